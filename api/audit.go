@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry records an exec session. Every session gets an entry when it
+// starts and one when it ends, with its exit code or error.
+type AuditEntry struct {
+	Time      time.Time
+	Event     string
+	Token     string
+	Remote    string
+	Host      string
+	Container string
+	Cmd       []string
+	TTY       bool
+	ExitCode  *int          `json:",omitempty"`
+	Duration  time.Duration `json:",omitempty"`
+	Error     string        `json:",omitempty"`
+}
+
+// AuditLog appends entries to a file as JSON lines.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: f}, nil
+}
+
+func (a *AuditLog) Record(e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *AuditLog) Close() error {
+	return a.file.Close()
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/configstore"
+	"github.com/rmerezha/mtrpz-lab4/planner"
+)
+
+func (s *Server) handleConfigCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	changed, err := s.Configs.Set(req.Name, req.Content)
+	if err != nil {
+		if errors.Is(err, configstore.ErrInvalidName) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to store config", http.StatusInternalServerError)
+		return
+	}
+
+	if changed {
+		n := s.Planner.RecreateContainers(func(c config.Container) bool {
+			return usesConfig(c, req.Name)
+		})
+		log.Printf("config %s changed, recreating %d container(s)", req.Name, n)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleConfigList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Configs.List()); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleConfigDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	err := s.Planner.DeleteUnused(func(c config.Container) bool {
+		return usesConfig(c, req.Name)
+	}, func() error {
+		return s.Configs.Delete(req.Name)
+	})
+	if err != nil {
+		var inUse *planner.InUseError
+		if errors.As(err, &inUse) {
+			http.Error(w, fmt.Sprintf("config is used by container %s in manifest %s", inUse.Container, inUse.Manifest), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, configstore.ErrNotFound) {
+			http.Error(w, "config not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to delete config", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkConfigs is called with the planner locked and must not use it.
+func (s *Server) checkConfigs(m *config.Manifest) config.ValidationError {
+	var errs config.ValidationError
+	for i, c := range m.Containers {
+		for j, f := range c.Configs {
+			if f.Name != "" && !s.Configs.Has(f.Name) {
+				errs = append(errs, config.FieldError{
+					Path:    fmt.Sprintf("containers[%d].configs[%d].name", i, j),
+					Message: fmt.Sprintf("config %q does not exist", f.Name),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+func usesConfig(c config.Container, name string) bool {
+	for _, f := range c.Configs {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/auth"
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/stream"
+)
+
+// execBroker hands the exec requests of clients to the slaves, which poll
+// for them like for log requests, and the connections the slaves open back
+// to the clients.
+type execBroker struct {
+	mu       sync.Mutex
+	pending  map[string]chan *execSession
+	sessions map[string]*execSession
+}
+
+type execSession struct {
+	host   string
+	req    config.ExecRequest
+	attach chan upgraded
+	// done is closed when the client is gone.
+	done chan struct{}
+}
+
+// upgraded is a hijacked connection speaking stream frames.
+type upgraded struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (b *execBroker) queue(host string) chan *execSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]chan *execSession)
+		b.sessions = make(map[string]*execSession)
+	}
+	q, ok := b.pending[host]
+	if !ok {
+		q = make(chan *execSession, maxPending)
+		b.pending[host] = q
+	}
+	return q
+}
+
+func (b *execBroker) open(host string, req config.ExecRequest) (*execSession, bool) {
+	q := b.queue(host)
+	es := &execSession{host: host, req: req, attach: make(chan upgraded), done: make(chan struct{})}
+	b.mu.Lock()
+	b.sessions[req.ID] = es
+	b.mu.Unlock()
+	select {
+	case q <- es:
+		return es, true
+	default:
+		b.close(es)
+		return nil, false
+	}
+}
+
+func (b *execBroker) close(es *execSession) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, es.req.ID)
+	close(es.done)
+}
+
+func (b *execBroker) session(id string) (*execSession, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	es, ok := b.sessions[id]
+	return es, ok
+}
+
+// upgrade switches the connection of r to stream frames.
+func upgrade(w http.ResponseWriter, r *http.Request) (upgraded, error) {
+	if r.Header.Get("Upgrade") != stream.Upgrade {
+		http.Error(w, "expected an upgrade to "+stream.Upgrade, http.StatusBadRequest)
+		return upgraded{}, errors.New("no upgrade requested")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "failed to hijack the connection", http.StatusInternalServerError)
+		return upgraded{}, err
+	}
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: " + stream.Upgrade + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return upgraded{}, err
+	}
+	return upgraded{conn: conn, r: rw.Reader}, nil
+}
+
+// handleContainerExec runs an interactive command in a container, relaying
+// the frames of the client to the slave and back. Every session is
+// recorded in the audit log.
+func (s *Server) handleContainerExec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	host, name := q.Get("host"), q.Get("container")
+	if host == "" || name == "" {
+		http.Error(w, "missing 'host' or 'container' query param", http.StatusBadRequest)
+		return
+	}
+	req := config.ExecRequest{Container: name, Cmd: q["cmd"], TTY: q.Get("tty") == "true"}
+	if len(req.Cmd) == 0 {
+		http.Error(w, "missing 'cmd' query param", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Upgrade") != stream.Upgrade {
+		http.Error(w, "expected an upgrade to "+stream.Upgrade, http.StatusBadRequest)
+		return
+	}
+	if !s.hasContainer(host, name) {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
+	id, err := requestID()
+	if err != nil {
+		http.Error(w, "failed to generate request id", http.StatusInternalServerError)
+		return
+	}
+	req.ID = id
+
+	entry := AuditEntry{
+		Time:      time.Now(),
+		Event:     "exec.start",
+		Token:     auth.Fingerprint(bearer(r)),
+		Remote:    r.RemoteAddr,
+		Host:      host,
+		Container: name,
+		Cmd:       req.Cmd,
+		TTY:       req.TTY,
+	}
+	if err := s.Audit.Record(entry); err != nil {
+		http.Error(w, "failed to write the audit log", http.StatusInternalServerError)
+		return
+	}
+	start := entry.Time
+	entry.Event = "exec.end"
+	defer func() {
+		entry.Time = time.Now()
+		entry.Duration = entry.Time.Sub(start)
+		_ = s.Audit.Record(entry)
+	}()
+
+	es, ok := s.execs.open(host, req)
+	if !ok {
+		entry.Error = "too many pending exec requests"
+		http.Error(w, "too many pending exec requests for "+host, http.StatusServiceUnavailable)
+		return
+	}
+	defer s.execs.close(es)
+
+	var slave upgraded
+	select {
+	case slave = <-es.attach:
+	case <-time.After(pickupTimeout):
+		entry.Error = "slave did not answer"
+		http.Error(w, "slave "+host+" did not answer", http.StatusGatewayTimeout)
+		return
+	case <-r.Context().Done():
+		entry.Error = "client gone"
+		return
+	}
+	defer slave.conn.Close()
+
+	client, err := upgrade(w, r)
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+	defer client.conn.Close()
+
+	go func() {
+		_, _ = io.Copy(slave.conn, client.r)
+		slave.conn.Close()
+	}()
+	out := stream.NewWriter(client.conn)
+	for {
+		typ, p, err := stream.ReadFrame(slave.r)
+		if err != nil {
+			if entry.ExitCode == nil && entry.Error == "" {
+				entry.Error = "connection lost"
+			}
+			return
+		}
+		switch typ {
+		case stream.Exit:
+			if code, err := stream.ParseExit(p); err == nil {
+				entry.ExitCode = &code
+			}
+		case stream.Error:
+			entry.Error = string(p)
+		}
+		if err := out.WriteFrame(typ, p); err != nil {
+			entry.Error = "client gone"
+			return
+		}
+	}
+}
+
+// handleExecPoll answers a slave polling for exec requests with the next
+// one, or with 204 when none arrives in time.
+func (s *Server) handleExecPoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		http.Error(w, "missing 'host' query param", http.StatusBadRequest)
+		return
+	}
+	if !slaveOf(s.Auth, w, r, host) {
+		return
+	}
+
+	q := s.execs.queue(host)
+	timeout := time.After(pollTimeout)
+	for {
+		select {
+		case es := <-q:
+			select {
+			case <-es.done:
+				// The client gave up meanwhile.
+				continue
+			default:
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(es.req)
+			return
+		case <-timeout:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleExecAttach takes the connection a slave opens for an exec request
+// and hands it to the waiting client.
+func (s *Server) handleExecAttach(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	es, ok := s.execs.session(r.URL.Query().Get("id"))
+	if !ok {
+		http.Error(w, "exec request is gone", http.StatusGone)
+		return
+	}
+	if !slaveOf(s.Auth, w, r, es.host) {
+		return
+	}
+	slave, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	select {
+	case es.attach <- slave:
+	case <-es.done:
+		slave.conn.Close()
+	}
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+var (
+	// pollTimeout is how long the master holds a poll of a slave for log or
+	// exec requests before answering that there is none.
+	pollTimeout = 30 * time.Second
+	// pickupTimeout is how long a client waits for the slave to answer its
+	// log or exec request.
+	pickupTimeout = 30 * time.Second
+)
+
+// maxPending bounds the log or exec requests queued for a slave.
+const maxPending = 16
+
+// logBroker hands the log requests of clients to the slaves, which poll for
+// them, and the chunks the slaves send back to the clients. The slave always
+// initiates the connection, as for the desired state.
+type logBroker struct {
+	mu       sync.Mutex
+	pending  map[string]chan *logSession
+	sessions map[string]*logSession
+}
+
+type logSession struct {
+	host   string
+	req    config.LogRequest
+	chunks chan logChunk
+	// done is closed when the client is gone.
+	done chan struct{}
+}
+
+type logChunk struct {
+	data []byte
+	// err is the failure the slave ended the stream with.
+	err string
+	eof bool
+}
+
+func (b *logBroker) queue(host string) chan *logSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]chan *logSession)
+		b.sessions = make(map[string]*logSession)
+	}
+	q, ok := b.pending[host]
+	if !ok {
+		q = make(chan *logSession, maxPending)
+		b.pending[host] = q
+	}
+	return q
+}
+
+// open queues a log request for host.
+func (b *logBroker) open(host string, req config.LogRequest) (*logSession, bool) {
+	q := b.queue(host)
+	ls := &logSession{host: host, req: req, chunks: make(chan logChunk), done: make(chan struct{})}
+	b.mu.Lock()
+	b.sessions[req.ID] = ls
+	b.mu.Unlock()
+	select {
+	case q <- ls:
+		return ls, true
+	default:
+		b.close(ls)
+		return nil, false
+	}
+}
+
+func (b *logBroker) close(ls *logSession) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, ls.req.ID)
+	close(ls.done)
+}
+
+func (b *logBroker) session(id string) (*logSession, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ls, ok := b.sessions[id]
+	return ls, ok
+}
+
+func (s *Server) handleContainerLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	host, name := q.Get("host"), q.Get("container")
+	if host == "" || name == "" {
+		http.Error(w, "missing 'host' or 'container' query param", http.StatusBadRequest)
+		return
+	}
+	if !s.hasContainer(host, name) {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
+
+	req := config.LogRequest{Container: name, Follow: q.Get("follow") == "true"}
+	if v := q.Get("since"); v != "" {
+		since, err := parseSince(v)
+		if err != nil {
+			http.Error(w, "invalid 'since': "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Since = since
+	}
+	if v := q.Get("tail"); v != "" {
+		tail, err := strconv.Atoi(v)
+		if err != nil || tail < 0 {
+			http.Error(w, "invalid 'tail'", http.StatusBadRequest)
+			return
+		}
+		req.Tail = tail
+	}
+	id, err := requestID()
+	if err != nil {
+		http.Error(w, "failed to generate request id", http.StatusInternalServerError)
+		return
+	}
+	req.ID = id
+
+	ls, ok := s.logs.open(host, req)
+	if !ok {
+		http.Error(w, "too many pending log requests for "+host, http.StatusServiceUnavailable)
+		return
+	}
+	defer s.logs.close(ls)
+
+	// The first chunk, possibly empty, tells that the slave picked it up.
+	var chunk logChunk
+	select {
+	case chunk = <-ls.chunks:
+	case <-time.After(pickupTimeout):
+		http.Error(w, "slave "+host+" did not answer", http.StatusGatewayTimeout)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	if chunk.err != "" && len(chunk.data) == 0 {
+		http.Error(w, chunk.err, http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	for {
+		if len(chunk.data) > 0 {
+			if _, err := w.Write(chunk.data); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if chunk.err != "" {
+			_, _ = io.WriteString(w, "error: "+chunk.err+"\n")
+		}
+		if chunk.eof {
+			return
+		}
+		select {
+		case chunk = <-ls.chunks:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) hasContainer(host, name string) bool {
+	for _, cs := range s.Planner.ListContainersByHost(host) {
+		if cs.Config.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func requestID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// parseSince accepts a duration back from now, e.g. 10m, or an RFC 3339
+// time.
+func parseSince(v string) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// handleLogPoll answers a slave polling for log requests with the next one,
+// or with 204 when none arrives in time.
+func (s *Server) handleLogPoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		http.Error(w, "missing 'host' query param", http.StatusBadRequest)
+		return
+	}
+	if !slaveOf(s.Auth, w, r, host) {
+		return
+	}
+
+	q := s.logs.queue(host)
+	timeout := time.After(pollTimeout)
+	for {
+		select {
+		case ls := <-q:
+			select {
+			case <-ls.done:
+				// The client gave up meanwhile.
+				continue
+			default:
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ls.req)
+			return
+		case <-timeout:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleLogChunk passes a chunk of logs from the slave to the client. It
+// answers 410 when the client is gone, which tells the slave to stop.
+func (s *Server) handleLogChunk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	ls, ok := s.logs.session(q.Get("id"))
+	if !ok {
+		http.Error(w, "log request is gone", http.StatusGone)
+		return
+	}
+	if !slaveOf(s.Auth, w, r, ls.host) {
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	chunk := logChunk{data: data, err: q.Get("error"), eof: q.Get("eof") == "true"}
+
+	select {
+	case ls.chunks <- chunk:
+	case <-ls.done:
+		http.Error(w, "log request is gone", http.StatusGone)
+		return
+	case <-r.Context().Done():
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
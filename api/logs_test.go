@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/auth"
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/planner"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	m, err := auth.NewManager(filepath.Join(t.TempDir(), "tokens.txt"))
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	if err := m.AddToken("node1-token", auth.SlaveScope("node1")); err != nil {
+		t.Fatalf("failed to add token: %v", err)
+	}
+	if err := m.AddToken("node2-token", auth.SlaveScope("node2")); err != nil {
+		t.Fatalf("failed to add token: %v", err)
+	}
+
+	s := &Server{Planner: planner.NewPlanner(), Auth: m}
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func do(t *testing.T, method, url, token string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestLogPoll_SlaveOnly(t *testing.T) {
+	defer func(d time.Duration) { pollTimeout = d }(pollTimeout)
+	pollTimeout = 10 * time.Millisecond
+
+	_, ts := newTestServer(t)
+	url := ts.URL + "/api/v1/logs/poll?host=node1"
+
+	if code := do(t, http.MethodGet, url, "node2-token"); code != http.StatusForbidden {
+		t.Errorf("expected 403 for the token of another slave, got %d", code)
+	}
+	if code := do(t, http.MethodGet, url, "node1-token"); code != http.StatusNoContent {
+		t.Errorf("expected 204 for the slave token, got %d", code)
+	}
+}
+
+func TestLogChunk_SlaveOnly(t *testing.T) {
+	s, ts := newTestServer(t)
+
+	ls, ok := s.logs.open("node1", config.LogRequest{ID: "req1", Container: "web"})
+	if !ok {
+		t.Fatal("failed to open log session")
+	}
+	defer s.logs.close(ls)
+	go func() {
+		for {
+			select {
+			case <-ls.chunks:
+			case <-ls.done:
+				return
+			}
+		}
+	}()
+
+	url := ts.URL + "/api/v1/logs/chunk?id=req1&eof=true"
+	if code := do(t, http.MethodPost, url, "node2-token"); code != http.StatusForbidden {
+		t.Errorf("expected 403 for the token of another slave, got %d", code)
+	}
+
+	if code := do(t, http.MethodPost, url, "node1-token"); code != http.StatusNoContent {
+		t.Errorf("expected 204 for the slave token, got %d", code)
+	}
+}
@@ -2,19 +2,40 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/rmerezha/mtrpz-lab4/auth"
 	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/configstore"
 	"gopkg.in/yaml.v3"
 	"io"
+	"log"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/rmerezha/mtrpz-lab4/planner"
+	"github.com/rmerezha/mtrpz-lab4/secrets"
 )
 
 type Server struct {
 	Planner  *planner.Planner
 	Auth     *auth.Manager
+	Secrets  *secrets.Store
+	Configs  *configstore.Store
 	Password string
+	// ExecPassword is required instead of Password to generate tokens with
+	// the exec scope, unless the request carries a token having it.
+	ExecPassword string
+	// Registries holds the credentials for private registries by host.
+	Registries *secrets.Store
+	// Audit records the exec sessions.
+	Audit *AuditLog
+	// StatsWindow is how long the stats of a container are kept.
+	StatsWindow time.Duration
+
+	logs  logBroker
+	execs execBroker
 }
 
 func (s *Server) handleUpdateState(w http.ResponseWriter, r *http.Request) {
@@ -27,6 +48,9 @@ func (s *Server) handleUpdateState(w http.ResponseWriter, r *http.Request) {
 		Host          string                `json:"host"`
 		ContainerName string                `json:"name"`
 		State         config.ContainerState `json:"state"`
+		Ports         []string              `json:"ports,omitempty"`
+		Volumes       []config.VolumeUsage  `json:"volumes,omitempty"`
+		Job           *config.JobStatus     `json:"job,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -34,11 +58,103 @@ func (s *Server) handleUpdateState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ok := s.Planner.UpdateState(req.Host, req.ContainerName, req.State)
+	var ok bool
+	if req.State == config.StateRemoved {
+		ok = s.Planner.SetRemoved(req.Host, req.ContainerName)
+	} else {
+		ok = s.Planner.UpdateState(req.Host, req.ContainerName, req.State)
+	}
 	if !ok {
 		http.Error(w, "container not found", http.StatusNotFound)
 		return
 	}
+	s.Planner.SetEndpoints(req.Host, req.ContainerName, req.Ports)
+	// The slave inspects the volumes of running containers only.
+	if req.State == config.StateRunning {
+		s.Planner.SetVolumeUsage(req.Host, req.ContainerName, req.Volumes)
+	}
+	if req.Job != nil {
+		s.Planner.SetJobStatus(req.Host, req.ContainerName, *req.Job)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleHookResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Host          string            `json:"host"`
+		ContainerName string            `json:"name"`
+		Result        config.HookResult `json:"result"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Result.Hook == "" {
+		http.Error(w, "missing hook name", http.StatusBadRequest)
+		return
+	}
+
+	if !s.Planner.RecordHook(req.Host, req.ContainerName, req.Result) {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePullResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Host          string            `json:"host"`
+		ContainerName string            `json:"name"`
+		Result        config.PullResult `json:"result"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if !s.Planner.RecordPull(req.Host, req.ContainerName, req.Result) {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePullProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Host          string              `json:"host"`
+		ContainerName string              `json:"name"`
+		Progress      config.PullProgress `json:"progress"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if !s.Planner.SetPullProgress(req.Host, req.ContainerName, req.Progress) {
+		http.Error(w, "container not found", http.StatusNotFound)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -54,11 +170,26 @@ func (s *Server) handleListContainers(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing 'host' query param", http.StatusBadRequest)
 		return
 	}
+	// The list carries the secrets of the containers, so only the slave of
+	// the host may get it.
+	if !slaveOf(s.Auth, w, r, host) {
+		return
+	}
 
 	containers := s.Planner.ListContainersByHost(host)
 
+	result := make([]config.ContainerStatus, 0, len(containers))
+	for _, cs := range containers {
+		resolved, err := s.resolve(cs)
+		if err != nil {
+			log.Printf("skipping container %s on %s: %v", cs.Config.Name, host, err)
+			continue
+		}
+		result = append(result, resolved)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(containers); err != nil {
+	if err := json.NewEncoder(w).Encode(result); err != nil {
 		http.Error(w, "failed to encode response", http.StatusInternalServerError)
 	}
 }
@@ -117,18 +248,35 @@ func (s *Server) handleManifestUp(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	var manifest config.Manifest
-	if err := yaml.Unmarshal(data, &manifest); err != nil {
-		http.Error(w, "invalid YAML: "+err.Error(), http.StatusBadRequest)
+	manifest, err := config.DecodeManifest(data)
+	if err != nil {
+		writeValidationError(w, err)
 		return
 	}
 
-	if err := manifest.Validate(); err != nil {
-		http.Error(w, "invalid manifest: "+err.Error(), http.StatusBadRequest)
+	if err := manifest.Normalize(); err != nil {
+		writeValidationError(w, err)
 		return
 	}
 
-	s.Planner.AddManifest(&manifest)
+	// Secrets and configs are checked under the planner lock so that they
+	// cannot be deleted before the manifest is added.
+	checkRefs := func(m *config.Manifest) error {
+		if errs := append(s.checkSecrets(m), s.checkConfigs(m)...); len(errs) > 0 {
+			return errs
+		}
+		return nil
+	}
+	if err := s.Planner.AddManifest(manifest, checkRefs); err != nil {
+		var root yaml.Node
+		_ = yaml.Unmarshal(data, &root)
+		var errs config.ValidationError
+		if errors.As(err, &errs) {
+			err = config.Locate(&root, errs)
+		}
+		writeValidationError(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -183,6 +331,23 @@ func (s *Server) handleManifestPS(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleManifestSchema serves the JSON Schema of manifests. It is public, like
+// the schema in the repository, so editors can fetch it without a token.
+func (s *Server) handleManifestSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := config.Schema()
+	if err != nil {
+		http.Error(w, "failed to generate schema: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(data)
+}
+
 func (s *Server) handleGenerateToken(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -190,7 +355,8 @@ func (s *Server) handleGenerateToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Password string `json:"password"`
+		Password string   `json:"password"`
+		Scopes   []string `json:"scopes,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -198,7 +364,23 @@ func (s *Server) handleGenerateToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Password != s.Password {
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			http.Error(w, "unknown scope "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The exec scope is granted to holders of the exec password or of an
+	// exec token only, who may generate any token.
+	execAuth := s.ExecPassword != "" && req.Password == s.ExecPassword ||
+		s.Auth.HasScope(bearer(r), auth.ScopeExec)
+	if slices.Contains(req.Scopes, auth.ScopeExec) {
+		if !execAuth {
+			http.Error(w, "the exec scope needs the exec password or an exec token", http.StatusForbidden)
+			return
+		}
+	} else if req.Password != s.Password && !execAuth {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -208,8 +390,7 @@ func (s *Server) handleGenerateToken(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to generate token", http.StatusInternalServerError)
 		return
 	}
-
-	if err := s.Auth.AddToken(token); err != nil {
+	if err := s.Auth.AddToken(token, req.Scopes...); err != nil {
 		http.Error(w, "failed to store token", http.StatusInternalServerError)
 		return
 	}
@@ -224,12 +405,116 @@ func (s *Server) handleGenerateToken(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// writeValidationError responds with 400 and the list of problems found in a
+// submitted manifest as JSON.
+func writeValidationError(w http.ResponseWriter, err error) {
+	var errs config.ValidationError
+	if !errors.As(err, &errs) {
+		errs = config.ValidationError{{Message: err.Error()}}
+	}
+
+	resp := struct {
+		Error  string              `json:"error"`
+		Errors []config.FieldError `json:"errors"`
+	}{
+		Error:  "invalid manifest",
+		Errors: errs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// resolve returns cs carrying the plaintext of every secret and the content
+// of every named config the container references. It must only be used for
+// the hosting slave's view.
+func (s *Server) resolve(cs config.ContainerStatus) (config.ContainerStatus, error) {
+	res := cs
+	// The slave reported the stats itself.
+	res.Stats = nil
+
+	if len(cs.Config.Secrets) > 0 {
+		res.Secrets = make(map[string]string, len(cs.Config.Secrets))
+		for _, ref := range cs.Config.Secrets {
+			val, err := s.Secrets.Get(ref.Name)
+			if err != nil {
+				return res, fmt.Errorf("secret %q: %w", ref.Name, err)
+			}
+			res.Secrets[ref.Name] = string(val)
+		}
+	}
+
+	for _, f := range cs.Config.Configs {
+		if f.Name == "" {
+			continue
+		}
+		content, err := s.Configs.Get(f.Name)
+		if err != nil {
+			return res, fmt.Errorf("config %q: %w", f.Name, err)
+		}
+		if res.Configs == nil {
+			res.Configs = make(map[string]string)
+		}
+		res.Configs[f.Name] = content
+	}
+
+	auth, err := s.registryAuth(cs.Config.Image)
+	if err != nil {
+		return res, err
+	}
+	res.RegistryAuth = auth
+
+	for _, h := range cs.Config.PreStart {
+		if h.Image == "" {
+			continue
+		}
+		auth, err := s.registryAuth(h.Image)
+		if err != nil {
+			return res, err
+		}
+		if auth == nil {
+			continue
+		}
+		if res.HookRegistryAuth == nil {
+			res.HookRegistryAuth = make(map[string]*config.RegistryAuth)
+		}
+		res.HookRegistryAuth[config.ImageRegistry(h.Image)] = auth
+	}
+	res.Config.Image = s.Planner.PinnedImage(cs.ManifestName, cs.Config.Image)
+
+	return res, nil
+}
+
 func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/state", withAuth(s.Auth, s.handleUpdateState))
+	mux.HandleFunc("/api/v1/hook", withAuth(s.Auth, s.handleHookResult))
+	mux.HandleFunc("/api/v1/pull", withAuth(s.Auth, s.handlePullResult))
+	mux.HandleFunc("/api/v1/pull/progress", withAuth(s.Auth, s.handlePullProgress))
 	mux.HandleFunc("/api/v1/container", withAuth(s.Auth, s.handleListContainers))
 	mux.HandleFunc("/api/v1/container/action", withAuth(s.Auth, s.handleContainerAction))
+	mux.HandleFunc("/api/v1/container/logs", withAuth(s.Auth, s.handleContainerLogs))
+	mux.HandleFunc("/api/v1/logs/poll", withAuth(s.Auth, s.handleLogPoll))
+	mux.HandleFunc("/api/v1/logs/chunk", withAuth(s.Auth, s.handleLogChunk))
+	mux.HandleFunc("/api/v1/container/exec", withScope(s.Auth, auth.ScopeExec, s.handleContainerExec))
+	mux.HandleFunc("/api/v1/exec/poll", withAuth(s.Auth, s.handleExecPoll))
+	mux.HandleFunc("/api/v1/exec/attach", withAuth(s.Auth, s.handleExecAttach))
+	mux.HandleFunc("/api/v1/stats", withAuth(s.Auth, s.handleStatsList))
+	mux.HandleFunc("/api/v1/stats/report", withAuth(s.Auth, s.handleReportStats))
 	mux.HandleFunc("/api/v1/manifest/up", withAuth(s.Auth, s.handleManifestUp))
 	mux.HandleFunc("/api/v1/manifest/down", withAuth(s.Auth, s.handleManifestDown))
 	mux.HandleFunc("/api/v1/manifest/ps", withAuth(s.Auth, s.handleManifestPS))
+	// The schema is public on purpose, see handleManifestSchema.
+	mux.HandleFunc("/api/v1/manifest/schema", s.handleManifestSchema)
+	mux.HandleFunc("/api/v1/cron", withAuth(s.Auth, s.handleCronList))
+	mux.HandleFunc("/api/v1/secret", withAuth(s.Auth, s.handleSecretList))
+	mux.HandleFunc("/api/v1/secret/create", withAuth(s.Auth, s.handleSecretCreate))
+	mux.HandleFunc("/api/v1/secret/delete", withAuth(s.Auth, s.handleSecretDelete))
+	mux.HandleFunc("/api/v1/registry", withAuth(s.Auth, s.handleRegistryList))
+	mux.HandleFunc("/api/v1/registry/create", withAuth(s.Auth, s.handleRegistryCreate))
+	mux.HandleFunc("/api/v1/registry/delete", withAuth(s.Auth, s.handleRegistryDelete))
+	mux.HandleFunc("/api/v1/config", withAuth(s.Auth, s.handleConfigList))
+	mux.HandleFunc("/api/v1/config/create", withAuth(s.Auth, s.handleConfigCreate))
+	mux.HandleFunc("/api/v1/config/delete", withAuth(s.Auth, s.handleConfigDelete))
 	mux.HandleFunc("/api/v1/token", s.handleGenerateToken)
 }
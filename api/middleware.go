@@ -23,3 +23,28 @@ func withAuth(authManager *auth.Manager, next http.HandlerFunc) http.HandlerFunc
 		next(w, r)
 	}
 }
+
+// withScope lets through only the tokens having scope.
+func withScope(authManager *auth.Manager, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return withAuth(authManager, func(w http.ResponseWriter, r *http.Request) {
+		if !authManager.HasScope(bearer(r), scope) {
+			http.Error(w, "token lacks the "+scope+" scope", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// slaveOf reports whether the token of r is the slave token of host, and
+// responds with 403 otherwise.
+func slaveOf(authManager *auth.Manager, w http.ResponseWriter, r *http.Request, host string) bool {
+	if !authManager.HasScope(bearer(r), auth.SlaveScope(host)) {
+		http.Error(w, "token is not the slave token of host "+host, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func bearer(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/secrets"
+)
+
+func (s *Server) handleRegistryCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Registry string `json:"registry"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		http.Error(w, "missing username or password", http.StatusBadRequest)
+		return
+	}
+
+	value, err := json.Marshal(config.RegistryAuth{Registry: req.Registry, Username: req.Username, Password: req.Password})
+	if err != nil {
+		http.Error(w, "failed to encode credentials", http.StatusInternalServerError)
+		return
+	}
+	if err := s.Registries.Set(req.Registry, value); err != nil {
+		if errors.Is(err, secrets.ErrInvalidName) {
+			http.Error(w, "invalid registry host", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to store credentials", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleRegistryList lists the registries with credentials; the
+// credentials themselves are never returned.
+func (s *Server) handleRegistryList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Registries.List()); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleRegistryDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Registry string `json:"registry"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Registries.Delete(req.Registry); err != nil {
+		if errors.Is(err, secrets.ErrNotFound) {
+			http.Error(w, "registry not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to delete credentials", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// registryAuth returns the credentials for the registry of the image, or nil
+// if there are none.
+func (s *Server) registryAuth(image string) (*config.RegistryAuth, error) {
+	registry := config.ImageRegistry(image)
+	if s.Registries == nil || !s.Registries.Has(registry) {
+		return nil, nil
+	}
+	value, err := s.Registries.Get(registry)
+	if err != nil {
+		return nil, fmt.Errorf("credentials for registry %q: %w", registry, err)
+	}
+	var auth config.RegistryAuth
+	if err := json.Unmarshal(value, &auth); err != nil {
+		return nil, fmt.Errorf("credentials for registry %q: %w", registry, err)
+	}
+	return &auth, nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/planner"
+	"github.com/rmerezha/mtrpz-lab4/secrets"
+)
+
+func (s *Server) handleSecretCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Secrets.Set(req.Name, []byte(req.Value)); err != nil {
+		if errors.Is(err, secrets.ErrInvalidName) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to store secret", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleSecretList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Secrets.List()); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleSecretDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	err := s.Planner.DeleteUnused(func(c config.Container) bool {
+		return usesSecret(c, req.Name)
+	}, func() error {
+		return s.Secrets.Delete(req.Name)
+	})
+	if err != nil {
+		var inUse *planner.InUseError
+		if errors.As(err, &inUse) {
+			http.Error(w, fmt.Sprintf("secret is used by container %s in manifest %s", inUse.Container, inUse.Manifest), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, secrets.ErrNotFound) {
+			http.Error(w, "secret not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to delete secret", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSecrets is called with the planner locked and must not use it.
+func (s *Server) checkSecrets(m *config.Manifest) config.ValidationError {
+	var errs config.ValidationError
+	for i, c := range m.Containers {
+		for j, ref := range c.Secrets {
+			if !s.Secrets.Has(ref.Name) {
+				errs = append(errs, config.FieldError{
+					Path:    fmt.Sprintf("containers[%d].secrets[%d].name", i, j),
+					Message: fmt.Sprintf("secret %q does not exist", ref.Name),
+				})
+			}
+		}
+	}
+	return errs
+}
+
+func usesSecret(c config.Container, name string) bool {
+	for _, ref := range c.Secrets {
+		if ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// handleReportStats records the stats a slave sampled for its containers.
+// Containers the master no longer knows are skipped.
+func (s *Server) handleReportStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Host  string                           `json:"host"`
+		Stats map[string]config.ContainerStats `json:"stats"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Host == "" {
+		http.Error(w, "missing host", http.StatusBadRequest)
+		return
+	}
+
+	for name, stats := range req.Stats {
+		s.Planner.RecordStats(req.Host, name, stats, s.StatsWindow)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleStatsList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	result := s.Planner.ListStats(q.Get("manifest"), q.Get("host"))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	}
+}
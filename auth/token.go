@@ -3,16 +3,38 @@ package auth
 import (
 	"bufio"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 )
 
+// ScopeExec lets a token run commands inside containers.
+const ScopeExec = "exec"
+
+const slaveScopePrefix = "slave:"
+
+// SlaveScope is the scope of the token of the slave running on host. Only
+// such a token receives the secrets of the containers of host.
+func SlaveScope(host string) string {
+	return slaveScopePrefix + host
+}
+
+// ValidScope reports whether scope is a known scope.
+func ValidScope(scope string) bool {
+	host, ok := strings.CutPrefix(scope, slaveScopePrefix)
+	return scope == ScopeExec || ok && host != "" && !strings.ContainsAny(host, ", \t")
+}
+
+// Manager keeps the tokens, one per line of its file, followed by the
+// comma-separated scopes of the token if any.
 type Manager struct {
 	mu     sync.RWMutex
-	tokens map[string]struct{}
+	tokens map[string][]string
 	file   *os.File
 }
 
@@ -23,7 +45,7 @@ func NewManager(filePath string) (*Manager, error) {
 	}
 
 	return &Manager{
-		tokens: make(map[string]struct{}),
+		tokens: make(map[string][]string),
 		file:   f,
 	}, nil
 }
@@ -43,7 +65,9 @@ func (m *Manager) GenerateToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-func (m *Manager) AddToken(token string) error {
+// AddToken stores token with the given scopes on top of the access every
+// token has.
+func (m *Manager) AddToken(token string, scopes ...string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -51,14 +75,23 @@ func (m *Manager) AddToken(token string) error {
 	if token == "" {
 		return errors.New("empty token")
 	}
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return fmt.Errorf("unknown scope %q", s)
+		}
+	}
 
-	m.tokens[token] = struct{}{}
+	m.tokens[token] = scopes
 
-	return m.addToFile(token)
+	return m.addToFile(token, scopes)
 }
 
-func (m *Manager) addToFile(token string) error {
-	_, err := m.file.WriteString(token + "\n")
+func (m *Manager) addToFile(token string, scopes []string) error {
+	line := token
+	if len(scopes) > 0 {
+		line += " " + strings.Join(scopes, ",")
+	}
+	_, err := m.file.WriteString(line + "\n")
 	return err
 }
 
@@ -70,6 +103,20 @@ func (m *Manager) ValidateToken(token string) bool {
 	return ok
 }
 
+// HasScope reports whether token is valid and has scope.
+func (m *Manager) HasScope(token, scope string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Contains(m.tokens[strings.TrimSpace(token)], scope)
+}
+
+// Fingerprint identifies a token in logs without disclosing it.
+func Fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:6])
+}
+
 func (m *Manager) LoadFromFile() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -80,12 +127,15 @@ func (m *Manager) LoadFromFile() error {
 
 	scanner := bufio.NewScanner(m.file)
 	for scanner.Scan() {
-		tok := strings.TrimSpace(scanner.Text())
-		if tok != "" {
-			if _, exists := m.tokens[tok]; !exists {
-				m.tokens[tok] = struct{}{}
-			}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var scopes []string
+		if len(fields) > 1 {
+			scopes = strings.Split(fields[1], ",")
 		}
+		m.tokens[fields[0]] = scopes
 	}
 
 	return scanner.Err()
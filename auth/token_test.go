@@ -103,3 +103,67 @@ func TestManager_TokenPersists(t *testing.T) {
 		t.Errorf("file content = %q; want %q", string(data), expected)
 	}
 }
+
+func TestManager_Scopes(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "tokens.txt")
+
+	manager, err := auth.NewManager(filePath)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer manager.Close()
+
+	if err := manager.AddToken("plain"); err != nil {
+		t.Fatalf("add token failed: %v", err)
+	}
+	if err := manager.AddToken("admin", auth.ScopeExec); err != nil {
+		t.Fatalf("add token failed: %v", err)
+	}
+	if err := manager.AddToken("bogus", "root"); err == nil {
+		t.Error("expected error for an unknown scope, got nil")
+	}
+
+	reloaded, err := auth.NewManager(filePath)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer reloaded.Close()
+	if err := reloaded.LoadFromFile(); err != nil {
+		t.Fatalf("failed to load tokens from file: %v", err)
+	}
+
+	if !reloaded.ValidateToken("admin") || !reloaded.HasScope("admin", auth.ScopeExec) {
+		t.Error("expected the admin token to keep its exec scope")
+	}
+	if !reloaded.ValidateToken("plain") || reloaded.HasScope("plain", auth.ScopeExec) {
+		t.Error("expected the plain token to be valid without the exec scope")
+	}
+}
+
+func TestManager_SlaveScope(t *testing.T) {
+	tmpDir := t.TempDir()
+	filePath := filepath.Join(tmpDir, "tokens.txt")
+
+	manager, err := auth.NewManager(filePath)
+	if err != nil {
+		t.Fatalf("failed to create manager: %v", err)
+	}
+	defer manager.Close()
+
+	if err := manager.AddToken("node1", auth.SlaveScope("node1")); err != nil {
+		t.Fatalf("add token failed: %v", err)
+	}
+	for _, scope := range []string{"slave:", "slave:a,b", "slave:a b"} {
+		if err := manager.AddToken("bogus", scope); err == nil {
+			t.Errorf("expected error for scope %q, got nil", scope)
+		}
+	}
+
+	if !manager.HasScope("node1", auth.SlaveScope("node1")) {
+		t.Error("expected the token to be the slave token of node1")
+	}
+	if manager.HasScope("node1", auth.SlaveScope("node2")) {
+		t.Error("expected the token not to be the slave token of node2")
+	}
+}
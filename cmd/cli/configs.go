@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rmerezha/mtrpz-lab4/configstore"
+)
+
+func handleConfig(args []string) {
+	if len(args) < 1 {
+		fmt.Println("expected subcommand: create/ls/rm")
+		os.Exit(1)
+	}
+	cmd := args[0]
+	flags := parseFlags(args[1:], []string{"-n", "-f", "--url", "--token"})
+	url, ok := flags["--url"]
+	if !ok {
+		fmt.Println("-url flag is required")
+		os.Exit(3)
+	}
+	token, ok := flags["--token"]
+	if !ok {
+		fmt.Println("-token flag is required")
+		os.Exit(3)
+	}
+
+	switch cmd {
+	case "create":
+		name, ok := flags["-n"]
+		if !ok {
+			fmt.Println("-n flag is required")
+			os.Exit(3)
+		}
+		file, ok := flags["-f"]
+		if !ok {
+			fmt.Println("-f flag is required")
+			os.Exit(3)
+		}
+		data, err := os.ReadFile(file)
+		checkErr(err)
+
+		body, _ := json.Marshal(map[string]string{"name": name, "content": string(data)})
+		req, _ := http.NewRequest("POST", url+"/api/v1/config/create", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp := doRequest(req)
+		fmt.Println("Config stored", resp.Status)
+
+	case "ls":
+		req, _ := http.NewRequest("GET", url+"/api/v1/config", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := doRequest(req)
+		defer resp.Body.Close()
+
+		var list []configstore.Info
+		checkErr(json.NewDecoder(resp.Body).Decode(&list))
+
+		fmt.Printf("%-30s  %-8s  %-20s\n", "Name", "Size", "Updated")
+		fmt.Println(strings.Repeat("-", 62))
+		for _, c := range list {
+			fmt.Printf("%-30s  %-8d  %-20s\n", shorten(c.Name, 30), c.Size, c.UpdatedAt.Format("2006-01-02 15:04:05"))
+		}
+
+	case "rm":
+		name, ok := flags["-n"]
+		if !ok {
+			fmt.Println("-n flag is required")
+			os.Exit(3)
+		}
+		body, _ := json.Marshal(map[string]string{"name": name})
+		req, _ := http.NewRequest("POST", url+"/api/v1/config/delete", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp := doRequest(req)
+		fmt.Println("Config removed", resp.Status)
+
+	default:
+		fmt.Println("unknown config subcommand")
+	}
+}
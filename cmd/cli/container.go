@@ -4,16 +4,27 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 )
 
 func handleContainer(args []string) {
 	if len(args) < 1 {
-		fmt.Println("expected subcommand: stop/kill/restart/rm")
+		fmt.Println("expected subcommand: stop/kill/restart/rm/logs/exec")
 		os.Exit(1)
 	}
 	cmd := args[0]
+	switch cmd {
+	case "logs":
+		handleContainerLogs(args[1:])
+		return
+	case "exec":
+		handleContainerExec(args[1:])
+		return
+	}
 	flags := parseFlags(args[1:], []string{"-h", "-c", "--url", "--token"})
 	host, ok := flags["-h"]
 	if !ok {
@@ -48,3 +59,39 @@ func handleContainer(args []string) {
 	resp := doRequest(req)
 	fmt.Println("Action", cmd, "status:", resp.Status)
 }
+
+// handleContainerLogs prints the logs of a container, streamed by its slave
+// through the master.
+func handleContainerLogs(args []string) {
+	flags := parseFlags(args, []string{"-h", "-c", "--since", "--tail", "--url", "--token"})
+	for _, key := range []string{"-h", "-c", "--url", "--token"} {
+		if _, ok := flags[key]; !ok {
+			fmt.Println(key, "flag is required")
+			os.Exit(3)
+		}
+	}
+
+	q := url.Values{"host": {flags["-h"]}, "container": {flags["-c"]}}
+	if slices.Contains(args, "-f") {
+		q.Set("follow", "true")
+	}
+	if since, ok := flags["--since"]; ok {
+		q.Set("since", since)
+	}
+	if tail, ok := flags["--tail"]; ok {
+		q.Set("tail", tail)
+	}
+
+	req, _ := http.NewRequest("GET", flags["--url"]+"/api/v1/container/logs?"+q.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+flags["--token"])
+	resp, err := http.DefaultClient.Do(req)
+	checkErr(err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		fmt.Printf("HTTP error: %d: %s", resp.StatusCode, msg)
+		os.Exit(1)
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	checkErr(err)
+}
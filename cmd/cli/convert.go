@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rmerezha/mtrpz-lab4/compose"
+	"gopkg.in/yaml.v3"
+)
+
+// convertManifest translates a foreign file into a manifest. Untranslated
+// features are listed on stderr.
+func convertManifest(args []string) {
+	flags := parseFlags(args, []string{"--from", "-f", "--host", "--name", "-o"})
+	if flags["--from"] != "compose" {
+		fmt.Println("--from compose is required")
+		os.Exit(3)
+	}
+	file, ok := flags["-f"]
+	if !ok {
+		file = "docker-compose.yml"
+	}
+
+	hosts := map[string]string{}
+	for _, kv := range parseMultiFlag(args, "--service-host") {
+		service, host, ok := strings.Cut(kv, "=")
+		if !ok {
+			fmt.Println("--service-host must be service=host")
+			os.Exit(3)
+		}
+		hosts[service] = host
+	}
+
+	data, err := os.ReadFile(file)
+	checkErr(err)
+	m, warnings, err := compose.Convert(data, compose.Options{
+		Name:  flags["--name"],
+		Host:  flags["--host"],
+		Hosts: hosts,
+		Dir:   filepath.Dir(file),
+	})
+	checkErr(err)
+
+	out, err := yaml.Marshal(m)
+	checkErr(err)
+	if path, ok := flags["-o"]; ok {
+		checkErr(os.WriteFile(path, out, 0644))
+	} else {
+		fmt.Print(string(out))
+	}
+
+	if len(warnings) > 0 {
+		fmt.Fprintf(os.Stderr, "%d compose features were not translated:\n", len(warnings))
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "  "+w.String())
+		}
+	}
+	if err := m.Validate(); err != nil {
+		printValidationErrors(os.Stderr, err)
+		os.Exit(2)
+	}
+}
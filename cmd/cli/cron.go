@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+func handleCron(args []string) {
+	if len(args) < 1 {
+		fmt.Println("expected subcommand: ls/history")
+		os.Exit(1)
+	}
+	cmd := args[0]
+	flags := parseFlags(args[1:], []string{"-n", "-m", "--url", "--token"})
+	base, ok := flags["--url"]
+	if !ok {
+		fmt.Println("-url flag is required")
+		os.Exit(3)
+	}
+	token, ok := flags["--token"]
+	if !ok {
+		fmt.Println("-token flag is required")
+		os.Exit(3)
+	}
+
+	req, _ := http.NewRequest("GET", base+"/api/v1/cron?manifest="+url.QueryEscape(flags["-m"]), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	switch cmd {
+	case "ls":
+		resp := doRequest(req)
+		defer resp.Body.Close()
+		var jobs []config.CronJobStatus
+		checkErr(json.NewDecoder(resp.Body).Decode(&jobs))
+
+		fmt.Printf("%-10s  %-15s  %-6s  %-15s  %-20s  %-20s  %s\n", "Manifest", "Name", "Host", "Schedule", "Next", "Last", "Runs")
+		fmt.Println(strings.Repeat("-", 100))
+		for _, j := range jobs {
+			fmt.Printf("%-10s  %-15s  %-6s  %-15s  %-20s  %-20s  %d\n",
+				j.ManifestName,
+				shorten(j.Name, 15),
+				j.Host,
+				shorten(j.Schedule.Cron, 15),
+				formatTime(j.Next),
+				formatTime(j.Last),
+				len(j.Runs),
+			)
+		}
+
+	case "history":
+		name, ok := flags["-n"]
+		if !ok {
+			fmt.Println("-n flag is required")
+			os.Exit(3)
+		}
+		resp := doRequest(req)
+		defer resp.Body.Close()
+		var jobs []config.CronJobStatus
+		checkErr(json.NewDecoder(resp.Body).Decode(&jobs))
+
+		fmt.Printf("%-25s  %-6s  %-15s  %-20s  %-20s\n", "Run", "Host", "State", "Started", "Finished")
+		fmt.Println(strings.Repeat("-", 94))
+		for _, j := range jobs {
+			if j.Name != name {
+				continue
+			}
+			for _, r := range j.Runs {
+				var started, finished time.Time
+				if r.Job != nil {
+					started, finished = r.Job.Started, r.Job.Finished
+				}
+				fmt.Printf("%-25s  %-6s  %-15s  %-20s  %-20s\n",
+					r.Config.Name, j.Host, formatState(r), formatTime(started), formatTime(finished))
+			}
+		}
+
+	default:
+		fmt.Println("unknown cron subcommand")
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/rmerezha/mtrpz-lab4/stream"
+)
+
+// handleContainerExec runs the command after -- in a container, with a TTY
+// when stdin is a terminal unless -T is given.
+func handleContainerExec(args []string) {
+	i := slices.Index(args, "--")
+	if i < 0 || i == len(args)-1 {
+		fmt.Println("expected a command after --")
+		os.Exit(3)
+	}
+	args, cmd := args[:i], args[i+1:]
+	flags := parseFlags(args, []string{"-h", "-c", "--url", "--token"})
+	for _, key := range []string{"-h", "-c", "--url", "--token"} {
+		if _, ok := flags[key]; !ok {
+			fmt.Println(key, "flag is required")
+			os.Exit(3)
+		}
+	}
+
+	fd := int(os.Stdin.Fd())
+	tty := isTerminal(fd) && !slices.Contains(args, "-T")
+	q := url.Values{"host": {flags["-h"]}, "container": {flags["-c"]}, "cmd": cmd}
+	if tty {
+		q.Set("tty", "true")
+	}
+
+	req, _ := http.NewRequest("GET", flags["--url"]+"/api/v1/container/exec?"+q.Encode(), nil)
+	req.Header.Set("Authorization", "Bearer "+flags["--token"])
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", stream.Upgrade)
+	resp, err := http.DefaultClient.Do(req)
+	checkErr(err)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		msg, _ := io.ReadAll(resp.Body)
+		fmt.Printf("HTTP error: %d: %s", resp.StatusCode, msg)
+		os.Exit(1)
+	}
+	conn := resp.Body.(io.ReadWriteCloser)
+	defer conn.Close()
+	out := stream.NewWriter(conn)
+
+	restore := func() {}
+	if tty {
+		restore, err = makeRaw(fd)
+		checkErr(err)
+		resize := func() {
+			if width, height, ok := terminalSize(fd); ok {
+				_ = out.WriteResize(width, height)
+			}
+		}
+		resize()
+		resized := make(chan os.Signal, 1)
+		notifyResize(resized)
+		go func() {
+			for range resized {
+				resize()
+			}
+		}()
+	}
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				if out.WriteFrame(stream.Stdin, buf[:n]) != nil {
+					return
+				}
+			}
+			if err != nil {
+				// An empty frame closes the stdin of the command.
+				_ = out.WriteFrame(stream.Stdin, nil)
+				return
+			}
+		}
+	}()
+
+	for {
+		typ, p, err := stream.ReadFrame(conn)
+		if err != nil {
+			restore()
+			fmt.Fprintln(os.Stderr, "connection lost:", err)
+			os.Exit(1)
+		}
+		switch typ {
+		case stream.Output:
+			_, _ = os.Stdout.Write(p)
+		case stream.Error:
+			restore()
+			fmt.Fprintln(os.Stderr, "error:", string(p))
+			os.Exit(1)
+		case stream.Exit:
+			code, err := stream.ParseExit(p)
+			restore()
+			checkErr(err)
+			os.Exit(code)
+		}
+	}
+}
@@ -4,7 +4,7 @@ import "os"
 
 func main() {
 	if len(os.Args) < 2 {
-		println("expected 'manifest', 'container' or 'token'")
+		println("expected 'manifest', 'container', 'top', 'cron', 'secret', 'config', 'registry' or 'token'")
 		os.Exit(1)
 	}
 
@@ -13,6 +13,16 @@ func main() {
 		handleManifest(os.Args[2:])
 	case "container":
 		handleContainer(os.Args[2:])
+	case "top":
+		handleTop(os.Args[2:])
+	case "cron":
+		handleCron(os.Args[2:])
+	case "secret":
+		handleSecret(os.Args[2:])
+	case "config":
+		handleConfig(os.Args[2:])
+	case "registry":
+		handleRegistry(os.Args[2:])
 	case "token":
 		handleToken(os.Args[2:])
 	default:
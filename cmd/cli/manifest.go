@@ -9,20 +9,44 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"slices"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
 )
 
 func handleManifest(args []string) {
 	if len(args) < 1 {
-		fmt.Println("expected subcommand: up/down/ps")
+		fmt.Println("expected subcommand: up/down/ps/render/schema/convert")
 		os.Exit(1)
 	}
 	cmd := args[0]
-	flags := parseFlags(args[1:], []string{"-f", "--url", "--token"})
-	file, ok := flags["-f"]
-	if !ok {
+	switch cmd {
+	case "schema":
+		writeSchema(args[1:])
+		return
+	case "convert":
+		convertManifest(args[1:])
+		return
+	}
+	flags := parseFlags(args[1:], []string{"--url", "--token", "--interval"})
+	files := parseMultiFlag(args[1:], "-f")
+	if len(files) == 0 {
 		fmt.Println("-f flag is required")
 		os.Exit(3)
 	}
+	manifestData, err := config.LoadManifestFiles(files...)
+	checkErr(err)
+
+	if cmd == "render" {
+		fmt.Print(string(manifestData))
+		if _, err := config.DecodeManifest(manifestData); err != nil {
+			printValidationErrors(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
 	url, ok := flags["--url"]
 	if !ok {
 		fmt.Println("-url flag is required")
@@ -33,15 +57,27 @@ func handleManifest(args []string) {
 		fmt.Println("-token flag is required")
 		os.Exit(3)
 	}
-	manifestData, err := os.ReadFile(file)
-	checkErr(err)
 
 	switch cmd {
 	case "up":
 		req, _ := http.NewRequest("POST", url+"/api/v1/manifest/up", bytes.NewReader(manifestData))
 		req.Header.Set("Authorization", "Bearer "+token)
 		req.Header.Set("Content-Type", "application/x-yaml")
-		resp := doRequest(req)
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(err)
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusBadRequest && resp.Header.Get("Content-Type") == "application/json" {
+			var body struct {
+				Errors config.ValidationError `json:"errors"`
+			}
+			checkErr(json.NewDecoder(resp.Body).Decode(&body))
+			printValidationErrors(os.Stdout, body.Errors)
+			os.Exit(2)
+		}
+		if resp.StatusCode >= 300 {
+			fmt.Printf("HTTP error: %d\n", resp.StatusCode)
+			os.Exit(1)
+		}
 		fmt.Println("Manifest uploaded", resp.Status)
 
 	case "down":
@@ -66,14 +102,42 @@ func handleManifest(args []string) {
 			log.Fatalf("failed to parse YAML: %v", err)
 		}
 		body, _ := json.Marshal(map[string]string{"manifest": parsed.Name})
-		req, _ := http.NewRequest("POST", url+"/api/v1/manifest/ps", bytes.NewReader(body))
-		req.Header.Set("Authorization", "Bearer "+token)
-		req.Header.Set("Content-Type", "application/json")
-		resp := doRequest(req)
-		data, _ := io.ReadAll(resp.Body)
-		printContainerListJSON(data)
+		digests := slices.Contains(args, "--digests")
+		watch := slices.Contains(args, "--watch")
+		interval := parseInterval(flags)
+		for {
+			req, _ := http.NewRequest("POST", url+"/api/v1/manifest/ps", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/json")
+			resp := doRequest(req)
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if watch {
+				// Clear the screen, like top.
+				fmt.Print("\033[H\033[2J")
+			}
+			printContainerListJSON(data, digests)
+			if !watch {
+				return
+			}
+			time.Sleep(interval)
+		}
 
 	default:
 		fmt.Println("unknown manifest subcommand")
 	}
 }
+
+// writeSchema writes the manifest JSON Schema to -o, or to stdout.
+func writeSchema(args []string) {
+	data, err := config.Schema()
+	checkErr(err)
+
+	out, ok := parseFlags(args, []string{"-o"})["-o"]
+	if !ok {
+		fmt.Print(string(data))
+		return
+	}
+	checkErr(os.WriteFile(out, data, 0644))
+	fmt.Println("Schema written to", out)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rmerezha/mtrpz-lab4/secrets"
+)
+
+func handleRegistry(args []string) {
+	if len(args) < 1 {
+		fmt.Println("expected subcommand: create/ls/rm")
+		os.Exit(1)
+	}
+	cmd := args[0]
+	flags := parseFlags(args[1:], []string{"-r", "-u", "--url", "--token"})
+	url, ok := flags["--url"]
+	if !ok {
+		fmt.Println("-url flag is required")
+		os.Exit(3)
+	}
+	token, ok := flags["--token"]
+	if !ok {
+		fmt.Println("-token flag is required")
+		os.Exit(3)
+	}
+
+	switch cmd {
+	case "create":
+		registry, ok := flags["-r"]
+		if !ok {
+			fmt.Println("-r flag is required")
+			os.Exit(3)
+		}
+		user, ok := flags["-u"]
+		if !ok {
+			fmt.Println("-u flag is required")
+			os.Exit(3)
+		}
+		// The password is read from stdin so that it stays out of the
+		// shell history.
+		fmt.Fprint(os.Stderr, "Enter password: ")
+		pass, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if pass == "" {
+			checkErr(err)
+		}
+		pass = strings.TrimRight(pass, "\r\n")
+
+		body, _ := json.Marshal(map[string]string{"registry": registry, "username": user, "password": pass})
+		req, _ := http.NewRequest("POST", url+"/api/v1/registry/create", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp := doRequest(req)
+		fmt.Println("Registry credentials stored", resp.Status)
+
+	case "ls":
+		req, _ := http.NewRequest("GET", url+"/api/v1/registry", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := doRequest(req)
+		defer resp.Body.Close()
+
+		var list []secrets.Info
+		checkErr(json.NewDecoder(resp.Body).Decode(&list))
+
+		fmt.Printf("%-40s  %-20s\n", "Registry", "Created")
+		fmt.Println(strings.Repeat("-", 62))
+		for _, r := range list {
+			fmt.Printf("%-40s  %-20s\n", shorten(r.Name, 40), r.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+
+	case "rm":
+		registry, ok := flags["-r"]
+		if !ok {
+			fmt.Println("-r flag is required")
+			os.Exit(3)
+		}
+		body, _ := json.Marshal(map[string]string{"registry": registry})
+		req, _ := http.NewRequest("POST", url+"/api/v1/registry/delete", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp := doRequest(req)
+		fmt.Println("Registry credentials removed", resp.Status)
+
+	default:
+		fmt.Println("unknown registry subcommand")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rmerezha/mtrpz-lab4/secrets"
+)
+
+func handleSecret(args []string) {
+	if len(args) < 1 {
+		fmt.Println("expected subcommand: create/ls/rm")
+		os.Exit(1)
+	}
+	cmd := args[0]
+	flags := parseFlags(args[1:], []string{"-n", "-f", "--value", "--url", "--token"})
+	url, ok := flags["--url"]
+	if !ok {
+		fmt.Println("-url flag is required")
+		os.Exit(3)
+	}
+	token, ok := flags["--token"]
+	if !ok {
+		fmt.Println("-token flag is required")
+		os.Exit(3)
+	}
+
+	switch cmd {
+	case "create":
+		name, ok := flags["-n"]
+		if !ok {
+			fmt.Println("-n flag is required")
+			os.Exit(3)
+		}
+		value, hasValue := flags["--value"]
+		if file, ok := flags["-f"]; ok {
+			data, err := os.ReadFile(file)
+			checkErr(err)
+			value = string(data)
+		} else if !hasValue {
+			fmt.Println("either -f or --value flag is required")
+			os.Exit(3)
+		}
+
+		body, _ := json.Marshal(map[string]string{"name": name, "value": value})
+		req, _ := http.NewRequest("POST", url+"/api/v1/secret/create", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp := doRequest(req)
+		fmt.Println("Secret created", resp.Status)
+
+	case "ls":
+		req, _ := http.NewRequest("GET", url+"/api/v1/secret", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := doRequest(req)
+		defer resp.Body.Close()
+
+		var list []secrets.Info
+		checkErr(json.NewDecoder(resp.Body).Decode(&list))
+
+		fmt.Printf("%-30s  %-20s\n", "Name", "Created")
+		fmt.Println(strings.Repeat("-", 52))
+		for _, s := range list {
+			fmt.Printf("%-30s  %-20s\n", shorten(s.Name, 30), s.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+
+	case "rm":
+		name, ok := flags["-n"]
+		if !ok {
+			fmt.Println("-n flag is required")
+			os.Exit(3)
+		}
+		body, _ := json.Marshal(map[string]string{"name": name})
+		req, _ := http.NewRequest("POST", url+"/api/v1/secret/delete", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp := doRequest(req)
+		fmt.Println("Secret removed", resp.Status)
+
+	default:
+		fmt.Println("unknown secret subcommand")
+	}
+}
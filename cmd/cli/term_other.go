@@ -0,0 +1,25 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Raw terminals are only supported on Linux; elsewhere exec runs without a
+// TTY.
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminals are not supported on this platform")
+}
+
+func terminalSize(fd int) (width, height uint16, ok bool) {
+	return 0, 0, false
+}
+
+func notifyResize(ch chan<- os.Signal) {}
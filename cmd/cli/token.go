@@ -10,7 +10,7 @@ import (
 )
 
 func handleToken(args []string) {
-	flags := parseFlags(args, []string{"--url"})
+	flags := parseFlags(args, []string{"--url", "--scope", "--token"})
 	url, ok := flags["--url"]
 	if !ok {
 		fmt.Println("-url flag is required")
@@ -20,10 +20,17 @@ func handleToken(args []string) {
 	var pass string
 	fmt.Scanln(&pass)
 
-	body, _ := json.Marshal(map[string]string{"password": pass})
+	payload := map[string]any{"password": pass}
+	if scope, ok := flags["--scope"]; ok {
+		payload["scopes"] = []string{scope}
+	}
+	body, _ := json.Marshal(payload)
 	req, err := http.NewRequest("POST", url+"/api/v1/token", bytes.NewReader(body))
 	checkErr(err)
 	req.Header.Set("Content-Type", "application/json")
+	if token, ok := flags["--token"]; ok {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 	resp := doRequest(req)
 	data, _ := io.ReadAll(resp.Body)
 	fmt.Println(string(data))
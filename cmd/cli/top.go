@@ -0,0 +1,118 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// handleTop shows the latest resource usage of the containers, refreshed
+// until interrupted unless --once is given.
+func handleTop(args []string) {
+	flags := parseFlags(args, []string{"-m", "-h", "--interval", "--url", "--token"})
+	for _, key := range []string{"--url", "--token"} {
+		if _, ok := flags[key]; !ok {
+			fmt.Println(key, "flag is required")
+			os.Exit(3)
+		}
+	}
+	interval := parseInterval(flags)
+	once := slices.Contains(args, "--once")
+
+	q := url.Values{}
+	if m, ok := flags["-m"]; ok {
+		q.Set("manifest", m)
+	}
+	if h, ok := flags["-h"]; ok {
+		q.Set("host", h)
+	}
+	for {
+		req, _ := http.NewRequest("GET", flags["--url"]+"/api/v1/stats?"+q.Encode(), nil)
+		req.Header.Set("Authorization", "Bearer "+flags["--token"])
+		resp := doRequest(req)
+		var windows []config.StatsWindow
+		err := json.NewDecoder(resp.Body).Decode(&windows)
+		resp.Body.Close()
+		checkErr(err)
+
+		if !once {
+			// Clear the screen, like top.
+			fmt.Print("\033[H\033[2J")
+		}
+		printStats(windows)
+		if once {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// parseInterval returns the refresh interval given with --interval, 2
+// seconds by default.
+func parseInterval(flags map[string]string) time.Duration {
+	v, ok := flags["--interval"]
+	if !ok {
+		return 2 * time.Second
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		fmt.Println("invalid --interval:", v)
+		os.Exit(3)
+	}
+	return d
+}
+
+// printStats prints the latest sample of every container, busiest first,
+// along with its average CPU usage over the window kept by the master.
+func printStats(windows []config.StatsWindow) {
+	slices.SortFunc(windows, func(a, b config.StatsWindow) int {
+		return cmp.Compare(b.Samples[len(b.Samples)-1].CPUPercent, a.Samples[len(a.Samples)-1].CPUPercent)
+	})
+
+	fmt.Printf("%-10s  %-15s  %-6s  %7s  %7s  %-21s  %6s  %-21s  %s\n", "Manifest", "Name", "Host", "CPU %", "Avg %", "Mem usage / limit", "Mem %", "Net I/O", "Block I/O")
+	fmt.Println(strings.Repeat("-", 125))
+	for _, w := range windows {
+		last := w.Samples[len(w.Samples)-1]
+		var sum float64
+		for _, s := range w.Samples {
+			sum += s.CPUPercent
+		}
+		mem, memPercent := formatBytes(last.MemoryUsage), "-"
+		if last.MemoryLimit > 0 {
+			mem += " / " + formatBytes(last.MemoryLimit)
+			memPercent = fmt.Sprintf("%.1f", float64(last.MemoryUsage)/float64(last.MemoryLimit)*100)
+		}
+		fmt.Printf("%-10s  %-15s  %-6s  %7.1f  %7.1f  %-21s  %6s  %-21s  %s\n",
+			w.ManifestName,
+			shorten(w.Name, 15),
+			w.Host,
+			last.CPUPercent,
+			sum/float64(len(w.Samples)),
+			mem,
+			memPercent,
+			formatBytes(last.NetworkRx)+" / "+formatBytes(last.NetworkTx),
+			formatBytes(last.BlockRead)+" / "+formatBytes(last.BlockWrite),
+		)
+	}
+}
+
+// formatBytes rounds a size to one decimal of the largest unit that fits.
+func formatBytes(b config.ByteSize) string {
+	for _, u := range []struct {
+		size config.ByteSize
+		name string
+	}{{config.TiB, "TiB"}, {config.GiB, "GiB"}, {config.MiB, "MiB"}, {config.KiB, "KiB"}} {
+		if b >= u.size {
+			return fmt.Sprintf("%.1f%s", float64(b)/float64(u.size), u.name)
+		}
+	}
+	return fmt.Sprintf("%dB", b)
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/rmerezha/mtrpz-lab4/config"
+	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 func parseFlags(args []string, keys []string) map[string]string {
@@ -23,6 +26,18 @@ func parseFlags(args []string, keys []string) map[string]string {
 	return flags
 }
 
+// parseMultiFlag collects every value of a flag that may be repeated.
+func parseMultiFlag(args []string, key string) []string {
+	var values []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == key && i+1 < len(args) {
+			values = append(values, args[i+1])
+			i++
+		}
+	}
+	return values
+}
+
 func checkErr(err error) {
 	if err != nil {
 		fmt.Println("error:", err)
@@ -40,7 +55,9 @@ func doRequest(req *http.Request) *http.Response {
 	return resp
 }
 
-func printContainerListJSON(body []byte) {
+// printContainerListJSON prints the containers as a table, with the image
+// digests their last pulls resolved to if digests is set.
+func printContainerListJSON(body []byte, digests bool) {
 	var containers []config.ContainerStatus
 	err := json.Unmarshal(body, &containers)
 	if err != nil {
@@ -49,23 +66,112 @@ func printContainerListJSON(body []byte) {
 		return
 	}
 
-	fmt.Printf("%-3s  %-10s  %-10s  %-6s  %-15s  %-12s  %-10s\n", "#", "Manifest", "Name", "Host", "Image", "Ports", "State")
-	fmt.Println(strings.Repeat("-", 75))
+	fmt.Printf("%-3s  %-10s  %-10s  %-6s  %-15s  %-12s  %-10s  %s\n", "#", "Manifest", "Name", "Host", "Image", "Ports", "State", "Volumes")
+	fmt.Println(strings.Repeat("-", 90))
 
 	for i, c := range containers {
 		ports := "-"
-		if len(c.Config.Ports) > 0 {
+		if len(c.Endpoints) > 0 {
+			ports = strings.Join(c.Endpoints, ",")
+		} else if len(c.Config.Ports) > 0 {
 			ports = strings.Join(c.Config.Ports, ",")
 		}
-		fmt.Printf("%-3d  %-10s  %-10s  %-6s  %-15s  %-12s  %-10s\n",
+		fmt.Printf("%-3d  %-10s  %-10s  %-6s  %-15s  %-12s  %-10s  %s\n",
 			i+1,
 			c.ManifestName,
 			c.Config.Name,
 			c.Config.Host,
 			shorten(c.Config.Image, 15),
 			shorten(ports, 12),
-			c.State,
+			formatState(c),
+			formatVolumes(c.VolumeUsage),
 		)
+		if c.State == config.StatePulling && c.PullProgress != nil {
+			p := c.PullProgress
+			fmt.Printf("     pulling %s: %s / %s, %d/%d layers, %s\n", p.Image, formatBytes(p.Current), formatBytes(p.Total),
+				p.Done, p.Layers, time.Since(p.Started).Round(time.Second))
+		}
+		if digests && c.Pull != nil && c.Pull.Digest != "" {
+			fmt.Printf("     image %s resolved to %s\n", c.Pull.Image, c.Pull.Digest)
+		}
+		if c.Pull != nil && c.Pull.Error != "" {
+			fmt.Printf("     pull of %s failed: %s\n", c.Pull.Image, c.Pull.Error)
+			if c.Pull.AuthFailed {
+				fmt.Printf("     check the credentials for %s (cli registry ls)\n", config.ImageRegistry(c.Pull.Image))
+			}
+		}
+		if c.Job != nil && c.State == config.StateFailed && c.Job.Error != "" {
+			fmt.Printf("     job failed: %s\n", c.Job.Error)
+		}
+		for _, h := range c.Hooks {
+			if h.Failed() {
+				fmt.Printf("     %s hook failed: %s\n", h.Hook, formatHookFailure(h))
+			}
+		}
+	}
+}
+
+func formatHookFailure(h config.HookResult) string {
+	msg := h.Error
+	if msg == "" {
+		msg = fmt.Sprintf("exit code %d", h.ExitCode)
+	}
+	if out := strings.TrimSpace(h.Output); out != "" {
+		lines := strings.Split(out, "\n")
+		msg += ": " + shorten(lines[len(lines)-1], 60)
+	}
+	return msg
+}
+
+// formatState adds the progress of a pull, or the exit code and attempts of
+// a finished job.
+func formatState(c config.ContainerStatus) string {
+	if c.State == config.StatePulling && c.PullProgress != nil {
+		return fmt.Sprintf("%s %.0f%%", c.State, c.PullProgress.Percent())
+	}
+	if c.Job == nil || (c.State != config.StateSucceeded && c.State != config.StateFailed) {
+		return string(c.State)
+	}
+	res := fmt.Sprintf("%s(%d)", c.State, c.Job.ExitCode)
+	if c.Job.Attempts > 1 {
+		res += fmt.Sprintf(" x%d", c.Job.Attempts)
+	}
+	return res
+}
+
+func formatVolumes(usage []config.VolumeUsage) string {
+	if len(usage) == 0 {
+		return "-"
+	}
+	res := make([]string, len(usage))
+	for i, u := range usage {
+		if u.Exists {
+			res[i] = fmt.Sprintf("%s(%s)", u.Name, u.Size)
+		} else {
+			res[i] = u.Name + "(missing)"
+		}
+	}
+	return strings.Join(res, ",")
+}
+
+func printValidationErrors(w io.Writer, err error) {
+	var errs config.ValidationError
+	if !errors.As(err, &errs) {
+		fmt.Fprintln(w, "error:", err)
+		return
+	}
+
+	fmt.Fprintf(w, "manifest has %d problem(s):\n", len(errs))
+	for _, e := range errs {
+		pos := ""
+		if e.Line > 0 {
+			pos = fmt.Sprintf("%d:%d", e.Line, e.Column)
+		}
+		path := e.Path
+		if path == "" {
+			path = "-"
+		}
+		fmt.Fprintf(w, "  %-8s %-30s %s\n", pos, path, e.Message)
 	}
 }
 
@@ -6,17 +6,28 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/rmerezha/mtrpz-lab4/api"
 	"github.com/rmerezha/mtrpz-lab4/auth"
+	"github.com/rmerezha/mtrpz-lab4/configstore"
 	"github.com/rmerezha/mtrpz-lab4/planner"
+	"github.com/rmerezha/mtrpz-lab4/secrets"
 )
 
 var (
-	port      = flag.String("port", "8080", "Port to run the master server on")
-	ip        = flag.String("ip", "0.0.0.0", "IP address to bind the server to")
-	tokenFile = flag.String("token-file", "tokens.txt", "Path to the token file")
-	tokenPass = flag.String("token-pass", "", "Password required to generate new tokens")
+	port        = flag.String("port", "8080", "Port to run the master server on")
+	ip          = flag.String("ip", "0.0.0.0", "IP address to bind the server to")
+	tokenFile   = flag.String("token-file", "tokens.txt", "Path to the token file")
+	tokenPass   = flag.String("token-pass", "", "Password required to generate new tokens")
+	execPass    = flag.String("exec-pass", "", "Password required to generate tokens with the exec scope (only exec tokens can if empty)")
+	secretKey   = flag.String("secret-key-file", "master.key", "Path to the master key used to encrypt secrets (created if missing)")
+	secretDB    = flag.String("secret-file", "secrets.json", "Path to the encrypted secrets file")
+	registryDB  = flag.String("registry-file", "registries.json", "Path to the encrypted file storing registry credentials")
+	configDB    = flag.String("config-file", "configs.json", "Path to the file storing named configs")
+	auditFile   = flag.String("audit-log", "audit.log", "Path to the log of exec sessions")
+	statsWindow = flag.Duration("stats-window", 5*time.Minute, "How long the stats of a container are kept")
 )
 
 func main() {
@@ -26,6 +37,10 @@ func main() {
 		fmt.Fprintln(os.Stderr, "--token-pass must be specified")
 		os.Exit(1)
 	}
+	if *execPass == *tokenPass {
+		fmt.Fprintln(os.Stderr, "--exec-pass must differ from --token-pass")
+		os.Exit(1)
+	}
 
 	authManager, err := auth.NewManager(*tokenFile)
 	if err != nil {
@@ -37,13 +52,45 @@ func main() {
 		log.Fatalf("failed to load tokens from %s: %v", *tokenFile, err)
 	}
 
+	key, err := secrets.LoadKey(*secretKey)
+	if err != nil {
+		log.Fatalf("failed to load master key from %s: %v", *secretKey, err)
+	}
+	secretStore, err := secrets.NewStore(*secretDB, key)
+	if err != nil {
+		log.Fatalf("failed to open secrets from %s: %v", *secretDB, err)
+	}
+
+	registryStore, err := secrets.NewRegistryStore(*registryDB, key)
+	if err != nil {
+		log.Fatalf("failed to open registry credentials from %s: %v", *registryDB, err)
+	}
+
+	configStore, err := configstore.NewStore(*configDB)
+	if err != nil {
+		log.Fatalf("failed to open configs from %s: %v", *configDB, err)
+	}
+
+	auditLog, err := api.NewAuditLog(*auditFile)
+	if err != nil {
+		log.Fatalf("failed to open audit log %s: %v", *auditFile, err)
+	}
+	defer auditLog.Close()
+
 	pl := planner.NewPlanner()
+	go pl.RunScheduler(nil)
 
 	mux := http.NewServeMux()
 	server := &api.Server{
-		Planner:  pl,
-		Auth:     authManager,
-		Password: *tokenPass,
+		Planner:      pl,
+		Auth:         authManager,
+		Secrets:      secretStore,
+		Configs:      configStore,
+		Password:     *tokenPass,
+		ExecPassword: *execPass,
+		Registries:   registryStore,
+		Audit:        auditLog,
+		StatsWindow:  *statsWindow,
 	}
 	server.RegisterRoutes(mux)
 
@@ -2,9 +2,15 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/rmerezha/mtrpz-lab4/listener"
 	"github.com/rmerezha/mtrpz-lab4/runner"
+	"gopkg.in/yaml.v3"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 )
 
@@ -13,6 +19,17 @@ var (
 	host      = flag.String("host", "", "host node")
 	interval  = flag.Duration("interval", 5*time.Second, "interval")
 	token     = flag.String("token", "", "auth token")
+	dataDir   = flag.String("data-dir", "/var/lib/mtrpz-slave", "directory for files materialized for containers")
+	runtime   = flag.String("runtime", "docker", "container runtime: docker, process to run host processes without docker, or fake to simulate containers in memory")
+	stateDir  = flag.String("state-dir", "", "directory for the state of the process runtime (default <data-dir>/processes)")
+	fakeFile  = flag.String("fake-behavior", "", "YAML file scripting the fake runtime")
+
+	pullTimeout    = flag.Duration("pull-timeout", 3*time.Minute, "timeout for pulling an image, 0 for none; other containers wait while an image is pulled")
+	startTimeout   = flag.Duration("start-timeout", time.Minute, "timeout for starting a container and creating its networks and volumes, 0 for none")
+	stopTimeout    = flag.Duration("stop-timeout", time.Minute, "timeout for stopping, restarting or removing a container, 0 for none")
+	inspectTimeout = flag.Duration("inspect-timeout", 10*time.Second, "timeout for reading the state of a container, 0 for none")
+
+	statsInterval = flag.Duration("stats-interval", 10*time.Second, "interval between reports of the resource usage of containers, 0 to disable")
 )
 
 func main() {
@@ -28,19 +45,66 @@ func main() {
 		log.Println("--token must be specified")
 	}
 
-	runner, err := runner.NewDockerRunner()
+	r, err := newRunner()
 	if err != nil {
 		log.Fatal(err)
 	}
+	r = runner.WithTimeouts(r, runner.Timeouts{
+		Pull:    *pullTimeout,
+		Start:   *startTimeout,
+		Stop:    *stopTimeout,
+		Inspect: *inspectTimeout,
+	})
 	store := listener.NewContainerStateStore()
+	polling := listener.NewPollingListener(*masterUrl, *host, r, *interval, *token, store)
+	polling.DataDir = *dataDir
 	globalListener := listener.GlobalListener{
 		Listeners: []listener.Listener{
-			listener.NewPollingListener(*masterUrl, *host, runner, *interval, *token, store),
-			listener.NewStateWatcherListener(*masterUrl, *host, runner, *interval, *token, store),
+			polling,
+			listener.NewStateWatcherListener(*masterUrl, *host, r, *interval, *token, store),
+			listener.NewLogsListener(*masterUrl, *host, r, *interval, *token),
+			listener.NewExecListener(*masterUrl, *host, r, *interval, *token),
 		},
 	}
+	if *statsInterval > 0 {
+		globalListener.Listeners = append(globalListener.Listeners,
+			listener.NewStatsListener(*masterUrl, *host, r, *statsInterval, *token, store))
+	}
 
 	stopCh := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Println("slave node is stopping")
+		close(stopCh)
+	}()
 	log.Println("slave node is starting")
 	globalListener.Listen(stopCh)
 }
+
+func newRunner() (runner.Runner, error) {
+	switch *runtime {
+	case "docker":
+		return runner.NewDockerRunner()
+	case "process":
+		dir := *stateDir
+		if dir == "" {
+			dir = filepath.Join(*dataDir, "processes")
+		}
+		return runner.NewProcessRunner(dir)
+	case "fake":
+		var b runner.FakeBehavior
+		if *fakeFile != "" {
+			data, err := os.ReadFile(*fakeFile)
+			if err != nil {
+				return nil, err
+			}
+			if err := yaml.Unmarshal(data, &b); err != nil {
+				return nil, fmt.Errorf("%s: %w", *fakeFile, err)
+			}
+		}
+		return runner.NewFakeRunner(b), nil
+	}
+	return nil, fmt.Errorf("unknown runtime %q, expected docker, process or fake", *runtime)
+}
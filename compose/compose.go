@@ -0,0 +1,733 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Options control how a Compose file is converted.
+type Options struct {
+	// Name of the manifest. Defaults to the Compose project name, then to
+	// the base name of Dir.
+	Name string
+	// Host runs every service that has no entry in Hosts.
+	Host  string
+	Hosts map[string]string
+	// Dir is the directory of the Compose file; relative paths are resolved
+	// against it.
+	Dir string
+}
+
+// Warning is a Compose feature that could not be translated. Path points at
+// it in the Compose file, e.g. "services.web.healthcheck".
+type Warning struct {
+	Path    string
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Path + ": " + w.Message
+}
+
+type converter struct {
+	opts     Options
+	warnings []Warning
+	configs  map[string]*yaml.Node
+	// external maps external networks to their name on the host.
+	external map[string]string
+	networks []config.Network
+}
+
+func (c *converter) warn(path, format string, args ...any) {
+	c.warnings = append(c.warnings, Warning{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Convert translates a Compose file into a manifest. Every Compose feature
+// without an equivalent is reported as a Warning rather than dropped silently.
+func Convert(data []byte, opts Options) (*config.Manifest, []Warning, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New("compose file must be a mapping")
+	}
+	root := doc.Content[0]
+
+	c := &converter{opts: opts, configs: map[string]*yaml.Node{}, external: map[string]string{}}
+	c.checkInterpolation(root, "")
+
+	m := &config.Manifest{Name: opts.Name}
+	var services *yaml.Node
+	eachKey(root, func(key string, val *yaml.Node) {
+		switch key {
+		case "version":
+			// Obsolete, ignored by Compose itself.
+		case "name":
+			if m.Name == "" {
+				m.Name = val.Value
+			}
+		case "services":
+			services = val
+		case "configs":
+			eachKey(val, func(name string, def *yaml.Node) { c.configs[name] = def })
+		case "secrets":
+			eachKey(val, func(name string, _ *yaml.Node) {
+				c.warn("secrets."+name, "secret definitions are not converted, create it with `cli secret create -n %s`", name)
+			})
+		case "networks":
+			eachKey(val, func(name string, def *yaml.Node) { c.network("networks."+name, name, def) })
+		case "volumes":
+			eachKey(val, func(name string, def *yaml.Node) {
+				if v, ok := c.namedVolume("volumes."+name, name, def); ok {
+					m.Volumes = append(m.Volumes, v)
+				}
+			})
+		default:
+			c.warn(key, "not supported")
+		}
+	})
+	if m.Name == "" && opts.Dir != "" {
+		if abs, err := filepath.Abs(opts.Dir); err == nil {
+			m.Name = filepath.Base(abs)
+		}
+	}
+	if services == nil || len(services.Content) == 0 {
+		return nil, nil, errors.New("compose file has no services")
+	}
+
+	var missing []string
+	eachKey(services, func(name string, svc *yaml.Node) {
+		host := opts.Hosts[name]
+		if host == "" {
+			host = opts.Host
+		}
+		if host == "" {
+			missing = append(missing, name)
+		}
+		ct := c.service("services."+name, name, svc)
+		ct.Host = host
+		if ct.Network == "" && len(ct.Networks) == 0 && !hasKey(svc, "networks") {
+			// Like Compose, attach services without networks to a
+			// project-wide default network.
+			ct.Networks = []string{"default"}
+			c.declare("default")
+		}
+		m.Containers = append(m.Containers, ct)
+	})
+	m.Networks = c.networks
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("no host for services %s, use --host or --service-host", strings.Join(missing, ", "))
+	}
+	for name := range opts.Hosts {
+		if !hasKey(services, name) {
+			return nil, nil, fmt.Errorf("host given for unknown service %q", name)
+		}
+	}
+	return m, c.warnings, nil
+}
+
+func (c *converter) service(path, name string, svc *yaml.Node) config.Container {
+	ct := config.Container{Name: name}
+	eachKey(svc, func(key string, val *yaml.Node) {
+		p := path + "." + key
+		switch key {
+		case "image":
+			ct.Image = val.Value
+		case "container_name":
+			ct.Name = val.Value
+		case "command":
+			ct.Cmd = command(val)
+		case "entrypoint":
+			ct.Entrypoint = command(val)
+		case "environment":
+			ct.Environment = c.mapping(p, val, "=")
+		case "labels":
+			ct.Labels = c.mapping(p, val, "=")
+		case "extra_hosts":
+			for _, h := range sortedPairs(c.mapping(p, val, "=:")) {
+				ct.ExtraHosts = append(ct.ExtraHosts, h[0]+":"+h[1])
+			}
+		case "ports":
+			for i, item := range val.Content {
+				if spec, ok := c.port(index(p, i), item); ok {
+					ct.Ports = append(ct.Ports, spec)
+				}
+			}
+		case "volumes":
+			for i, item := range val.Content {
+				c.volume(index(p, i), item, &ct)
+			}
+		case "tmpfs":
+			for _, mount := range stringList(val) {
+				target, opts, _ := strings.Cut(mount, ":")
+				if ct.Tmpfs == nil {
+					ct.Tmpfs = map[string]string{}
+				}
+				ct.Tmpfs[target] = opts
+			}
+		case "restart":
+			ct.Restart = val.Value
+		case "pull_policy":
+			c.pullPolicy(p, val, &ct)
+		case "network_mode":
+			if strings.HasPrefix(val.Value, "service:") {
+				c.warn(p, "sharing the network of another service is not supported")
+				return
+			}
+			ct.Network = val.Value
+		case "networks":
+			c.serviceNetworks(p, val, &ct)
+		case "cap_add":
+			ct.Capabilities.Add = stringList(val)
+		case "cap_drop":
+			ct.Capabilities.Drop = stringList(val)
+		case "dns":
+			ct.DNS = stringList(val)
+		case "dns_search":
+			ct.DNSSearch = stringList(val)
+		case "user":
+			ct.User = val.Value
+		case "working_dir":
+			ct.WorkDir = val.Value
+		case "hostname":
+			ct.Hostname = val.Value
+		case "ipc":
+			ct.IPC = val.Value
+		case "read_only":
+			ct.ReadOnly = val.Value == "true"
+		case "privileged":
+			ct.Privileged = val.Value == "true"
+		case "devices":
+			ct.Devices = stringList(val)
+		case "security_opt":
+			ct.SecurityOpt = stringList(val)
+		case "ulimits":
+			c.ulimits(p, val, &ct)
+		case "mem_limit":
+			ct.Resources.Memory = c.size(p, val)
+		case "shm_size":
+			ct.Resources.ShmSize = c.size(p, val)
+		case "cpus":
+			ct.Resources.CPUs = c.cpus(p, val)
+		case "deploy":
+			c.deploy(p, val, &ct)
+		case "secrets":
+			for i, item := range val.Content {
+				c.secret(index(p, i), item, &ct)
+			}
+		case "configs":
+			for i, item := range val.Content {
+				c.config(index(p, i), item, &ct)
+			}
+		case "build":
+			c.warn(p, "images are not built, push the image to a registry and set 'image'")
+		default:
+			c.warn(p, "not supported")
+		}
+	})
+	return ct
+}
+
+func (c *converter) pullPolicy(path string, val *yaml.Node, ct *config.Container) {
+	switch val.Value {
+	case "always":
+		ct.PullPolicy = config.PullAlways
+	case "missing", "if_not_present":
+		ct.PullPolicy = config.PullIfNotPresent
+	case "never":
+		ct.PullPolicy = config.PullNever
+	case "build":
+		c.warn(path, "images are not built, push the image to a registry and set 'image'")
+	default:
+		c.warn(path, "pull policy %q is not supported", val.Value)
+	}
+}
+
+func (c *converter) port(path string, node *yaml.Node) (string, bool) {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, true
+	}
+
+	var target, published, hostIP, protocol string
+	eachKey(node, func(key string, val *yaml.Node) {
+		switch key {
+		case "target":
+			target = val.Value
+		case "published":
+			published = val.Value
+		case "host_ip":
+			hostIP = val.Value
+		case "protocol":
+			protocol = val.Value
+		case "mode":
+			if val.Value != "host" {
+				c.warn(path+".mode", "mode %q is not supported, ports are always published on the host", val.Value)
+			}
+		case "name", "app_protocol":
+			// Informational only.
+		default:
+			c.warn(path+"."+key, "not supported")
+		}
+	})
+	if target == "" {
+		c.warn(path, "port without target is skipped")
+		return "", false
+	}
+
+	spec := target
+	switch {
+	case hostIP != "":
+		if strings.Contains(hostIP, ":") {
+			hostIP = "[" + hostIP + "]"
+		}
+		spec = hostIP + ":" + published + ":" + target
+	case published != "":
+		spec = published + ":" + target
+	}
+	if protocol != "" {
+		spec += "/" + protocol
+	}
+	return spec, true
+}
+
+func (c *converter) volume(path string, node *yaml.Node, ct *config.Container) {
+	if node.Kind == yaml.ScalarNode {
+		parts := strings.Split(node.Value, ":")
+		if len(parts) == 1 {
+			c.warn(path, "anonymous volumes are not supported")
+			return
+		}
+		v := config.Volume{Source: c.source(parts[0]), Target: parts[1]}
+		if len(parts) > 2 {
+			for _, opt := range strings.Split(parts[2], ",") {
+				switch opt {
+				case "ro":
+					v.ReadOnly = true
+				case "rw":
+				default:
+					c.warn(path, "volume option %q is not supported", opt)
+				}
+			}
+		}
+		ct.Volumes = append(ct.Volumes, v)
+		return
+	}
+
+	var typ, source, target, size string
+	readOnly := false
+	eachKey(node, func(key string, val *yaml.Node) {
+		switch key {
+		case "type":
+			typ = val.Value
+		case "source":
+			source = val.Value
+		case "target":
+			target = val.Value
+		case "read_only":
+			readOnly = val.Value == "true"
+		case "tmpfs":
+			eachKey(val, func(k string, v *yaml.Node) {
+				if k == "size" {
+					size = v.Value
+					return
+				}
+				c.warn(path+".tmpfs."+k, "not supported")
+			})
+		default:
+			c.warn(path+"."+key, "not supported")
+		}
+	})
+
+	switch typ {
+	case "bind", "volume", "":
+		if source == "" {
+			c.warn(path, "anonymous volumes are not supported")
+			return
+		}
+		ct.Volumes = append(ct.Volumes, config.Volume{Source: c.source(source), Target: target, ReadOnly: readOnly})
+	case "tmpfs":
+		if ct.Tmpfs == nil {
+			ct.Tmpfs = map[string]string{}
+		}
+		if size != "" {
+			ct.Tmpfs[target] = "size=" + size
+		} else {
+			ct.Tmpfs[target] = ""
+		}
+	default:
+		c.warn(path+".type", "volume type %q is not supported", typ)
+	}
+}
+
+// source resolves relative bind mount paths against the Compose file's
+// directory, as Compose does. Named volumes are kept as they are.
+func (c *converter) source(s string) string {
+	if s == "." || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../") {
+		return filepath.Join(c.absDir(), s)
+	}
+	return s
+}
+
+func (c *converter) absDir() string {
+	dir, err := filepath.Abs(c.opts.Dir)
+	if err != nil {
+		return c.opts.Dir
+	}
+	return dir
+}
+
+func (c *converter) network(path, name string, def *yaml.Node) {
+	n := config.Network{Name: name}
+	external := false
+	eachKey(def, func(key string, val *yaml.Node) {
+		switch key {
+		case "driver":
+			n.Driver = val.Value
+		case "internal":
+			n.Internal = val.Value == "true"
+		case "labels":
+			n.Labels = c.mapping(path+".labels", val, "=")
+		case "external":
+			external = val.Value == "true"
+		case "name":
+			c.external[name] = val.Value
+		default:
+			c.warn(path+"."+key, "not supported")
+		}
+	})
+	if !external {
+		if _, ok := c.external[name]; ok {
+			delete(c.external, name)
+			c.warn(path+".name", "custom names are not supported, the network is named after the manifest")
+		}
+		c.networks = append(c.networks, n)
+		return
+	}
+	if _, ok := c.external[name]; !ok {
+		c.external[name] = name
+	}
+}
+
+// namedVolume converts a top-level volume. External volumes are not declared,
+// so that containers mount them by their plain name.
+func (c *converter) namedVolume(path, name string, def *yaml.Node) (config.NamedVolume, bool) {
+	v := config.NamedVolume{Name: name}
+	external := false
+	eachKey(def, func(key string, val *yaml.Node) {
+		switch key {
+		case "driver":
+			v.Driver = val.Value
+		case "driver_opts":
+			v.DriverOpts = c.mapping(path+".driver_opts", val, "=")
+		case "labels":
+			v.Labels = c.mapping(path+".labels", val, "=")
+		case "external":
+			external = val.Value == "true"
+		default:
+			c.warn(path+"."+key, "not supported")
+		}
+	})
+	return v, !external
+}
+
+// declare adds an implicitly used network to the manifest.
+func (c *converter) declare(name string) {
+	for _, n := range c.networks {
+		if n.Name == name {
+			return
+		}
+	}
+	c.networks = append(c.networks, config.Network{Name: name})
+}
+
+func (c *converter) serviceNetworks(path string, node *yaml.Node, ct *config.Container) {
+	var names []string
+	if node.Kind == yaml.SequenceNode {
+		names = stringList(node)
+	} else {
+		eachKey(node, func(name string, val *yaml.Node) {
+			names = append(names, name)
+			eachKey(val, func(key string, _ *yaml.Node) {
+				c.warn(path+"."+name+"."+key, "not supported")
+			})
+		})
+	}
+
+	for _, name := range names {
+		ext, isExternal := c.external[name]
+		switch {
+		case ct.Network != "":
+			c.warn(path+"."+name, "ignored, the container already uses network %q", ct.Network)
+		case !isExternal:
+			c.declare(name)
+			ct.Networks = append(ct.Networks, name)
+		case len(ct.Networks) > 0:
+			c.warn(path+"."+name, "external networks cannot be combined with manifest networks")
+		default:
+			ct.Network = ext
+		}
+	}
+}
+
+func (c *converter) ulimits(path string, node *yaml.Node, ct *config.Container) {
+	eachKey(node, func(name string, val *yaml.Node) {
+		u := config.Ulimit{Name: name}
+		if val.Kind == yaml.ScalarNode {
+			n, err := strconv.ParseInt(val.Value, 10, 64)
+			if err != nil {
+				c.warn(path+"."+name, "invalid limit %q", val.Value)
+				return
+			}
+			u.Soft, u.Hard = n, n
+		} else if err := val.Decode(&struct {
+			Soft *int64 `yaml:"soft"`
+			Hard *int64 `yaml:"hard"`
+		}{&u.Soft, &u.Hard}); err != nil {
+			c.warn(path+"."+name, "invalid limit")
+			return
+		}
+		ct.Ulimits = append(ct.Ulimits, u)
+	})
+}
+
+func (c *converter) deploy(path string, node *yaml.Node, ct *config.Container) {
+	eachKey(node, func(key string, val *yaml.Node) {
+		if key != "resources" {
+			c.warn(path+"."+key, "not supported")
+			return
+		}
+		eachKey(val, func(key string, val *yaml.Node) {
+			p := path + ".resources." + key
+			if key != "limits" {
+				c.warn(p, "not supported")
+				return
+			}
+			eachKey(val, func(key string, val *yaml.Node) {
+				switch key {
+				case "memory":
+					ct.Resources.Memory = c.size(p+".memory", val)
+				case "cpus":
+					ct.Resources.CPUs = c.cpus(p+".cpus", val)
+				default:
+					c.warn(p+"."+key, "not supported")
+				}
+			})
+		})
+	})
+}
+
+func (c *converter) secret(path string, node *yaml.Node, ct *config.Container) {
+	ref := config.SecretRef{Name: node.Value}
+	if node.Kind == yaml.MappingNode {
+		ref.Name = ""
+		eachKey(node, func(key string, val *yaml.Node) {
+			switch key {
+			case "source":
+				ref.Name = val.Value
+			case "target":
+				ref.Target = val.Value
+			case "mode":
+				ref.Mode = c.mode(path+".mode", val)
+			default:
+				c.warn(path+"."+key, "not supported")
+			}
+		})
+	}
+	if ref.Target == "" {
+		ref.Target = ref.Name
+	}
+	if !filepath.IsAbs(ref.Target) {
+		ref.Target = "/run/secrets/" + ref.Target
+	}
+	ct.Secrets = append(ct.Secrets, ref)
+}
+
+// config inlines the content of a Compose config, read from its file when
+// needed, so that the manifest does not depend on configs stored beforehand.
+func (c *converter) config(path string, node *yaml.Node, ct *config.Container) {
+	source := node.Value
+	var cf config.ConfigFile
+	if node.Kind == yaml.MappingNode {
+		eachKey(node, func(key string, val *yaml.Node) {
+			switch key {
+			case "source":
+				source = val.Value
+			case "target":
+				cf.Target = val.Value
+			case "mode":
+				cf.Mode = c.mode(path+".mode", val)
+			default:
+				c.warn(path+"."+key, "not supported")
+			}
+		})
+	}
+	if cf.Target == "" {
+		cf.Target = "/" + source
+	}
+
+	def, ok := c.configs[source]
+	if !ok {
+		c.warn(path, "config %q is not defined", source)
+		return
+	}
+	var d struct {
+		File     string `yaml:"file"`
+		Content  string `yaml:"content"`
+		External bool   `yaml:"external"`
+		Name     string `yaml:"name"`
+	}
+	_ = def.Decode(&d)
+	switch {
+	case d.Content != "":
+		cf.Content = d.Content
+	case d.File != "":
+		file := d.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(c.opts.Dir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			c.warn(path, "config %q: %v", source, err)
+			return
+		}
+		cf.Content = string(data)
+	case d.External:
+		cf.Name = source
+		if d.Name != "" {
+			cf.Name = d.Name
+		}
+	default:
+		c.warn(path, "config %q has neither file nor content", source)
+		return
+	}
+	ct.Configs = append(ct.Configs, cf)
+}
+
+func (c *converter) mapping(path string, node *yaml.Node, seps string) map[string]string {
+	res := map[string]string{}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			i := strings.IndexAny(item.Value, seps)
+			if i < 0 {
+				c.warn(path, "%q has no value, compose would take it from the shell", item.Value)
+				continue
+			}
+			res[item.Value[:i]] = item.Value[i+1:]
+		}
+	case yaml.MappingNode:
+		eachKey(node, func(key string, val *yaml.Node) {
+			if val.Tag == "!!null" {
+				c.warn(path+"."+key, "has no value, compose would take it from the shell")
+				return
+			}
+			res[key] = val.Value
+		})
+	}
+	return res
+}
+
+func (c *converter) size(path string, node *yaml.Node) config.ByteSize {
+	n, err := config.ParseByteSize(node.Value)
+	if err != nil {
+		c.warn(path, "%v", err)
+	}
+	return n
+}
+
+func (c *converter) cpus(path string, node *yaml.Node) float64 {
+	f, err := strconv.ParseFloat(node.Value, 64)
+	if err != nil {
+		c.warn(path, "invalid cpus value %q", node.Value)
+	}
+	return f
+}
+
+func (c *converter) mode(path string, node *yaml.Node) uint32 {
+	var mode uint32
+	if err := node.Decode(&mode); err != nil {
+		c.warn(path, "invalid file mode %q", node.Value)
+	}
+	return mode
+}
+
+var interpolation = regexp.MustCompile(`\$(\{[^}]*\}|[A-Za-z_][A-Za-z0-9_]*)`)
+
+// checkInterpolation reports variables, which Compose would substitute from
+// the environment and which are copied as they are.
+func (c *converter) checkInterpolation(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if v := interpolation.FindString(strings.ReplaceAll(node.Value, "$$", "")); v != "" {
+			c.warn(path, "variable %s is not substituted", v)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			c.checkInterpolation(item, index(path, i))
+		}
+	case yaml.MappingNode:
+		eachKey(node, func(key string, val *yaml.Node) {
+			if path != "" {
+				key = path + "." + key
+			}
+			c.checkInterpolation(val, key)
+		})
+	}
+}
+
+func command(node *yaml.Node) config.Command {
+	if node.Kind == yaml.SequenceNode {
+		return config.NewCommand(stringList(node)...)
+	}
+	return config.Command(node.Value)
+}
+
+// stringList returns a scalar or a list of scalars as a list.
+func stringList(node *yaml.Node) []string {
+	if node.Kind == yaml.ScalarNode {
+		return []string{node.Value}
+	}
+	res := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		res = append(res, item.Value)
+	}
+	return res
+}
+
+func sortedPairs(m map[string]string) [][2]string {
+	res := make([][2]string, 0, len(m))
+	for k, v := range m {
+		res = append(res, [2]string{k, v})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i][0] < res[j][0] })
+	return res
+}
+
+func eachKey(node *yaml.Node, fn func(key string, val *yaml.Node)) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fn(node.Content[i].Value, node.Content[i+1])
+	}
+}
+
+func hasKey(node *yaml.Node, key string) bool {
+	found := false
+	eachKey(node, func(k string, _ *yaml.Node) { found = found || k == key })
+	return found
+}
+
+func index(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
@@ -0,0 +1,233 @@
+package compose_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rmerezha/mtrpz-lab4/compose"
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+const composeFile = `
+version: "3.8"
+name: shop
+services:
+  web:
+    image: nginx:1.25
+    container_name: shop-web
+    command: ["nginx", "-g", "daemon off;"]
+    ports:
+      - "8080:80"
+      - target: 443
+        published: 8443
+        host_ip: 127.0.0.1
+        protocol: tcp
+    environment:
+      MODE: prod
+      TOKEN:
+    volumes:
+      - ./html:/usr/share/nginx/html:ro
+      - type: tmpfs
+        target: /cache
+        tmpfs:
+          size: 64m
+    restart: unless-stopped
+    networks:
+      - front
+      - back
+    configs:
+      - source: nginx
+        target: /etc/nginx/nginx.conf
+    healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost"]
+    depends_on: [db]
+  db:
+    image: postgres:16
+    pull_policy: missing
+    volumes:
+      - pgdata:/var/lib/postgresql/data
+    environment:
+      - POSTGRES_DB=shop
+    secrets:
+      - db_password
+    deploy:
+      replicas: 2
+      resources:
+        limits:
+          memory: 512m
+          cpus: "1.5"
+    ulimits:
+      nofile:
+        soft: 1024
+        hard: 2048
+  legacy:
+    image: busybox
+    networks: [outside]
+networks:
+  front:
+  back:
+    internal: true
+  outside:
+    external: true
+    name: corp-net
+volumes:
+  pgdata:
+    driver: local
+    driver_opts:
+      type: tmpfs
+configs:
+  nginx:
+    content: "worker_processes 1;"
+secrets:
+  db_password:
+    file: ./db_password.txt
+`
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	m, warnings, err := compose.Convert([]byte(composeFile), compose.Options{
+		Host:  "node1",
+		Hosts: map[string]string{"db": "node2"},
+		Dir:   dir,
+	})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if m.Name != "shop" || len(m.Containers) != 3 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+
+	web := m.Containers[0]
+	if web.Name != "shop-web" || web.Host != "node1" || web.Image != "nginx:1.25" {
+		t.Errorf("unexpected web container: %+v", web)
+	}
+	if args, _ := web.Cmd.Args(); !reflect.DeepEqual(args, []string{"nginx", "-g", "daemon off;"}) {
+		t.Errorf("unexpected cmd: %q", args)
+	}
+	if !reflect.DeepEqual([]string(web.Ports), []string{"8080:80", "127.0.0.1:8443:443/tcp"}) {
+		t.Errorf("unexpected ports: %v", web.Ports)
+	}
+	if !reflect.DeepEqual(web.Environment, map[string]string{"MODE": "prod"}) {
+		t.Errorf("unexpected environment: %v", web.Environment)
+	}
+	wantVol := config.Volume{Source: filepath.Join(dir, "html"), Target: "/usr/share/nginx/html", ReadOnly: true}
+	if len(web.Volumes) != 1 || web.Volumes[0] != wantVol {
+		t.Errorf("unexpected volumes: %+v", web.Volumes)
+	}
+	if web.Tmpfs["/cache"] != "size=64m" {
+		t.Errorf("unexpected tmpfs: %v", web.Tmpfs)
+	}
+	if web.Restart != "unless-stopped" || !reflect.DeepEqual(web.Networks, []string{"front", "back"}) {
+		t.Errorf("unexpected restart %q or networks %v", web.Restart, web.Networks)
+	}
+	if len(web.Configs) != 1 || web.Configs[0].Content != "worker_processes 1;" || web.Configs[0].Target != "/etc/nginx/nginx.conf" {
+		t.Errorf("unexpected configs: %+v", web.Configs)
+	}
+
+	db := m.Containers[1]
+	if db.Name != "db" || db.Host != "node2" || db.Environment["POSTGRES_DB"] != "shop" {
+		t.Errorf("unexpected db container: %+v", db)
+	}
+	if len(db.Secrets) != 1 || db.Secrets[0] != (config.SecretRef{Name: "db_password", Target: "/run/secrets/db_password"}) {
+		t.Errorf("unexpected secrets: %+v", db.Secrets)
+	}
+	if db.PullPolicy != config.PullIfNotPresent {
+		t.Errorf("unexpected pull policy %q", db.PullPolicy)
+	}
+	if db.Resources.Memory != 512*config.MiB || db.Resources.CPUs != 1.5 {
+		t.Errorf("unexpected resources: %+v", db.Resources)
+	}
+	if len(db.Ulimits) != 1 || db.Ulimits[0] != (config.Ulimit{Name: "nofile", Soft: 1024, Hard: 2048}) {
+		t.Errorf("unexpected ulimits: %+v", db.Ulimits)
+	}
+
+	if !reflect.DeepEqual(db.Networks, []string{"default"}) {
+		t.Errorf("expected db on the default network, got %v", db.Networks)
+	}
+	if legacy := m.Containers[2]; legacy.Network != "corp-net" || len(legacy.Networks) != 0 {
+		t.Errorf("expected legacy on the external network, got %q %v", legacy.Network, legacy.Networks)
+	}
+	wantNetworks := []config.Network{{Name: "front"}, {Name: "back", Internal: true}, {Name: "default"}}
+	if !reflect.DeepEqual(m.Networks, wantNetworks) {
+		t.Errorf("unexpected networks: %+v", m.Networks)
+	}
+
+	wantVolumes := []config.NamedVolume{{Name: "pgdata", Driver: "local", DriverOpts: map[string]string{"type": "tmpfs"}}}
+	if !reflect.DeepEqual(m.Volumes, wantVolumes) {
+		t.Errorf("unexpected volumes: %+v", m.Volumes)
+	}
+	if len(db.Volumes) != 1 || db.Volumes[0].Source != "pgdata" {
+		t.Errorf("expected db to mount pgdata, got %+v", db.Volumes)
+	}
+
+	if err := m.Validate(); err != nil {
+		t.Errorf("converted manifest is invalid: %v", err)
+	}
+
+	wantWarnings := []string{
+		"services.web.environment.TOKEN",
+		"services.web.healthcheck",
+		"services.web.depends_on",
+		"services.db.deploy.replicas",
+		"secrets.db_password",
+	}
+	got := map[string]bool{}
+	for _, w := range warnings {
+		got[w.Path] = true
+	}
+	for _, path := range wantWarnings {
+		if !got[path] {
+			t.Errorf("missing warning for %s, got %v", path, warnings)
+		}
+	}
+	if len(warnings) != len(wantWarnings) {
+		t.Errorf("expected %d warnings, got %v", len(wantWarnings), warnings)
+	}
+}
+
+func TestConvert_MissingHost(t *testing.T) {
+	data := "services:\n  a:\n    image: nginx\n  b:\n    image: redis\n"
+	_, _, err := compose.Convert([]byte(data), compose.Options{Hosts: map[string]string{"a": "node1"}})
+	if err == nil || !strings.Contains(err.Error(), "no host for services b") {
+		t.Errorf("expected missing host error, got %v", err)
+	}
+
+	_, _, err = compose.Convert([]byte(data), compose.Options{Host: "node1", Hosts: map[string]string{"c": "node2"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown service "c"`) {
+		t.Errorf("expected unknown service error, got %v", err)
+	}
+}
+
+func TestConvert_ConfigFileAndInterpolation(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte("port=80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := `
+services:
+  app:
+    image: app:${TAG}
+    configs: [app]
+configs:
+  app:
+    file: ./app.conf
+`
+	m, warnings, err := compose.Convert([]byte(data), compose.Options{Host: "node1", Dir: dir})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if m.Name != filepath.Base(dir) {
+		t.Errorf("expected name from directory, got %q", m.Name)
+	}
+	cf := m.Containers[0].Configs
+	if len(cf) != 1 || cf[0].Content != "port=80\n" || cf[0].Target != "/app" {
+		t.Errorf("unexpected configs: %+v", cf)
+	}
+	if len(warnings) != 1 || warnings[0].Path != "services.app.image" {
+		t.Errorf("expected interpolation warning, got %v", warnings)
+	}
+}
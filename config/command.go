@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Command is an entrypoint or cmd. In manifests it is either a YAML list
+// (exec form, passed verbatim) or a string split with POSIX shell quoting
+// rules. A list is stored shell-quoted so that Args returns it unchanged.
+type Command string
+
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*c = Command(node.Value)
+		return nil
+	case yaml.SequenceNode:
+		args := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: command arguments must be strings", item.Line)}}
+			}
+			args = append(args, item.Value)
+		}
+		*c = NewCommand(args...)
+		return nil
+	}
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: command must be a string or a list of strings", node.Line)}}
+}
+
+func NewCommand(args ...string) Command {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return Command(strings.Join(quoted, " "))
+}
+
+// Args splits the command into arguments. Quotes and backslashes follow the
+// POSIX shell rules; no variable, glob or command expansion is performed.
+func (c Command) Args() ([]string, error) {
+	return splitShell(string(c))
+}
+
+func splitShell(s string) ([]string, error) {
+	var (
+		args    []string
+		cur     strings.Builder
+		inWord  bool
+		inQuote rune
+	)
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch inQuote {
+		case '\'':
+			if r == '\'' {
+				inQuote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+			continue
+		case '"':
+			switch r {
+			case '"':
+				inQuote = 0
+			case '\\':
+				if i+1 < len(runes) {
+					switch next := runes[i+1]; next {
+					case '$', '`', '"', '\\':
+						cur.WriteRune(next)
+						i++
+						continue
+					case '\n':
+						i++
+						continue
+					}
+				}
+				cur.WriteRune(r)
+			default:
+				cur.WriteRune(r)
+			}
+			continue
+		}
+
+		switch r {
+		case ' ', '\t', '\n':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		case '\'', '"':
+			inQuote = r
+			inWord = true
+		case '\\':
+			if i+1 >= len(runes) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			if runes[i] != '\n' {
+				cur.WriteRune(runes[i])
+				inWord = true
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if inQuote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", inQuote)
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=,+@%", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCommand_Args(t *testing.T) {
+	tests := []struct {
+		in   Command
+		want []string
+	}{
+		{in: "", want: nil},
+		{in: "   ", want: nil},
+		{in: "/bin/bash", want: []string{"/bin/bash"}},
+		{in: `sh -c "echo hello world"`, want: []string{"sh", "-c", "echo hello world"}},
+		{in: `-c "echo hello"`, want: []string{"-c", "echo hello"}},
+		{in: `echo 'single "quoted"'`, want: []string{"echo", `single "quoted"`}},
+		{in: `echo "it's"`, want: []string{"echo", "it's"}},
+		{in: `echo 'it'\''s'`, want: []string{"echo", "it's"}},
+		{in: `echo a\ b`, want: []string{"echo", "a b"}},
+		{in: `echo "a\"b" "c\\d" "e\f" "\$HOME"`, want: []string{"echo", `a"b`, `c\d`, `e\f`, "$HOME"}},
+		{in: `echo '\n'`, want: []string{"echo", `\n`}},
+		{in: `echo "" ''`, want: []string{"echo", "", ""}},
+		{in: `pre"mid"post`, want: []string{"premidpost"}},
+		{in: "a\tb\nc", want: []string{"a", "b", "c"}},
+		{in: "a \\\nb", want: []string{"a", "b"}},
+		{in: `echo $HOME *`, want: []string{"echo", "$HOME", "*"}},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.in.Args()
+		if err != nil {
+			t.Errorf("Args(%q) unexpected error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Args(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCommand_ArgsErrors(t *testing.T) {
+	for _, in := range []Command{`echo "unterminated`, `echo 'unterminated`, `echo trailing\`, `sh -c "echo 'x"'`} {
+		if _, err := in.Args(); err == nil {
+			t.Errorf("Args(%q): expected error, got nil", in)
+		}
+	}
+}
+
+func TestNewCommand_RoundTrip(t *testing.T) {
+	args := []string{"sh", "-c", "echo 'hello' \"world\" $HOME", "", `back\slash`}
+	got, err := NewCommand(args...).Args()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("expected %q, got %q", args, got)
+	}
+}
+
+func TestCommand_UnmarshalYAML(t *testing.T) {
+	data := `
+entrypoint: ["sh", "-c"]
+cmd:
+  - echo hello world
+`
+	var c Container
+	if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entrypoint, _ := c.Entrypoint.Args()
+	if !reflect.DeepEqual(entrypoint, []string{"sh", "-c"}) {
+		t.Errorf("unexpected entrypoint %q", entrypoint)
+	}
+	cmd, _ := c.Cmd.Args()
+	if !reflect.DeepEqual(cmd, []string{"echo hello world"}) {
+		t.Errorf("unexpected cmd %q", cmd)
+	}
+
+	if err := yaml.Unmarshal([]byte("cmd: {a: b}\n"), &c); err == nil {
+		t.Error("expected error for mapping cmd")
+	}
+}
+
+func TestContainerValidation_UnbalancedQuotes(t *testing.T) {
+	c := Container{Name: "app", Host: "host1", Image: "alpine", Cmd: `sh -c "echo hi`}
+	if err := c.Validate(); err == nil {
+		t.Error("expected error for unbalanced quotes in cmd")
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/distribution/reference"
+)
+
+var (
+	containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+	envNamePattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+func validContainerName(name string) bool {
+	return containerNamePattern.MatchString(name)
+}
+
+func validEnvName(name string) bool {
+	return envNamePattern.MatchString(name)
+}
+
+func validateImage(image string) error {
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return fmt.Errorf("invalid image reference %q: %v", image, err)
+	}
+	return nil
+}
+
+// hostPort is a single port a container binds on its host. Spec is the index
+// of the entry in Container.Ports it comes from.
+type hostPort struct {
+	IP       string
+	Port     int
+	Protocol string
+	Spec     int
+}
+
+func (p hostPort) String() string {
+	return strconv.Itoa(p.Port) + "/" + p.Protocol
+}
+
+func (p hostPort) conflicts(o hostPort) bool {
+	return p.Port == o.Port && p.Protocol == o.Protocol &&
+		(p.IP == o.IP || wildcardIP(p.IP) || wildcardIP(o.IP))
+}
+
+func wildcardIP(ip string) bool {
+	return ip == "" || ip == "0.0.0.0" || ip == "::"
+}
+
+// hostPorts lists the fixed host ports the container binds. Random host ports
+// and malformed specs are skipped; the latter are reported by Validate.
+func hostPorts(c *Container) []hostPort {
+	var res []hostPort
+	for i, spec := range c.Ports {
+		mappings, err := ParsePort(spec)
+		if err != nil {
+			continue
+		}
+		for _, m := range mappings {
+			if m.HostPort == "" {
+				continue
+			}
+			start, end, err := parsePortRange(m.HostPort)
+			if err != nil {
+				continue
+			}
+			for port := start; port <= end; port++ {
+				res = append(res, hostPort{IP: m.HostIP, Port: port, Protocol: m.Protocol, Spec: i})
+			}
+		}
+	}
+	return res
+}
+
+// validateUnique reports duplicate container names and host ports bound
+// twice on the same host within the manifest.
+func (m *Manifest) validateUnique(v *validator) {
+	names := make(map[string]int, len(m.Containers))
+	for i := range m.Containers {
+		c := &m.Containers[i]
+		if c.Name == "" {
+			continue
+		}
+		if j, ok := names[c.Name]; ok {
+			v.add(index("containers", i)+".name", "duplicate container name %q, already used by containers[%d]", c.Name, j)
+			continue
+		}
+		names[c.Name] = i
+	}
+
+	for i := range m.Containers {
+		c := &m.Containers[i]
+		ports := hostPorts(c)
+		for j := 0; j < i; j++ {
+			other := &m.Containers[j]
+			if other.Host != c.Host {
+				continue
+			}
+			for _, p := range ports {
+				for _, o := range hostPorts(other) {
+					if p.conflicts(o) {
+						v.add(joinPath(index("containers", i), index("ports", p.Spec)),
+							"host port %s on %s is already bound by containers[%d]", p, c.Host, j)
+					}
+				}
+			}
+		}
+	}
+}
+
+// CheckConflicts reports containers of m whose name or host ports clash with
+// containers of other manifests on the same host. Containers of m itself
+// (which m replaces) and containers being removed are ignored.
+func CheckConflicts(m *Manifest, existing []*ContainerStatus) ValidationError {
+	var v validator
+	for i := range m.Containers {
+		c := &m.Containers[i]
+		ports := hostPorts(c)
+
+		for _, cs := range existing {
+			if cs.ManifestName == m.Name || cs.State == StateRemoving || cs.State == StateRemoved || cs.Config.Host != c.Host {
+				continue
+			}
+			if cs.Config.Name == c.Name {
+				v.add(index("containers", i)+".name", "container name %q is already used on host %s by manifest %s", c.Name, c.Host, cs.ManifestName)
+			}
+			for _, p := range ports {
+				for _, o := range hostPorts(&cs.Config) {
+					if p.conflicts(o) {
+						v.add(joinPath(index("containers", i), index("ports", p.Spec)),
+							"host port %s on %s is already bound by %s in manifest %s",
+							p, c.Host, cs.Config.Name, cs.ManifestName)
+					}
+				}
+			}
+		}
+	}
+	return v.errs
+}
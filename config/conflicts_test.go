@@ -0,0 +1,72 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifestValidate_Duplicates(t *testing.T) {
+	m := Manifest{
+		Name: "app",
+		Containers: []Container{
+			{Name: "web", Host: "node1", Image: "nginx", Ports: PortList{"8080:80"}},
+			{Name: "web", Host: "node2", Image: "nginx"},
+			{Name: "api", Host: "node1", Image: "nginx", Ports: PortList{"127.0.0.1:8080:80"}},
+			{Name: "other", Host: "node2", Image: "nginx", Ports: PortList{"8080:80"}},
+			{Name: "udp", Host: "node1", Image: "nginx", Ports: PortList{"8080:80/udp"}},
+			{Name: "range", Host: "node1", Image: "nginx", Ports: PortList{"8070-8090:80"}},
+		},
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	errs := err.(ValidationError)
+
+	want := []FieldError{
+		{Path: "containers[1].name", Message: `duplicate container name "web", already used by containers[0]`},
+		{Path: "containers[2].ports[0]", Message: "host port 8080/tcp on node1 is already bound by containers[0]"},
+		{Path: "containers[5].ports[0]", Message: "host port 8080/tcp on node1 is already bound by containers[0]"},
+		{Path: "containers[5].ports[0]", Message: "host port 8080/tcp on node1 is already bound by containers[2]"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("error %d: expected %v, got %v", i, want[i], errs[i])
+		}
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	existing := []*ContainerStatus{
+		{ManifestName: "a", Config: Container{Name: "web", Host: "node1", Ports: PortList{"80:80"}}, State: StateRunning},
+		{ManifestName: "a", Config: Container{Name: "db", Host: "node1", Ports: PortList{"5432"}}, State: StateRunning},
+		{ManifestName: "b", Config: Container{Name: "api", Host: "node1", Ports: PortList{"9000:9000"}}, State: StateRunning},
+		{ManifestName: "c", Config: Container{Name: "old", Host: "node1", Ports: PortList{"7000:7000"}}, State: StateRemoving},
+	}
+
+	m := &Manifest{
+		Name: "b",
+		Containers: []Container{
+			{Name: "web", Host: "node1", Image: "nginx"},
+			{Name: "proxy", Host: "node1", Image: "nginx", Ports: PortList{"8080:8080", "[::1]:80:80"}},
+			{Name: "api", Host: "node1", Image: "nginx", Ports: PortList{"9000:9000"}},
+			{Name: "old", Host: "node1", Image: "nginx", Ports: PortList{"7000:7000"}},
+			{Name: "db", Host: "node2", Image: "postgres", Ports: PortList{"80:80"}},
+		},
+	}
+
+	errs := CheckConflicts(m, existing)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if errs[0].Path != "containers[0].name" || !strings.Contains(errs[0].Message, "manifest a") {
+		t.Errorf("unexpected name error: %v", errs[0])
+	}
+	if errs[1].Path != "containers[1].ports[1]" || !strings.Contains(errs[1].Message, "80/tcp") {
+		t.Errorf("unexpected port error: %v", errs[1])
+	}
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a single problem found in a manifest. Path points at the
+// offending field, e.g. "containers[2].ports[0]"; Line and Column are set
+// when the manifest was decoded from YAML.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+func (e FieldError) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, " (line %d, column %d)", e.Line, e.Column)
+	}
+	return b.String()
+}
+
+// ValidationError collects every problem found in a manifest.
+type ValidationError []FieldError
+
+func (v ValidationError) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type validator struct {
+	errs ValidationError
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// merge adds err under prefix, keeping the individual entries of a nested
+// ValidationError.
+func (v *validator) merge(prefix string, err error) {
+	if err == nil {
+		return
+	}
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		v.add(prefix, "%s", err.Error())
+		return
+	}
+	for _, e := range verr {
+		e.Path = joinPath(prefix, e.Path)
+		v.errs = append(v.errs, e)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func joinPath(prefix, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	}
+	return prefix + "." + path
+}
+
+func index(field string, i int) string {
+	return field + "[" + strconv.Itoa(i) + "]"
+}
+
+// DecodeManifest decodes and validates a YAML manifest. Any problem, be it a
+// malformed value, an unknown field or a failed validation rule, is reported
+// in the returned ValidationError together with its YAML position.
+func DecodeManifest(data []byte) (*Manifest, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, ValidationError{syntaxError(err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil, ValidationError{{Message: "manifest is empty"}}
+	}
+	root := doc.Content[0]
+
+	var v validator
+	checkUnknownFields(root, manifestType, "", &v)
+
+	var manifest Manifest
+	decodeFailed := map[string]bool{}
+	if err := root.Decode(&manifest); err != nil {
+		var terr *yaml.TypeError
+		if !errors.As(err, &terr) {
+			return nil, ValidationError{syntaxError(err)}
+		}
+		for _, msg := range terr.Errors {
+			e := typeError(root, msg)
+			decodeFailed[e.Path] = true
+			v.errs = append(v.errs, e)
+		}
+	}
+
+	if err := manifest.Validate(); err != nil {
+		var verr ValidationError
+		errors.As(err, &verr)
+		for _, e := range verr {
+			// A field that failed to decode is zero and would also be
+			// reported as missing; the decode error says more.
+			if !decodeFailed[e.Path] {
+				v.errs = append(v.errs, e)
+			}
+		}
+	}
+
+	if len(v.errs) > 0 {
+		errs := Locate(root, v.errs)
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return nil, errs
+	}
+	return &manifest, nil
+}
+
+// Locate fills in the YAML position of every error that has none, using the
+// deepest node of root that lies on the error's path.
+func Locate(root *yaml.Node, errs ValidationError) ValidationError {
+	if root != nil && root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	res := make(ValidationError, len(errs))
+	for i, e := range errs {
+		if e.Line == 0 && root != nil {
+			n := nodeAt(root, e.Path)
+			e.Line, e.Column = n.Line, n.Column
+		}
+		res[i] = e
+	}
+	return res
+}
+
+var pathSegment = regexp.MustCompile(`([^.\[\]]+)|\[(\d+)\]`)
+
+func nodeAt(root *yaml.Node, path string) *yaml.Node {
+	cur := root
+	for _, m := range pathSegment.FindAllStringSubmatch(path, -1) {
+		var next *yaml.Node
+		switch {
+		case m[2] != "" && cur.Kind == yaml.SequenceNode:
+			if i, _ := strconv.Atoi(m[2]); i < len(cur.Content) {
+				next = cur.Content[i]
+			}
+		case m[1] != "" && cur.Kind == yaml.MappingNode:
+			for j := 0; j+1 < len(cur.Content); j += 2 {
+				if cur.Content[j].Value == m[1] {
+					next = cur.Content[j+1]
+					break
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		cur = next
+	}
+	return cur
+}
+
+// pathAt returns the path of the first value node found on line.
+func pathAt(node *yaml.Node, line int, path string) (string, bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			key, val := node.Content[j], node.Content[j+1]
+			child := joinPath(path, key.Value)
+			if key.Line == line {
+				return child, true
+			}
+			if p, ok := pathAt(val, line, child); ok {
+				return p, true
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := joinPath(path, index("", i))
+			if p, ok := pathAt(item, line, child); ok {
+				return p, true
+			}
+		}
+	default:
+		if node.Line == line {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+var linePrefix = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+func typeError(root *yaml.Node, msg string) FieldError {
+	m := linePrefix.FindStringSubmatch(msg)
+	if m == nil {
+		return FieldError{Message: msg}
+	}
+	line, _ := strconv.Atoi(m[1])
+	e := FieldError{Message: m[2], Line: line}
+	if p, ok := pathAt(root, line, ""); ok {
+		e.Path = p
+		e.Column = nodeAt(root, p).Column
+	}
+	return e
+}
+
+func syntaxError(err error) FieldError {
+	m := linePrefix.FindStringSubmatch(err.Error())
+	if m == nil {
+		return FieldError{Message: err.Error()}
+	}
+	line, _ := strconv.Atoi(m[1])
+	return FieldError{Message: m[2], Line: line}
+}
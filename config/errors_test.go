@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func decodeErrors(t *testing.T, data string) ValidationError {
+	t.Helper()
+	_, err := DecodeManifest([]byte(data))
+	if err == nil {
+		t.Fatal("expected validation error, got nil")
+	}
+	var errs ValidationError
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationError, got %T: %v", err, err)
+	}
+	return errs
+}
+
+func findError(errs ValidationError, path string) (FieldError, bool) {
+	for _, e := range errs {
+		if e.Path == path {
+			return e, true
+		}
+	}
+	return FieldError{}, false
+}
+
+func TestDecodeManifest_CollectsAllErrors(t *testing.T) {
+	errs := decodeErrors(t, `name: shop
+containers:
+  - name: web
+    host: host1
+    image: nginx
+    ports:
+      - "8080:80"
+      - "99999:80"
+  - name: db
+    image: postgres
+  - name: cache
+    host: host2
+    image: redis
+    restart: sometimes
+`)
+
+	want := map[string][2]int{
+		"containers[0].ports[1]": {8, 9},
+		"containers[1].host":     {9, 5},
+		"containers[2].restart":  {14, 14},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for path, pos := range want {
+		e, ok := findError(errs, path)
+		if !ok {
+			t.Errorf("expected error at %s, got %v", path, errs)
+			continue
+		}
+		if e.Line != pos[0] || e.Column != pos[1] {
+			t.Errorf("%s: expected position %d:%d, got %d:%d", path, pos[0], pos[1], e.Line, e.Column)
+		}
+	}
+}
+
+func TestDecodeManifest_UnknownFields(t *testing.T) {
+	errs := decodeErrors(t, `name: shop
+containers:
+  - name: web
+    host: host1
+    image: nginx
+    imagee: nginx:2
+    resources:
+      memroy: 1g
+    ports:
+      - target: 80
+        publish: 8080
+`)
+
+	for _, path := range []string{"containers[0].imagee", "containers[0].resources.memroy", "containers[0].ports[0].publish"} {
+		e, ok := findError(errs, path)
+		if !ok {
+			t.Errorf("expected unknown field error at %s, got %v", path, errs)
+			continue
+		}
+		if e.Message != "unknown field" || e.Line == 0 {
+			t.Errorf("%s: unexpected error %+v", path, e)
+		}
+	}
+}
+
+func TestDecodeManifest_DecodeErrorsHavePaths(t *testing.T) {
+	errs := decodeErrors(t, `name: shop
+containers:
+  - name: web
+    host: host1
+    image: nginx
+    resources:
+      memory: lots
+`)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected exactly one error, got %v", errs)
+	}
+	if errs[0].Path != "containers[0].resources.memory" || errs[0].Line != 7 {
+		t.Errorf("unexpected error %+v", errs[0])
+	}
+}
+
+func TestDecodeManifest_SyntaxError(t *testing.T) {
+	errs := decodeErrors(t, "name: shop\ncontainers:\n  - name: web\n    host host1\n    image: nginx\n")
+	if len(errs) != 1 || errs[0].Line == 0 {
+		t.Errorf("expected a single positioned syntax error, got %v", errs)
+	}
+}
+
+func TestManifestValidate_ReportsIndex(t *testing.T) {
+	m := Manifest{
+		Name: "shop",
+		Containers: []Container{
+			{Name: "web", Host: "host1", Image: "nginx"},
+			{Name: "web2", Host: "host1", Image: "nginx"},
+			{Host: "host1", Image: "nginx"},
+		},
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "containers[2].name") {
+		t.Errorf("expected error to mention containers[2].name, got %q", err)
+	}
+}
+
+func TestFieldError_Error(t *testing.T) {
+	e := FieldError{Path: "containers[0].image", Message: "field is required", Line: 3, Column: 5}
+	if got := e.Error(); got != "containers[0].image: field is required (line 3, column 5)" {
+		t.Errorf("unexpected message %q", got)
+	}
+}
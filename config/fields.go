@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	manifestType = reflect.TypeOf(Manifest{})
+	portListType = reflect.TypeOf(PortList{})
+	longPortType = reflect.TypeOf(longPort{})
+)
+
+// checkUnknownFields reports every mapping key in node that has no matching
+// yaml field in t. It follows the same shapes the custom unmarshalers accept.
+func checkUnknownFields(node *yaml.Node, t reflect.Type, path string, v *validator) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == portListType:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			if item.Kind == yaml.MappingNode {
+				checkUnknownFields(item, longPortType, joinPath(path, index("", i)), v)
+			}
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			key, val := node.Content[j], node.Content[j+1]
+			child := joinPath(path, key.Value)
+			ft, ok := fields[key.Value]
+			if !ok {
+				v.errs = append(v.errs, FieldError{
+					Path:    child,
+					Message: "unknown field",
+					Line:    key.Line,
+					Column:  key.Column,
+				})
+				continue
+			}
+			checkUnknownFields(val, ft, child, v)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkUnknownFields(item, t.Elem(), joinPath(path, index("", i)), v)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			checkUnknownFields(node.Content[j+1], t.Elem(), joinPath(path, node.Content[j].Value), v)
+		}
+	}
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
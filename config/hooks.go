@@ -0,0 +1,108 @@
+package config
+
+import "time"
+
+// Hook failure policies. A failing preStart or postStart hook aborts the
+// start unless its policy is "continue".
+const (
+	HookAbort    = "abort"
+	HookContinue = "continue"
+)
+
+// ExecHook is a command executed inside the running container.
+type ExecHook struct {
+	Command   Command       `yaml:"command"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
+	OnFailure string        `yaml:"onFailure,omitempty"`
+}
+
+// PreStartHook is a one-shot container run to completion before the
+// container starts, e.g. to apply migrations. It shares the container's
+// environment, volumes and networks; Image and Command default to the
+// container's.
+type PreStartHook struct {
+	Image       string            `yaml:"image,omitempty"`
+	Command     Command           `yaml:"command,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	Timeout     time.Duration     `yaml:"timeout,omitempty"`
+	OnFailure   string            `yaml:"onFailure,omitempty"`
+}
+
+// HookResult is the outcome of a hook run, reported by the slave. Hook
+// names the hook, e.g. "preStart[0]" or "postStart".
+type HookResult struct {
+	Hook     string
+	ExitCode int
+	Error    string `json:",omitempty"`
+	Output   string `json:",omitempty"`
+	Time     time.Time
+}
+
+func (r HookResult) Failed() bool {
+	return r.Error != "" || r.ExitCode != 0
+}
+
+// Aborts reports whether a failure of the hook aborts the start.
+func (h *ExecHook) Aborts() bool {
+	return h.OnFailure != HookContinue
+}
+
+func (h *PreStartHook) Aborts() bool {
+	return h.OnFailure != HookContinue
+}
+
+func (h *ExecHook) Validate() error {
+	var v validator
+	if h.Command == "" {
+		v.add("command", "field is required")
+	} else if _, err := h.Command.Args(); err != nil {
+		v.add("command", "%v", err)
+	}
+	validateHookPolicy(&v, h.Timeout, h.OnFailure)
+	return v.err()
+}
+
+func (h *PreStartHook) Validate() error {
+	var v validator
+	if _, err := h.Command.Args(); err != nil {
+		v.add("command", "%v", err)
+	}
+	if h.Image != "" {
+		if err := validateImage(h.Image); err != nil {
+			v.add("image", "%v", err)
+		}
+	}
+	for k := range h.Environment {
+		if !validEnvName(k) {
+			v.add("environment."+k, "invalid environment variable name %q", k)
+		}
+	}
+	validateHookPolicy(&v, h.Timeout, h.OnFailure)
+	return v.err()
+}
+
+func validateHookPolicy(v *validator, timeout time.Duration, onFailure string) {
+	if timeout < 0 {
+		v.add("timeout", "cannot be negative")
+	}
+	switch onFailure {
+	case "", HookAbort, HookContinue:
+	default:
+		v.add("onFailure", "invalid policy %q, expected %s or %s", onFailure, HookAbort, HookContinue)
+	}
+}
+
+func (c *Container) validateHooks(v *validator) {
+	for i := range c.PreStart {
+		v.merge(index("preStart", i), c.PreStart[i].Validate())
+	}
+	if c.PostStart != nil {
+		v.merge("postStart", c.PostStart.Validate())
+	}
+	if c.PreStop != nil {
+		v.merge("preStop", c.PreStop.Validate())
+		if c.PreStop.OnFailure != "" {
+			v.add("preStop.onFailure", "a failing preStop hook never prevents the stop")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContainerValidate_Hooks(t *testing.T) {
+	c := Container{
+		Name: "api", Host: "node1", Image: "shop/api",
+		PreStart: []PreStartHook{
+			{Command: "./migrate up", Timeout: 2 * time.Minute},
+			{Image: "not a ref", Command: "echo 'unterminated", OnFailure: "retry"},
+		},
+		PostStart: &ExecHook{Timeout: -time.Second},
+		PreStop:   &ExecHook{Command: "nginx -s quit", OnFailure: HookContinue},
+	}
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	paths := map[string]bool{}
+	for _, e := range err.(ValidationError) {
+		paths[e.Path] = true
+	}
+	for _, p := range []string{
+		"preStart[1].image", "preStart[1].command", "preStart[1].onFailure",
+		"postStart.command", "postStart.timeout", "preStop.onFailure",
+	} {
+		if !paths[p] {
+			t.Errorf("missing error at %s, got %v", p, err)
+		}
+	}
+	if paths["preStart[0].command"] {
+		t.Errorf("unexpected error for a valid preStart hook: %v", err)
+	}
+}
+
+func TestDecodeManifest_Hooks(t *testing.T) {
+	data := []byte(`
+name: shop
+containers:
+  - name: api
+    host: node1
+    image: shop/api
+    preStart:
+      - command: ./migrate up
+        timeout: 2m
+    postStart:
+      command: ["curl", "-f", "localhost/health"]
+      onFailure: continue
+    preStop:
+      command: sleep 5
+`)
+	m, err := DecodeManifest(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := m.Containers[0]
+	if len(c.PreStart) != 1 || c.PreStart[0].Timeout != 2*time.Minute || !c.PreStart[0].Aborts() {
+		t.Errorf("unexpected preStart: %+v", c.PreStart)
+	}
+	if c.PostStart == nil || c.PostStart.Aborts() {
+		t.Errorf("unexpected postStart: %+v", c.PostStart)
+	}
+	if c.PreStop == nil || c.PreStop.Command != "sleep 5" {
+		t.Errorf("unexpected preStop: %+v", c.PreStop)
+	}
+}
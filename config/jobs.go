@@ -0,0 +1,94 @@
+package config
+
+import "time"
+
+// Container types. A manifest's Kind is the default type of its containers.
+const (
+	KindService = "service"
+	KindJob     = "job"
+)
+
+// Job configures a container run to completion instead of kept running.
+// A failed run is retried up to Retries times; Deadline bounds all attempts
+// together.
+type Job struct {
+	Retries    int           `yaml:"retries,omitempty"`
+	Deadline   time.Duration `yaml:"deadline,omitempty"`
+	AutoRemove bool          `yaml:"autoRemove,omitempty"`
+}
+
+// JobStatus is the progress of a job as reported by the slave.
+type JobStatus struct {
+	Attempts int
+	ExitCode int
+	Error    string `json:",omitempty"`
+	Started  time.Time
+	Finished time.Time
+}
+
+// IsJob reports whether the container runs to completion.
+func (c *Container) IsJob() bool {
+	return c.Type == KindJob
+}
+
+func (j *Job) Validate() error {
+	var v validator
+	if j.Retries < 0 {
+		v.add("retries", "cannot be negative")
+	}
+	if j.Deadline < 0 {
+		v.add("deadline", "cannot be negative")
+	}
+	return v.err()
+}
+
+func (m *Manifest) validateJobs(v *validator) {
+	if !validKind(m.Kind) {
+		v.add("kind", "invalid kind %q, expected %s or %s", m.Kind, KindService, KindJob)
+	}
+	for i := range m.Containers {
+		c := m.Containers[i]
+		path := index("containers", i)
+		if !validKind(c.Type) {
+			v.add(path+".type", "invalid type %q, expected %s or %s", c.Type, KindService, KindJob)
+			continue
+		}
+		c.Type = m.containerType(&c)
+		if !c.IsJob() {
+			if c.Job != nil {
+				v.add(path+".job", "only allowed for containers of type %s", KindJob)
+			}
+			if c.Schedule != nil {
+				v.add(path+".schedule", "only allowed for containers of type %s", KindJob)
+			}
+			continue
+		}
+		// Option errors are reported by Container.Validate.
+		_ = c.Normalize()
+		if c.Restart != "" && c.Restart != "no" {
+			v.add(path+".restart", "jobs are never restarted by docker, use job.retries")
+		}
+		if c.Job != nil {
+			v.merge(path+".job", c.Job.Validate())
+		}
+		if c.Schedule != nil {
+			v.merge(path+".schedule", c.Schedule.Validate())
+		}
+	}
+}
+
+// containerType is the type of c, which defaults to job for a scheduled
+// container and to the manifest kind otherwise.
+func (m *Manifest) containerType(c *Container) string {
+	switch {
+	case c.Type != "":
+		return c.Type
+	case c.Schedule != nil:
+		return KindJob
+	}
+	return m.Kind
+}
+
+func validKind(kind string) bool {
+	return kind == "" || kind == KindService || kind == KindJob
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestValidate_Jobs(t *testing.T) {
+	m := Manifest{
+		Name: "batch",
+		Kind: KindJob,
+		Containers: []Container{
+			{Name: "report", Host: "node1", Image: "busybox", Job: &Job{Retries: 2, Deadline: time.Hour}},
+			{Name: "import", Host: "node1", Image: "busybox", Restart: "always", Job: &Job{Retries: -1}},
+			{Name: "legacy", Host: "node1", Image: "busybox", Options: []string{"--restart=on-failure"}},
+			{Name: "web", Host: "node1", Image: "nginx", Type: KindService, Job: &Job{}},
+			{Name: "cron", Host: "node1", Image: "busybox", Type: "cronjob"},
+		},
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	paths := map[string]bool{}
+	for _, e := range err.(ValidationError) {
+		paths[e.Path] = true
+	}
+	for _, p := range []string{
+		"containers[1].restart", "containers[1].job.retries", "containers[2].restart",
+		"containers[3].job", "containers[4].type",
+	} {
+		if !paths[p] {
+			t.Errorf("missing error at %s, got %v", p, err)
+		}
+	}
+	for p := range paths {
+		if strings.HasPrefix(p, "containers[0]") {
+			t.Errorf("unexpected error for a valid job: %v", err)
+		}
+	}
+
+	m.Kind = "batch"
+	if err := m.Validate(); err == nil || !hasPath(err, "kind") {
+		t.Errorf("expected error at kind, got %v", err)
+	}
+}
+
+func TestManifestNormalize_Kind(t *testing.T) {
+	m := Manifest{
+		Name: "batch",
+		Kind: KindJob,
+		Containers: []Container{
+			{Name: "report", Host: "node1", Image: "busybox"},
+			{Name: "web", Host: "node1", Image: "nginx", Type: KindService},
+		},
+	}
+	if err := m.Normalize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !m.Containers[0].IsJob() || m.Containers[1].IsJob() {
+		t.Errorf("unexpected types: %q, %q", m.Containers[0].Type, m.Containers[1].Type)
+	}
+}
+
+func hasPath(err error, path string) bool {
+	for _, e := range err.(ValidationError) {
+		if e.Path == path {
+			return true
+		}
+	}
+	return false
+}
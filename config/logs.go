@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// LogRequest asks the slave hosting a container for its logs on behalf of a
+// client of the master.
+type LogRequest struct {
+	ID        string
+	Container string
+	// Follow keeps the stream open for the output written later.
+	Follow bool
+	// Since skips the output written before it unless it is zero.
+	Since time.Time
+	// Tail limits the output to its last lines unless it is zero.
+	Tail int
+}
+
+// ExecRequest asks the slave hosting a container to run an interactive
+// command in it on behalf of a client of the master.
+type ExecRequest struct {
+	ID        string
+	Container string
+	Cmd       []string
+	TTY       bool
+}
@@ -1,25 +1,81 @@
 package config
 
 import (
-	"errors"
-	"gopkg.in/yaml.v3"
 	"os"
+	"path"
 )
 
 type Manifest struct {
-	Name       string      `yaml:"name"`
-	Containers []Container `yaml:"containers"`
+	Name       string        `yaml:"name"`
+	Kind       string        `yaml:"kind,omitempty"`
+	PinDigests bool          `yaml:"pinDigests,omitempty"`
+	Networks   []Network     `yaml:"networks,omitempty"`
+	Volumes    []NamedVolume `yaml:"volumes,omitempty"`
+	Containers []Container   `yaml:"containers"`
 }
 
 type Container struct {
 	Name        string            `yaml:"name"`
 	Host        string            `yaml:"host"`
 	Image       string            `yaml:"image"`
-	Entrypoint  string            `yaml:"entrypoint,omitempty"`
-	Cmd         string            `yaml:"cmd,omitempty"`
-	Ports       []string          `yaml:"ports,omitempty"`
+	PullPolicy  string            `yaml:"pullPolicy,omitempty"`
+	Entrypoint  Command           `yaml:"entrypoint,omitempty"`
+	Cmd         Command           `yaml:"cmd,omitempty"`
+	Ports       PortList          `yaml:"ports,omitempty"`
 	Environment map[string]string `yaml:"environment,omitempty"`
-	Options     []string          `yaml:"options,omitempty"`
+	Secrets     []SecretRef       `yaml:"secrets,omitempty"`
+	Configs     []ConfigFile      `yaml:"configs,omitempty"`
+
+	Volumes      []Volume          `yaml:"volumes,omitempty"`
+	Resources    Resources         `yaml:"resources,omitempty"`
+	Restart      string            `yaml:"restart,omitempty"`
+	Network      string            `yaml:"network,omitempty"`
+	Networks     []string          `yaml:"networks,omitempty"`
+	Capabilities Capabilities      `yaml:"capabilities,omitempty"`
+	Ulimits      []Ulimit          `yaml:"ulimits,omitempty"`
+	DNS          []string          `yaml:"dns,omitempty"`
+	DNSSearch    []string          `yaml:"dnsSearch,omitempty"`
+	Labels       map[string]string `yaml:"labels,omitempty"`
+	User         string            `yaml:"user,omitempty"`
+	WorkDir      string            `yaml:"workdir,omitempty"`
+	ReadOnly     bool              `yaml:"readOnly,omitempty"`
+	Privileged   bool              `yaml:"privileged,omitempty"`
+	Hostname     string            `yaml:"hostname,omitempty"`
+	ExtraHosts   []string          `yaml:"extraHosts,omitempty"`
+	Devices      []string          `yaml:"devices,omitempty"`
+	Tmpfs        map[string]string `yaml:"tmpfs,omitempty"`
+	SecurityOpt  []string          `yaml:"securityOpt,omitempty"`
+	IPC          string            `yaml:"ipc,omitempty"`
+
+	Type     string    `yaml:"type,omitempty"`
+	Job      *Job      `yaml:"job,omitempty"`
+	Schedule *Schedule `yaml:"schedule,omitempty"`
+
+	PreStart  []PreStartHook `yaml:"preStart,omitempty"`
+	PostStart *ExecHook      `yaml:"postStart,omitempty"`
+	PreStop   *ExecHook      `yaml:"preStop,omitempty"`
+
+	// Options is the legacy docker-CLI-like list of flags. It is still
+	// accepted and translated into the typed fields above by Normalize.
+	Options []string `yaml:"options,omitempty"`
+}
+
+// SecretRef points at a secret stored on the master. The value is exposed to
+// the container either as the Env variable or as a file mounted at Target.
+type SecretRef struct {
+	Name   string `yaml:"name"`
+	Env    string `yaml:"env,omitempty"`
+	Target string `yaml:"target,omitempty"`
+	Mode   uint32 `yaml:"mode,omitempty"`
+}
+
+// ConfigFile is a small file mounted into the container at Target. Its
+// content is either inline or taken from a named config stored on the master.
+type ConfigFile struct {
+	Name    string `yaml:"name,omitempty"`
+	Content string `yaml:"content,omitempty"`
+	Target  string `yaml:"target"`
+	Mode    uint32 `yaml:"mode,omitempty"`
 }
 
 func ParseManifest(filename string) (*Manifest, error) {
@@ -28,45 +84,104 @@ func ParseManifest(filename string) (*Manifest, error) {
 		return nil, err
 	}
 
-	var manifest Manifest
-	if err := yaml.Unmarshal(data, &manifest); err != nil {
-		return nil, err
-	}
-
-	if err := manifest.Validate(); err != nil {
-		return nil, err
-	}
-
-	return &manifest, nil
+	return DecodeManifest(data)
 }
 
+// Validate checks the whole manifest and returns a ValidationError listing
+// every problem found, or nil.
 func (m *Manifest) Validate() error {
+	var v validator
 	if m.Name == "" {
-		return errors.New("manifest: 'name' field is required")
+		v.add("name", "field is required")
 	}
 	if len(m.Containers) == 0 {
-		return errors.New("manifest: 'containers' field is required and cannot be empty")
+		v.add("containers", "field is required and cannot be empty")
 	}
-	for i, c := range m.Containers {
-		if err := c.Validate(); err != nil {
-			return errors.New("container[" + c.Name + "]: " + err.Error())
-		}
-		if c.Name == "" {
-			return errors.New("container[" + string(rune(i)) + "]: 'name' field is required")
-		}
+	for i := range m.Containers {
+		v.merge(index("containers", i), m.Containers[i].Validate())
 	}
-	return nil
+	m.validateUnique(&v)
+	m.validateNetworks(&v)
+	m.validateVolumes(&v)
+	m.validateJobs(&v)
+	return v.err()
 }
 
 func (c *Container) Validate() error {
+	var v validator
 	if c.Name == "" {
-		return errors.New("'name' field is required")
+		v.add("name", "field is required")
+	} else if !validContainerName(c.Name) {
+		v.add("name", "invalid container name %q, must match [a-zA-Z0-9][a-zA-Z0-9_.-]+", c.Name)
 	}
 	if c.Host == "" {
-		return errors.New("'host' field is required")
+		v.add("host", "field is required")
 	}
 	if c.Image == "" {
-		return errors.New("'image' field is required")
+		v.add("image", "field is required")
+	} else if err := validateImage(c.Image); err != nil {
+		v.add("image", "%v", err)
+	}
+	for k := range c.Environment {
+		if !validEnvName(k) {
+			v.add("environment."+k, "invalid environment variable name %q", k)
+		}
+	}
+	if _, err := c.Entrypoint.Args(); err != nil {
+		v.add("entrypoint", "%v", err)
+	}
+	if _, err := c.Cmd.Args(); err != nil {
+		v.add("cmd", "%v", err)
+	}
+	for i := range c.Secrets {
+		v.merge(index("secrets", i), c.Secrets[i].Validate())
+	}
+	for i := range c.Configs {
+		v.merge(index("configs", i), c.Configs[i].Validate())
+	}
+	c.validateHooks(&v)
+
+	spec := *c
+	if len(c.Options) > 0 {
+		for i, opt := range c.Options {
+			flag, val := splitOption(opt)
+			if err := spec.applyOption(flag, val); err != nil {
+				v.add(index("options", i), "%v", err)
+			}
+		}
+		spec.Options = nil
+	}
+	spec.validateSpec(&v)
+
+	return v.err()
+}
+
+func (s *SecretRef) Validate() error {
+	var v validator
+	if s.Name == "" {
+		v.add("name", "field is required")
+	}
+	if (s.Env == "") == (s.Target == "") {
+		v.add("", "exactly one of 'env' or 'target' must be set")
+	}
+	if s.Env != "" && !validEnvName(s.Env) {
+		v.add("env", "invalid environment variable name %q", s.Env)
+	}
+	if s.Target != "" && !path.IsAbs(s.Target) {
+		v.add("target", "must be an absolute path")
+	}
+	return v.err()
+}
+
+func (f *ConfigFile) Validate() error {
+	var v validator
+	if (f.Name == "") == (f.Content == "") {
+		v.add("", "exactly one of 'name' or 'content' must be set")
+	}
+	if f.Target == "" {
+		v.add("target", "field is required")
+	} else if !path.IsAbs(f.Target) {
+		v.add("target", "must be an absolute path")
 	}
-	return nil
+	return v.err()
 }
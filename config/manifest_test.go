@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -150,6 +151,61 @@ func TestContainerValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "dot-dot name",
+			container: Container{
+				Name:  "..",
+				Host:  "host1",
+				Image: "nginx",
+			},
+			wantErr: true,
+		},
+		{
+			name: "name with separator",
+			container: Container{
+				Name:  "../app",
+				Host:  "host1",
+				Image: "nginx",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid name",
+			container: Container{
+				Name:  "-app",
+				Host:  "host1",
+				Image: "nginx",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed image",
+			container: Container{
+				Name:  "app",
+				Host:  "host1",
+				Image: "Nginx:latest",
+			},
+			wantErr: true,
+		},
+		{
+			name: "image with registry and digest",
+			container: Container{
+				Name:  "app",
+				Host:  "host1",
+				Image: "registry.local:5000/team/app@sha256:" + strings.Repeat("a", 64),
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid env name",
+			container: Container{
+				Name:        "app",
+				Host:        "host1",
+				Image:       "nginx",
+				Environment: map[string]string{"1BAD": "x"},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -231,3 +287,47 @@ containers:
 		t.Fatal("expected permission denied error, got nil")
 	}
 }
+
+func TestSecretRefValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     SecretRef
+		wantErr bool
+	}{
+		{name: "env", ref: SecretRef{Name: "db", Env: "DB_PASSWORD"}, wantErr: false},
+		{name: "file", ref: SecretRef{Name: "db", Target: "/run/secrets/db"}, wantErr: false},
+		{name: "missing name", ref: SecretRef{Env: "DB_PASSWORD"}, wantErr: true},
+		{name: "no target", ref: SecretRef{Name: "db"}, wantErr: true},
+		{name: "env and target", ref: SecretRef{Name: "db", Env: "X", Target: "/x"}, wantErr: true},
+		{name: "relative target", ref: SecretRef{Name: "db", Target: "run/secrets/db"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.ref.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestConfigFileValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    ConfigFile
+		wantErr bool
+	}{
+		{name: "inline", file: ConfigFile{Content: "a=1", Target: "/etc/app.ini"}, wantErr: false},
+		{name: "named", file: ConfigFile{Name: "app", Target: "/etc/app.ini"}, wantErr: false},
+		{name: "both", file: ConfigFile{Name: "app", Content: "a=1", Target: "/etc/app.ini"}, wantErr: true},
+		{name: "neither", file: ConfigFile{Target: "/etc/app.ini"}, wantErr: true},
+		{name: "missing target", file: ConfigFile{Name: "app"}, wantErr: true},
+		{name: "relative target", file: ConfigFile{Name: "app", Target: "etc/app.ini"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.file.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MergeYAML deep-merges manifest documents in order, each one patching the
+// result of the previous ones:
+//
+//   - mappings are merged key by key, and a null value removes the key;
+//   - the top-level "containers" list is merged by container name: a
+//     container with a known name is patched, an unknown one is appended;
+//   - every other list and every scalar is replaced as a whole.
+func MergeYAML(docs ...[]byte) ([]byte, error) {
+	var merged *yaml.Node
+	for i, doc := range docs {
+		node, err := parseDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if merged, err = mergeNodes(merged, node, ""); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+	}
+	if merged == nil {
+		return nil, errors.New("nothing to merge")
+	}
+	return yaml.Marshal(merged)
+}
+
+// LoadManifestFiles renders the manifest described by files, merged in order
+// with MergeYAML. A file may list other files under a top-level "include"
+// key; paths are relative to the including file and are merged before it.
+func LoadManifestFiles(files ...string) ([]byte, error) {
+	var merged *yaml.Node
+	for _, f := range files {
+		node, err := loadWithIncludes(f, nil)
+		if err != nil {
+			return nil, err
+		}
+		if merged, err = mergeNodes(merged, node, ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+	}
+	if merged == nil {
+		return nil, errors.New("no manifest files given")
+	}
+	return yaml.Marshal(merged)
+}
+
+func loadWithIncludes(file string, stack []string) (*yaml.Node, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range stack {
+		if f == abs {
+			return nil, fmt.Errorf("include cycle: %s", file)
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	node, err := parseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	includes, err := takeIncludes(node)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(includes) == 0 {
+		// Returned as is, so that null deletions still apply to the files
+		// this one is merged onto.
+		return node, nil
+	}
+
+	var merged *yaml.Node
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(file), inc)
+		}
+		incNode, err := loadWithIncludes(inc, stack)
+		if err != nil {
+			return nil, err
+		}
+		if merged, err = mergeNodes(merged, incNode, ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", inc, err)
+		}
+	}
+	if merged, err = mergeNodes(merged, node, ""); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return merged, nil
+}
+
+func parseDocument(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, errors.New("empty document")
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: manifest must be a mapping", root.Line)
+	}
+	return root, nil
+}
+
+// takeIncludes removes the top-level "include" key from node and returns its
+// paths.
+func takeIncludes(node *yaml.Node) ([]string, error) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "include" {
+			continue
+		}
+		var includes []string
+		val := node.Content[i+1]
+		if val.Kind == yaml.ScalarNode {
+			includes = []string{val.Value}
+		} else if err := val.Decode(&includes); err != nil {
+			return nil, fmt.Errorf("line %d: 'include' must be a path or a list of paths", val.Line)
+		}
+		node.Content = append(node.Content[:i], node.Content[i+2:]...)
+		return includes, nil
+	}
+	return nil, nil
+}
+
+func mergeNodes(base, overlay *yaml.Node, path string) (*yaml.Node, error) {
+	if isNull(overlay) {
+		return nil, nil
+	}
+	if base == nil {
+		return stripNulls(overlay), nil
+	}
+
+	switch {
+	case base.Kind == yaml.MappingNode && overlay.Kind == yaml.MappingNode:
+		return mergeMappings(base, overlay, path)
+	case path == "containers" && base.Kind == yaml.SequenceNode && overlay.Kind == yaml.SequenceNode:
+		return mergeContainers(base, overlay)
+	}
+	return stripNulls(overlay), nil
+}
+
+func mergeMappings(base, overlay *yaml.Node, path string) (*yaml.Node, error) {
+	res := *base
+	res.Content = append([]*yaml.Node(nil), base.Content...)
+
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, val := overlay.Content[i], overlay.Content[i+1]
+		childPath := key.Value
+		if path != "" {
+			childPath = path + "." + key.Value
+		}
+
+		idx := mappingIndex(&res, key.Value)
+		if idx < 0 {
+			if !isNull(val) {
+				res.Content = append(res.Content, key, stripNulls(val))
+			}
+			continue
+		}
+
+		merged, err := mergeNodes(res.Content[idx+1], val, childPath)
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			res.Content = append(res.Content[:idx], res.Content[idx+2:]...)
+		} else {
+			res.Content[idx+1] = merged
+		}
+	}
+	return &res, nil
+}
+
+func mergeContainers(base, overlay *yaml.Node) (*yaml.Node, error) {
+	res := *base
+	res.Content = append([]*yaml.Node(nil), base.Content...)
+
+	for _, item := range overlay.Content {
+		name := containerName(item)
+		if name == "" {
+			return nil, fmt.Errorf("line %d: overlay container must have a 'name'", item.Line)
+		}
+
+		found := false
+		for i, existing := range res.Content {
+			if containerName(existing) != name {
+				continue
+			}
+			merged, err := mergeNodes(existing, item, "containers[]")
+			if err != nil {
+				return nil, err
+			}
+			res.Content[i] = merged
+			found = true
+			break
+		}
+		if !found {
+			res.Content = append(res.Content, stripNulls(item))
+		}
+	}
+	return &res, nil
+}
+
+func containerName(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	if idx := mappingIndex(node, "name"); idx >= 0 {
+		return node.Content[idx+1].Value
+	}
+	return ""
+}
+
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// stripNulls drops null-valued keys from mappings so that a deletion in an
+// overlay never leaks into the result when there was nothing to delete.
+func stripNulls(node *yaml.Node) *yaml.Node {
+	if node.Kind != yaml.MappingNode && node.Kind != yaml.SequenceNode {
+		return node
+	}
+	res := *node
+	res.Content = make([]*yaml.Node, 0, len(node.Content))
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			res.Content = append(res.Content, stripNulls(item))
+		}
+		return &res
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isNull(node.Content[i+1]) {
+			res.Content = append(res.Content, node.Content[i], stripNulls(node.Content[i+1]))
+		}
+	}
+	return &res
+}
+
+func isNull(node *yaml.Node) bool {
+	return node != nil && node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
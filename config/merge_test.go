@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const mergeBase = `
+name: shop
+containers:
+  - name: web
+    host: host1
+    image: shop/web:1.0
+    ports:
+      - "8080:80"
+      - "8443:443"
+    environment:
+      LOG_LEVEL: info
+      DEBUG: "true"
+  - name: db
+    host: host2
+    image: postgres:15
+`
+
+func mergeManifest(t *testing.T, docs ...string) Manifest {
+	t.Helper()
+	in := make([][]byte, len(docs))
+	for i, d := range docs {
+		in[i] = []byte(d)
+	}
+	out, err := MergeYAML(in...)
+	if err != nil {
+		t.Fatalf("unexpected merge error: %v", err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(out, &m); err != nil {
+		t.Fatalf("merged output is not a manifest: %v\n%s", err, out)
+	}
+	return m
+}
+
+func TestMergeYAML_PatchesContainerByName(t *testing.T) {
+	m := mergeManifest(t, mergeBase, `
+containers:
+  - name: web
+    image: shop/web:2.0
+    host: prod1
+`)
+
+	if len(m.Containers) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(m.Containers))
+	}
+	web := m.Containers[0]
+	if web.Image != "shop/web:2.0" || web.Host != "prod1" {
+		t.Errorf("expected patched image and host, got %q on %q", web.Image, web.Host)
+	}
+	if len(web.Ports) != 2 {
+		t.Errorf("expected untouched ports to be kept, got %v", web.Ports)
+	}
+	if m.Containers[1].Image != "postgres:15" {
+		t.Errorf("expected db to be untouched, got %q", m.Containers[1].Image)
+	}
+}
+
+func TestMergeYAML_MapsMergeKeyByKey(t *testing.T) {
+	m := mergeManifest(t, mergeBase, `
+containers:
+  - name: web
+    environment:
+      LOG_LEVEL: warn
+      REGION: eu
+      DEBUG: null
+`)
+
+	want := map[string]string{"LOG_LEVEL": "warn", "REGION": "eu"}
+	if !reflect.DeepEqual(m.Containers[0].Environment, want) {
+		t.Errorf("expected environment %v, got %v", want, m.Containers[0].Environment)
+	}
+}
+
+func TestMergeYAML_ListsAreReplaced(t *testing.T) {
+	m := mergeManifest(t, mergeBase, `
+containers:
+  - name: web
+    ports:
+      - "80:80"
+`)
+
+	if !reflect.DeepEqual(m.Containers[0].Ports, PortList{"80:80"}) {
+		t.Errorf("expected ports to be replaced, got %v", m.Containers[0].Ports)
+	}
+}
+
+func TestMergeYAML_UnknownContainerIsAppended(t *testing.T) {
+	m := mergeManifest(t, mergeBase, `
+containers:
+  - name: cache
+    host: host2
+    image: redis
+`)
+
+	if len(m.Containers) != 3 || m.Containers[2].Name != "cache" {
+		t.Errorf("expected cache to be appended, got %+v", m.Containers)
+	}
+}
+
+func TestMergeYAML_LaterOverlaysWin(t *testing.T) {
+	m := mergeManifest(t, mergeBase,
+		"containers:\n  - name: web\n    image: a\n",
+		"name: shop-prod\ncontainers:\n  - name: web\n    image: b\n",
+	)
+
+	if m.Name != "shop-prod" || m.Containers[0].Image != "b" {
+		t.Errorf("expected last overlay to win, got name %q image %q", m.Name, m.Containers[0].Image)
+	}
+}
+
+func TestMergeYAML_OverlayContainerWithoutName(t *testing.T) {
+	_, err := MergeYAML([]byte(mergeBase), []byte("containers:\n  - image: x\n"))
+	if err == nil {
+		t.Error("expected error for overlay container without name")
+	}
+}
+
+func TestLoadManifestFiles_Includes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return p
+	}
+
+	write("base.yaml", mergeBase)
+	prod := write("prod.yaml", "include: base.yaml\ncontainers:\n  - name: web\n    image: shop/web:3.0\n")
+	extra := write("extra.yaml", "containers:\n  - name: db\n    host: host3\n  - name: web\n    environment:\n      DEBUG: null\n")
+
+	out, err := LoadManifestFiles(prod, extra)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(out), "include") {
+		t.Errorf("expected include key to be removed, got\n%s", out)
+	}
+
+	var m Manifest
+	if err := yaml.Unmarshal(out, &m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Containers[0].Image != "shop/web:3.0" || m.Containers[1].Host != "host3" {
+		t.Errorf("unexpected merge result %+v", m.Containers)
+	}
+	if _, ok := m.Containers[0].Environment["DEBUG"]; ok {
+		t.Errorf("expected DEBUG to be removed by the second file, got %v", m.Containers[0].Environment)
+	}
+}
+
+func TestLoadManifestFiles_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	os.WriteFile(a, []byte("include: b.yaml\nname: a\n"), 0644)
+	os.WriteFile(b, []byte("include: a.yaml\nname: b\n"), 0644)
+
+	if _, err := LoadManifestFiles(a); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+// Labels set on docker objects owned by a manifest.
+const (
+	LabelManifest = "mtrpz.manifest"
+	LabelNetwork  = "mtrpz.network"
+)
+
+// Network is a network declared by a manifest. The slave creates it on
+// demand and attaches the containers listing it, with their name as DNS
+// alias. It is removed once the manifest goes down.
+type Network struct {
+	Name     string            `yaml:"name"`
+	Driver   string            `yaml:"driver,omitempty"`
+	Internal bool              `yaml:"internal,omitempty"`
+	Labels   map[string]string `yaml:"labels,omitempty"`
+}
+
+func (n *Network) Validate() error {
+	var v validator
+	if n.Name == "" {
+		v.add("name", "field is required")
+	} else if !validContainerName(n.Name) {
+		v.add("name", "invalid network name %q, must match [a-zA-Z0-9][a-zA-Z0-9_.-]+", n.Name)
+	}
+	return v.err()
+}
+
+// NetworkName returns the docker name of the network declared as name by
+// manifest, so that manifests never share networks by accident.
+func NetworkName(manifest, name string) string {
+	return manifest + "_" + name
+}
+
+// ContainerNetworks returns the networks c is attached to, named and
+// labelled as they are created on the host.
+func (m *Manifest) ContainerNetworks(c *Container) []Network {
+	var res []Network
+	for _, name := range c.Networks {
+		for _, n := range m.Networks {
+			if n.Name != name {
+				continue
+			}
+			labels := make(map[string]string, len(n.Labels)+2)
+			for k, v := range n.Labels {
+				labels[k] = v
+			}
+			labels[LabelManifest] = m.Name
+			labels[LabelNetwork] = n.Name
+			res = append(res, Network{
+				Name:     NetworkName(m.Name, n.Name),
+				Driver:   n.Driver,
+				Internal: n.Internal,
+				Labels:   labels,
+			})
+		}
+	}
+	return res
+}
+
+func (m *Manifest) validateNetworks(v *validator) {
+	declared := make(map[string]bool, len(m.Networks))
+	for i := range m.Networks {
+		n := &m.Networks[i]
+		v.merge(index("networks", i), n.Validate())
+		if declared[n.Name] {
+			v.add(index("networks", i)+".name", "duplicate network name %q", n.Name)
+		}
+		declared[n.Name] = true
+	}
+
+	for i := range m.Containers {
+		c := &m.Containers[i]
+		for j, name := range c.Networks {
+			if !declared[name] {
+				v.add(joinPath(index("containers", i), index("networks", j)), "network %q is not declared in the manifest", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifestValidate_Networks(t *testing.T) {
+	m := Manifest{
+		Name: "shop",
+		Networks: []Network{
+			{Name: "front"},
+			{Name: "front"},
+			{Name: "-bad"},
+		},
+		Containers: []Container{
+			{Name: "web", Host: "node1", Image: "nginx", Networks: []string{"front", "back"}},
+			{Name: "api", Host: "node1", Image: "nginx", Network: "host", Networks: []string{"front"}},
+		},
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	paths := map[string]bool{}
+	for _, e := range err.(ValidationError) {
+		paths[e.Path] = true
+	}
+	for _, p := range []string{"networks[1].name", "networks[2].name", "containers[0].networks[1]", "containers[1].networks"} {
+		if !paths[p] {
+			t.Errorf("missing error at %s, got %v", p, err)
+		}
+	}
+}
+
+func TestContainerNetworks(t *testing.T) {
+	m := Manifest{
+		Name: "shop",
+		Networks: []Network{
+			{Name: "front", Driver: "bridge", Labels: map[string]string{"team": "web"}},
+			{Name: "back", Internal: true},
+		},
+	}
+	c := Container{Name: "web", Networks: []string{"back", "front"}}
+
+	want := []Network{
+		{Name: "shop_back", Internal: true, Labels: map[string]string{LabelManifest: "shop", LabelNetwork: "back"}},
+		{Name: "shop_front", Driver: "bridge", Labels: map[string]string{"team": "web", LabelManifest: "shop", LabelNetwork: "front"}},
+	}
+	if got := m.ContainerNetworks(&c); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if len(m.Networks[0].Labels) != 1 {
+		t.Error("ContainerNetworks must not modify the manifest")
+	}
+}
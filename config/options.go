@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// supportedOptions lists the flags applyOption understands.
+var supportedOptions = []string{
+	"--privileged", "--read-only", "--net", "--network", "--restart", "--pull",
+	"-v", "--volume", "--memory", "-m", "--cpus", "--shm-size",
+	"--add-host", "--device", "--tmpfs", "--hostname", "-h",
+	"--cap-add", "--cap-drop", "--security-opt", "--ipc", "--ulimit",
+	"--dns", "--dns-search", "--label", "-l", "--user", "-u", "--workdir", "-w",
+}
+
+// Normalize gives every container its type, see containerType, and
+// normalizes it.
+func (m *Manifest) Normalize() error {
+	for i := range m.Containers {
+		m.Containers[i].Type = m.containerType(&m.Containers[i])
+		if err := m.Containers[i].Normalize(); err != nil {
+			return fmt.Errorf("container[%s]: %w", m.Containers[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// Normalize translates the legacy Options list into the typed fields and
+// clears it. Options that conflict with an explicitly set field are errors.
+func (c *Container) Normalize() error {
+	if len(c.Options) == 0 {
+		return nil
+	}
+
+	for _, opt := range c.Options {
+		flag, val := splitOption(opt)
+		if err := c.applyOption(flag, val); err != nil {
+			return fmt.Errorf("option %q: %w", opt, err)
+		}
+	}
+	c.Options = nil
+	return nil
+}
+
+// splitOption accepts "--flag=value", "--flag value" and "-v value" with any
+// amount of whitespace between the flag and its value.
+func splitOption(opt string) (string, string) {
+	opt = strings.TrimSpace(opt)
+	if i := strings.IndexAny(opt, " \t"); i >= 0 {
+		if j := strings.IndexByte(opt, '='); j < 0 || j > i {
+			return opt[:i], strings.TrimSpace(opt[i:])
+		}
+	}
+	if flag, val, ok := strings.Cut(opt, "="); ok {
+		return flag, val
+	}
+	return opt, ""
+}
+
+func (c *Container) applyOption(flag, val string) error {
+	requireValue := func() error {
+		if val == "" {
+			return fmt.Errorf("%s requires a value", flag)
+		}
+		return nil
+	}
+
+	switch flag {
+	case "--privileged":
+		c.Privileged = true
+		return nil
+	case "--read-only":
+		c.ReadOnly = true
+		return nil
+	}
+
+	if err := requireValue(); err != nil {
+		return err
+	}
+
+	switch flag {
+	case "--net", "--network":
+		return setString(&c.Network, val, "network")
+
+	case "--restart":
+		return setString(&c.Restart, val, "restart")
+
+	case "--pull":
+		// Docker calls ifNotPresent missing.
+		if val == "missing" {
+			val = PullIfNotPresent
+		}
+		return setString(&c.PullPolicy, val, "pullPolicy")
+
+	case "-v", "--volume":
+		v, err := ParseVolume(val)
+		if err != nil {
+			return err
+		}
+		c.Volumes = append(c.Volumes, v)
+
+	case "--memory", "-m":
+		n, err := ParseByteSize(val)
+		if err != nil {
+			return fmt.Errorf("invalid memory value: %w", err)
+		}
+		return setSize(&c.Resources.Memory, n, "resources.memory")
+
+	case "--cpus":
+		cpus, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpus value: %s", val)
+		}
+		if c.Resources.CPUs != 0 && c.Resources.CPUs != cpus {
+			return fmt.Errorf("conflicts with resources.cpus %v", c.Resources.CPUs)
+		}
+		c.Resources.CPUs = cpus
+
+	case "--shm-size":
+		n, err := ParseByteSize(val)
+		if err != nil {
+			return fmt.Errorf("invalid shm-size: %w", err)
+		}
+		return setSize(&c.Resources.ShmSize, n, "resources.shmSize")
+
+	case "--add-host":
+		c.ExtraHosts = append(c.ExtraHosts, val)
+
+	case "--device":
+		c.Devices = append(c.Devices, val)
+
+	case "--tmpfs":
+		mount, opts, _ := strings.Cut(val, ":")
+		c.Tmpfs = cloneMap(c.Tmpfs)
+		c.Tmpfs[mount] = opts
+
+	case "--hostname", "-h":
+		return setString(&c.Hostname, val, "hostname")
+
+	case "--cap-add":
+		c.Capabilities.Add = append(c.Capabilities.Add, val)
+
+	case "--cap-drop":
+		c.Capabilities.Drop = append(c.Capabilities.Drop, val)
+
+	case "--security-opt":
+		c.SecurityOpt = append(c.SecurityOpt, val)
+
+	case "--ipc":
+		return setString(&c.IPC, val, "ipc")
+
+	case "--ulimit":
+		u, err := parseUlimit(val)
+		if err != nil {
+			return err
+		}
+		c.Ulimits = append(c.Ulimits, u)
+
+	case "--dns":
+		c.DNS = append(c.DNS, val)
+
+	case "--dns-search":
+		c.DNSSearch = append(c.DNSSearch, val)
+
+	case "--label", "-l":
+		k, v, _ := strings.Cut(val, "=")
+		c.Labels = cloneMap(c.Labels)
+		c.Labels[k] = v
+
+	case "--user", "-u":
+		return setString(&c.User, val, "user")
+
+	case "--workdir", "-w":
+		return setString(&c.WorkDir, val, "workdir")
+
+	default:
+		return fmt.Errorf("unsupported or unknown option: %s", flag)
+	}
+	return nil
+}
+
+func parseUlimit(val string) (Ulimit, error) {
+	name, limits, ok := strings.Cut(val, "=")
+	if !ok {
+		return Ulimit{}, fmt.Errorf("invalid ulimit format: %s", val)
+	}
+	softStr, hardStr, ok := strings.Cut(limits, ":")
+	if !ok {
+		hardStr = softStr
+	}
+	soft, err := strconv.ParseInt(softStr, 10, 64)
+	if err != nil {
+		return Ulimit{}, fmt.Errorf("invalid soft limit: %s", softStr)
+	}
+	hard, err := strconv.ParseInt(hardStr, 10, 64)
+	if err != nil {
+		return Ulimit{}, fmt.Errorf("invalid hard limit: %s", hardStr)
+	}
+	return Ulimit{Name: name, Soft: soft, Hard: hard}, nil
+}
+
+func setString(dst *string, val, field string) error {
+	if *dst != "" && *dst != val {
+		return fmt.Errorf("conflicts with %s %q", field, *dst)
+	}
+	*dst = val
+	return nil
+}
+
+func setSize(dst *ByteSize, val ByteSize, field string) error {
+	if *dst != 0 && *dst != val {
+		return fmt.Errorf("conflicts with %s %s", field, *dst)
+	}
+	*dst = val
+	return nil
+}
+
+// cloneMap returns a writable copy so that normalizing a shallow copy of a
+// Container never mutates the original's maps.
+func cloneMap(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize_TranslatesLegacyOptions(t *testing.T) {
+	c := Container{
+		Name:  "app",
+		Host:  "host1",
+		Image: "nginx",
+		Options: []string{
+			"--net=my-net",
+			"-v   /mnt:/mnt",
+			"--volume=/data:/data:ro",
+			"--restart=on-failure:3",
+			"--pull=missing",
+			"--memory=512m",
+			"--cpus=1.5",
+			"--cap-add=NET_ADMIN",
+			"--ulimit=nofile=1024:2048",
+			"--dns=8.8.8.8",
+			"--label=team=core",
+			"--privileged",
+			"--tmpfs=/run:size=64m",
+		},
+	}
+
+	if err := c.Normalize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(c.Options) != 0 {
+		t.Errorf("expected options to be cleared, got %v", c.Options)
+	}
+	if c.Network != "my-net" {
+		t.Errorf("expected network 'my-net', got %q", c.Network)
+	}
+	wantVolumes := []Volume{{Source: "/mnt", Target: "/mnt"}, {Source: "/data", Target: "/data", ReadOnly: true}}
+	if !reflect.DeepEqual(c.Volumes, wantVolumes) {
+		t.Errorf("expected volumes %v, got %v", wantVolumes, c.Volumes)
+	}
+	if c.Restart != "on-failure:3" {
+		t.Errorf("expected restart 'on-failure:3', got %q", c.Restart)
+	}
+	if c.PullPolicy != PullIfNotPresent {
+		t.Errorf("expected pull policy %q, got %q", PullIfNotPresent, c.PullPolicy)
+	}
+	if c.Resources.Memory != 512*MiB || c.Resources.CPUs != 1.5 {
+		t.Errorf("unexpected resources %+v", c.Resources)
+	}
+	if !reflect.DeepEqual(c.Capabilities.Add, []string{"NET_ADMIN"}) {
+		t.Errorf("unexpected capabilities %+v", c.Capabilities)
+	}
+	if !reflect.DeepEqual(c.Ulimits, []Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}}) {
+		t.Errorf("unexpected ulimits %+v", c.Ulimits)
+	}
+	if !reflect.DeepEqual(c.DNS, []string{"8.8.8.8"}) {
+		t.Errorf("unexpected dns %v", c.DNS)
+	}
+	if c.Labels["team"] != "core" {
+		t.Errorf("unexpected labels %v", c.Labels)
+	}
+	if !c.Privileged {
+		t.Error("expected privileged to be set")
+	}
+	if c.Tmpfs["/run"] != "size=64m" {
+		t.Errorf("unexpected tmpfs %v", c.Tmpfs)
+	}
+}
+
+func TestNormalize_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Container
+	}{
+		{name: "unknown option", c: Container{Options: []string{"--netwrk=host"}}},
+		{name: "missing value", c: Container{Options: []string{"--dns"}}},
+		{name: "bad volume", c: Container{Options: []string{"-v /only-one"}}},
+		{name: "bad ulimit", c: Container{Options: []string{"--ulimit=nofile"}}},
+		{name: "conflicting network", c: Container{Network: "a", Options: []string{"--net=b"}}},
+	}
+
+	for _, tt := range tests {
+		if err := tt.c.Normalize(); err == nil {
+			t.Errorf("%s: expected error, got nil", tt.name)
+		}
+	}
+}
+
+func TestValidate_DoesNotMutateOptions(t *testing.T) {
+	c := Container{
+		Name:    "app",
+		Host:    "host1",
+		Image:   "nginx",
+		Labels:  map[string]string{"a": "b"},
+		Options: []string{"--label=c=d"},
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.Options) != 1 || len(c.Labels) != 1 {
+		t.Errorf("expected Validate to leave the container untouched, got %+v", c)
+	}
+}
+
+func TestValidate_TypedFields(t *testing.T) {
+	base := func() Container { return Container{Name: "app", Host: "host1", Image: "nginx"} }
+
+	tests := []struct {
+		name    string
+		mutate  func(c *Container)
+		wantErr bool
+	}{
+		{name: "valid restart", mutate: func(c *Container) { c.Restart = "unless-stopped" }, wantErr: false},
+		{name: "invalid restart", mutate: func(c *Container) { c.Restart = "sometimes" }, wantErr: true},
+		{name: "restart count on always", mutate: func(c *Container) { c.Restart = "always:3" }, wantErr: true},
+		{name: "valid pull policy", mutate: func(c *Container) { c.PullPolicy = PullNever }, wantErr: false},
+		{name: "invalid pull policy", mutate: func(c *Container) { c.PullPolicy = "missing" }, wantErr: true},
+		{name: "relative volume target", mutate: func(c *Container) { c.Volumes = []Volume{{Source: "/a", Target: "b"}} }, wantErr: true},
+		{name: "negative memory", mutate: func(c *Container) { c.Resources.Memory = -1 }, wantErr: true},
+		{name: "lowercase capability", mutate: func(c *Container) { c.Capabilities.Add = []string{"net_admin"} }, wantErr: true},
+		{name: "soft above hard", mutate: func(c *Container) { c.Ulimits = []Ulimit{{Name: "nofile", Soft: 2, Hard: 1}} }, wantErr: true},
+		{name: "invalid dns", mutate: func(c *Container) { c.DNS = []string{"dns.google"} }, wantErr: true},
+		{name: "relative workdir", mutate: func(c *Container) { c.WorkDir = "app" }, wantErr: true},
+		{name: "typo in option", mutate: func(c *Container) { c.Options = []string{"--restrat=always"} }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		c := base()
+		tt.mutate(&c)
+		err := c.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PortList holds port specs in their short string form. In manifests each
+// entry may also be written in the long form, which is converted on decode.
+type PortList []string
+
+// PortMapping is a single container port published on the host. An empty
+// HostPort lets docker pick a random port; HostPort may also be a range
+// ("8000-8010") when a single container port is published.
+type PortMapping struct {
+	HostIP        string
+	HostPort      string
+	ContainerPort int
+	Protocol      string
+}
+
+type longPort struct {
+	Target    string `yaml:"target"`
+	Published string `yaml:"published,omitempty"`
+	HostIP    string `yaml:"hostIP,omitempty"`
+	Protocol  string `yaml:"protocol,omitempty"`
+}
+
+var protocols = []string{"tcp", "udp", "sctp"}
+
+func (p *PortList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: 'ports' must be a list", node.Line)}}
+	}
+
+	res := make(PortList, 0, len(node.Content))
+	var errs []string
+	for _, item := range node.Content {
+		switch item.Kind {
+		case yaml.ScalarNode:
+			res = append(res, item.Value)
+		case yaml.MappingNode:
+			var lp longPort
+			if err := item.Decode(&lp); err != nil {
+				return err
+			}
+			if lp.Target == "" {
+				errs = append(errs, fmt.Sprintf("line %d: port 'target' field is required", item.Line))
+				continue
+			}
+			res = append(res, lp.String())
+		default:
+			errs = append(errs, fmt.Sprintf("line %d: port must be a string or a mapping", item.Line))
+		}
+	}
+	if len(errs) > 0 {
+		return &yaml.TypeError{Errors: errs}
+	}
+
+	*p = res
+	return nil
+}
+
+func (lp longPort) String() string {
+	s := lp.Target
+	switch {
+	case lp.HostIP != "":
+		ip := lp.HostIP
+		if strings.Contains(ip, ":") {
+			ip = "[" + ip + "]"
+		}
+		s = ip + ":" + lp.Published + ":" + s
+	case lp.Published != "":
+		s = lp.Published + ":" + s
+	}
+	if lp.Protocol != "" {
+		s += "/" + lp.Protocol
+	}
+	return s
+}
+
+// ParsePort parses a port spec of the form
+//
+//	[[hostIP:][hostPort]:]containerPort[/protocol]
+//
+// where ports may be ranges ("8000-8010") and an IPv6 hostIP is written in
+// brackets. Container port ranges are expanded into one mapping per port.
+func ParsePort(spec string) ([]PortMapping, error) {
+	fail := func(format string, args ...any) ([]PortMapping, error) {
+		return nil, fmt.Errorf("invalid port format %q: %s", spec, fmt.Sprintf(format, args...))
+	}
+
+	rest, proto, hasProto := strings.Cut(spec, "/")
+	if !hasProto {
+		proto = "tcp"
+	}
+	if !validProtocol(proto) {
+		return fail("unknown protocol %q, expected one of %s", proto, strings.Join(protocols, ", "))
+	}
+
+	var hostIP string
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]:")
+		if end < 0 {
+			return fail("unterminated IPv6 address")
+		}
+		hostIP, rest = rest[1:end], rest[end+2:]
+		if ip := net.ParseIP(hostIP); ip == nil || ip.To4() != nil {
+			return fail("invalid IPv6 address %q", hostIP)
+		}
+		if !strings.Contains(rest, ":") {
+			return fail("host IP requires host and container ports")
+		}
+	}
+
+	parts := strings.Split(rest, ":")
+	var hostPart, containerPart string
+	switch len(parts) {
+	case 1:
+		containerPart = parts[0]
+	case 2:
+		hostPart, containerPart = parts[0], parts[1]
+		if hostIP == "" && hostPart == "" {
+			return fail("empty host port")
+		}
+	case 3:
+		if hostIP != "" {
+			return fail("too many colons")
+		}
+		hostIP, hostPart, containerPart = parts[0], parts[1], parts[2]
+		if net.ParseIP(hostIP) == nil {
+			return fail("invalid host IP %q", hostIP)
+		}
+	default:
+		return fail("too many colons, write IPv6 addresses as [addr]")
+	}
+
+	cStart, cEnd, err := parsePortRange(containerPart)
+	if err != nil {
+		return fail("container port: %v", err)
+	}
+
+	var hStart, hEnd int
+	if hostPart != "" {
+		if hStart, hEnd, err = parsePortRange(hostPart); err != nil {
+			return fail("host port: %v", err)
+		}
+	}
+
+	count := cEnd - cStart + 1
+	hostCount := hEnd - hStart + 1
+	if hostPart != "" && hostCount != count && count != 1 {
+		return fail("host range has %d ports but container range has %d", hostCount, count)
+	}
+
+	res := make([]PortMapping, 0, count)
+	for i := 0; i < count; i++ {
+		m := PortMapping{HostIP: hostIP, ContainerPort: cStart + i, Protocol: proto}
+		switch {
+		case hostPart == "":
+		case count == 1:
+			m.HostPort = hostPart
+		default:
+			m.HostPort = strconv.Itoa(hStart + i)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+func parsePortRange(s string) (int, int, error) {
+	startStr, endStr, isRange := strings.Cut(s, "-")
+	start, err := parsePortNumber(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err := parsePortNumber(endStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range %q ends before it starts", s)
+	}
+	return start, end, nil
+}
+
+func parsePortNumber(s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing port number")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if n < 1 || n > 65535 {
+		return 0, fmt.Errorf("%d is out of range 1-65535", n)
+	}
+	return n, nil
+}
+
+func validProtocol(p string) bool {
+	for _, proto := range protocols {
+		if p == proto {
+			return true
+		}
+	}
+	return false
+}
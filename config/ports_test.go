@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParsePort(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []PortMapping
+	}{
+		{spec: "80", want: []PortMapping{{ContainerPort: 80, Protocol: "tcp"}}},
+		{spec: "8080:80", want: []PortMapping{{HostPort: "8080", ContainerPort: 80, Protocol: "tcp"}}},
+		{spec: "127.0.0.1:8080:80", want: []PortMapping{{HostIP: "127.0.0.1", HostPort: "8080", ContainerPort: 80, Protocol: "tcp"}}},
+		{spec: "127.0.0.1::80", want: []PortMapping{{HostIP: "127.0.0.1", ContainerPort: 80, Protocol: "tcp"}}},
+		{spec: "[::1]:8080:80", want: []PortMapping{{HostIP: "::1", HostPort: "8080", ContainerPort: 80, Protocol: "tcp"}}},
+		{spec: "53:53/udp", want: []PortMapping{{HostPort: "53", ContainerPort: 53, Protocol: "udp"}}},
+		{spec: "9000/sctp", want: []PortMapping{{ContainerPort: 9000, Protocol: "sctp"}}},
+		{spec: "8000-8001:9000-9001", want: []PortMapping{
+			{HostPort: "8000", ContainerPort: 9000, Protocol: "tcp"},
+			{HostPort: "8001", ContainerPort: 9001, Protocol: "tcp"},
+		}},
+		{spec: "7000-7001", want: []PortMapping{
+			{ContainerPort: 7000, Protocol: "tcp"},
+			{ContainerPort: 7001, Protocol: "tcp"},
+		}},
+		{spec: "8000-8010:80", want: []PortMapping{{HostPort: "8000-8010", ContainerPort: 80, Protocol: "tcp"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePort(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePort(%q) unexpected error: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePort(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParsePort_Errors(t *testing.T) {
+	specs := []string{
+		"",
+		"badformat",
+		"0",
+		"70000",
+		"80/icmp",
+		":80",
+		"8000-8002:9000-9001",
+		"9001-9000",
+		"1.2.3.4.5:80:80",
+		"::1:8080:80",
+		"[::1:8080:80",
+		"[127.0.0.1]:8080:80",
+		"a:b:c:d",
+	}
+
+	for _, spec := range specs {
+		if _, err := ParsePort(spec); err == nil {
+			t.Errorf("ParsePort(%q): expected error, got nil", spec)
+		}
+	}
+}
+
+func TestPortList_LongForm(t *testing.T) {
+	data := `
+ports:
+  - "8080:80"
+  - target: 53
+    published: 5353
+    protocol: udp
+  - target: 443
+    published: 8443
+    hostIP: 127.0.0.1
+  - target: 9000
+`
+	var c Container
+	if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := PortList{"8080:80", "5353:53/udp", "127.0.0.1:8443:443", "9000"}
+	if !reflect.DeepEqual(c.Ports, want) {
+		t.Errorf("expected %v, got %v", want, c.Ports)
+	}
+
+	if err := yaml.Unmarshal([]byte("ports:\n  - published: 80\n"), &c); err == nil {
+		t.Error("expected error for long form without target")
+	}
+}
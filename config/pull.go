@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// DefaultRegistry is the registry of images that name none.
+const DefaultRegistry = "docker.io"
+
+// Pull policies of a container.
+const (
+	PullAlways       = "always"
+	PullIfNotPresent = "ifNotPresent"
+	PullNever        = "never"
+)
+
+var pullPolicies = []string{PullAlways, PullIfNotPresent, PullNever}
+
+// RegistryAuth holds the credentials for a registry.
+type RegistryAuth struct {
+	Registry string
+	Username string
+	Password string
+}
+
+// PullResult is the outcome of the last pull of the image of a container, as
+// reported by the slave.
+type PullResult struct {
+	Image string
+	Time  time.Time
+	// Digest is the digest the image resolved to, if known.
+	Digest string `json:",omitempty"`
+	// Error is empty if the pull succeeded.
+	Error string `json:",omitempty"`
+	// AuthFailed is set when the registry refused the credentials, or asked
+	// for some and there were none.
+	AuthFailed bool `json:",omitempty"`
+}
+
+// PullProgress is the progress of a pull, as reported by the slave while
+// it runs.
+type PullProgress struct {
+	Image   string
+	Started time.Time
+	// Current and Total are the bytes downloaded and to download, of the
+	// layers whose size is known so far.
+	Current ByteSize
+	Total   ByteSize
+	// Layers is the number of layers of the image and Done the number of
+	// layers downloaded or already present.
+	Layers int
+	Done   int
+}
+
+// Percent returns how much of the image is downloaded, from 0 to 100.
+func (p PullProgress) Percent() float64 {
+	if p.Layers > 0 && p.Done == p.Layers {
+		return 100
+	}
+	if p.Total <= 0 {
+		return 0
+	}
+	return 100 * float64(p.Current) / float64(p.Total)
+}
+
+// ImageRegistry returns the host of the registry an image is pulled from,
+// following the rules of Docker: the first component of the name is a
+// registry if it has a dot or a port, or is localhost.
+func ImageRegistry(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if !found || !strings.ContainsAny(first, ".:") && first != "localhost" {
+		return DefaultRegistry
+	}
+	return first
+}
+
+// EffectivePullPolicy returns the pull policy of the container. Like
+// Kubernetes, it defaults to always for images tagged latest or not tagged
+// at all, and to ifNotPresent otherwise.
+func (c *Container) EffectivePullPolicy() string {
+	if c.PullPolicy != "" {
+		return c.PullPolicy
+	}
+	ref, err := reference.ParseNormalizedNamed(c.Image)
+	if err != nil {
+		return PullIfNotPresent
+	}
+	if _, ok := ref.(reference.Digested); ok {
+		return PullIfNotPresent
+	}
+	if tagged, ok := ref.(reference.Tagged); ok && tagged.Tag() != "latest" {
+		return PullIfNotPresent
+	}
+	return PullAlways
+}
+
+// ImageDigest returns the digest an image reference pins, or "" if it has
+// none.
+func ImageDigest(image string) string {
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	if d, ok := ref.(reference.Digested); ok {
+		return d.Digest().String()
+	}
+	return ""
+}
+
+// PinImage returns the reference to the image by digest, dropping its tag,
+// e.g. nginx@sha256:... for nginx:1.27.
+func PinImage(image, dgst string) (string, error) {
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	d, err := digest.Parse(dgst)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest %q: %v", dgst, err)
+	}
+	pinned, err := reference.WithDigest(reference.TrimNamed(ref), d)
+	if err != nil {
+		return "", err
+	}
+	return reference.FamiliarString(pinned), nil
+}
+
+func validPullPolicy(s string) bool {
+	for _, p := range pullPolicies {
+		if s == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImageRegistry(t *testing.T) {
+	for image, want := range map[string]string{
+		"nginx":                             "docker.io",
+		"library/nginx:1.27":                "docker.io",
+		"myorg/app":                         "docker.io",
+		"registry.example.com/team/app:1.0": "registry.example.com",
+		"registry.example.com:5000/app":     "registry.example.com:5000",
+		"localhost/app":                     "localhost",
+		"localhost:5000/app":                "localhost:5000",
+	} {
+		if got := ImageRegistry(image); got != want {
+			t.Errorf("ImageRegistry(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
+
+func TestEffectivePullPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		image, policy, want string
+	}{
+		{"nginx", "", PullAlways},
+		{"nginx:latest", "", PullAlways},
+		{"nginx:1.27", "", PullIfNotPresent},
+		{"nginx@sha256:" + strings.Repeat("a", 64), "", PullIfNotPresent},
+		{"nginx", PullNever, PullNever},
+		{"nginx:1.27", PullAlways, PullAlways},
+	} {
+		c := Container{Image: tt.image, PullPolicy: tt.policy}
+		if got := c.EffectivePullPolicy(); got != tt.want {
+			t.Errorf("EffectivePullPolicy of %q with %q = %q, want %q", tt.image, tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	dgst := "sha256:" + strings.Repeat("ab", 32)
+	for image, want := range map[string]string{
+		"nginx":                           "nginx@" + dgst,
+		"nginx:1.27":                      "nginx@" + dgst,
+		"registry.example.com:5000/app:2": "registry.example.com:5000/app@" + dgst,
+		"myorg/app@sha256:" + strings.Repeat("0", 64): "myorg/app@" + dgst,
+	} {
+		got, err := PinImage(image, dgst)
+		if err != nil {
+			t.Errorf("PinImage(%q): %v", image, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("PinImage(%q) = %q, want %q", image, got, want)
+		}
+		if d := ImageDigest(got); d != dgst {
+			t.Errorf("ImageDigest(%q) = %q, want %q", got, d, dgst)
+		}
+	}
+	if _, err := PinImage("nginx", "latest"); err == nil {
+		t.Error("expected an error for an invalid digest")
+	}
+	if d := ImageDigest("nginx:1.27"); d != "" {
+		t.Errorf("expected no digest for a tag, got %q", d)
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/cron"
+)
+
+// Concurrency policies of a scheduled container, applied when a run is due
+// while an earlier run is still active.
+const (
+	ConcurrencyAllow   = "allow"
+	ConcurrencyForbid  = "forbid"
+	ConcurrencyReplace = "replace"
+)
+
+// DefaultHistoryLimit is how many finished runs of a scheduled container are
+// kept by default.
+const DefaultHistoryLimit = 5
+
+// Schedule makes a job run on a cron schedule. The master creates a new run
+// of the container at every activation.
+type Schedule struct {
+	Cron              string `yaml:"cron"`
+	Timezone          string `yaml:"timezone,omitempty"`
+	ConcurrencyPolicy string `yaml:"concurrencyPolicy,omitempty"`
+	HistoryLimit      int    `yaml:"historyLimit,omitempty"`
+}
+
+// CronState tracks the activations of a scheduled container.
+type CronState struct {
+	Next time.Time
+	Last time.Time
+}
+
+// CronJobStatus is a scheduled container with its runs, oldest first.
+type CronJobStatus struct {
+	ManifestName string
+	Name         string
+	Host         string
+	Schedule     Schedule
+	CronState
+	Runs []ContainerStatus
+}
+
+func (s *Schedule) Validate() error {
+	var v validator
+	if s.Cron == "" {
+		v.add("cron", "field is required")
+	} else if _, err := cron.Parse(s.Cron); err != nil {
+		v.add("cron", "%v", err)
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		v.add("timezone", "unknown time zone %q", s.Timezone)
+	}
+	switch s.ConcurrencyPolicy {
+	case "", ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace:
+	default:
+		v.add("concurrencyPolicy", "invalid policy %q, expected %s, %s or %s",
+			s.ConcurrencyPolicy, ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace)
+	}
+	if s.HistoryLimit < 0 {
+		v.add("historyLimit", "cannot be negative")
+	}
+	return v.err()
+}
+
+// Next returns the first activation after t, in the schedule's time zone.
+// An empty time zone is UTC.
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	sched, err := cron.Parse(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown time zone %q", s.Timezone)
+	}
+	return sched.Next(t.In(loc)), nil
+}
+
+func (s *Schedule) Policy() string {
+	if s.ConcurrencyPolicy == "" {
+		return ConcurrencyAllow
+	}
+	return s.ConcurrencyPolicy
+}
+
+func (s *Schedule) History() int {
+	if s.HistoryLimit == 0 {
+		return DefaultHistoryLimit
+	}
+	return s.HistoryLimit
+}
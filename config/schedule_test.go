@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManifestValidate_Schedule(t *testing.T) {
+	m := Manifest{
+		Name: "nightly",
+		Containers: []Container{
+			{Name: "report", Host: "node1", Image: "busybox", Schedule: &Schedule{Cron: "30 2 * * *", Timezone: "UTC"}},
+			{Name: "backup", Host: "node1", Image: "busybox", Schedule: &Schedule{Cron: "61 * * * *", Timezone: "Mars/Olympus", ConcurrencyPolicy: "queue", HistoryLimit: -1}},
+			{Name: "web", Host: "node1", Image: "nginx", Type: KindService, Schedule: &Schedule{Cron: "@daily"}},
+		},
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, p := range []string{
+		"containers[1].schedule.cron", "containers[1].schedule.timezone",
+		"containers[1].schedule.concurrencyPolicy", "containers[1].schedule.historyLimit",
+		"containers[2].schedule",
+	} {
+		if !hasPath(err, p) {
+			t.Errorf("missing error at %s, got %v", p, err)
+		}
+	}
+
+	if err := m.Normalize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !m.Containers[0].IsJob() {
+		t.Errorf("expected a scheduled container to be a job, got %q", m.Containers[0].Type)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	s := Schedule{Cron: "0 3 * * *", Timezone: "Europe/Kyiv"}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	next, err := s.Next(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2026, 10, 20, 3, 0, 0, 0, loc); !next.Equal(want) {
+		t.Errorf("expected %s, got %s", want, next)
+	}
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	commandType  = reflect.TypeOf(Command(""))
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	volumeType   = reflect.TypeOf(Volume{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// fieldDescriptions documents every manifest field, keyed by Go type name and
+// yaml key. TestSchemaDescriptions fails when a field is missing here.
+var fieldDescriptions = map[string]string{
+	"Manifest.name":       "Name of the manifest. Uploading a manifest with the same name replaces it.",
+	"Manifest.kind":       "Default type of the containers: service (the default) or job.",
+	"Manifest.pinDigests": "Pin every image to the digest its first pull resolved to, so that all hosts run the identical image.",
+	"Manifest.networks":   "Networks created on each host that runs a container attached to them.",
+	"Manifest.volumes":    "Named volumes, mounted by listing their name as a container volume source.",
+	"Manifest.containers": "Containers started by this manifest.",
+
+	"Container.name":         "Container name, unique per host.",
+	"Container.host":         "Name of the slave that runs the container.",
+	"Container.image":        "Image reference, e.g. nginx:1.25 or registry.local:5000/app@sha256:...",
+	"Container.pullPolicy":   "When to pull the image: always, ifNotPresent or never. Defaults to always for latest or untagged images, ifNotPresent otherwise.",
+	"Container.entrypoint":   "Overrides the image entrypoint. A list is passed verbatim, a string is split with shell quoting rules.",
+	"Container.cmd":          "Overrides the image command. A list is passed verbatim, a string is split with shell quoting rules.",
+	"Container.ports":        "Published ports, as [[hostIP:][hostPort]:]containerPort[/protocol] or in the long form.",
+	"Container.environment":  "Environment variables.",
+	"Container.secrets":      "Secrets stored on the master, exposed as environment variables or files.",
+	"Container.configs":      "Files mounted into the container, inline or from configs stored on the master.",
+	"Container.volumes":      "Mounts, as source:target[:ro|rw] or in the long form. The source is a host path or a volume name.",
+	"Container.resources":    "Resource limits.",
+	"Container.restart":      "Restart policy.",
+	"Container.network":      "Network mode or name of an existing network to connect to.",
+	"Container.networks":     "Manifest networks to attach to, with the container name as DNS alias.",
+	"Container.capabilities": "Linux capabilities to add or drop.",
+	"Container.ulimits":      "Resource limits set with setrlimit.",
+	"Container.dns":          "Custom DNS servers.",
+	"Container.dnsSearch":    "Custom DNS search domains.",
+	"Container.labels":       "Container labels.",
+	"Container.user":         "User the processes run as, as user[:group].",
+	"Container.workdir":      "Working directory, an absolute path.",
+	"Container.readOnly":     "Mount the root filesystem read-only.",
+	"Container.privileged":   "Give extended privileges to the container.",
+	"Container.hostname":     "Container hostname.",
+	"Container.extraHosts":   "Extra /etc/hosts entries, as name:ip.",
+	"Container.devices":      "Host devices to add, as host[:container[:permissions]].",
+	"Container.tmpfs":        "Tmpfs mounts, keyed by absolute path, with mount options as values.",
+	"Container.securityOpt":  "Security options, e.g. no-new-privileges or seccomp=unconfined.",
+	"Container.ipc":          "IPC namespace mode.",
+	"Container.type":         "service (kept running, the default) or job (run to completion). Defaults to the manifest kind.",
+	"Container.schedule":     "Run the job on a cron schedule instead of once.",
+	"Container.job":          "Retries, deadline and cleanup of a job.",
+	"Container.preStart":     "One-shot containers run to completion before the container starts.",
+	"Container.postStart":    "Command executed in the container right after it starts.",
+	"Container.preStop":      "Command executed in the container before it is stopped or removed.",
+	"Container.options":      "Deprecated docker-CLI-like flags, translated into the typed fields.",
+
+	"Network.name":     "Network name, unique within the manifest.",
+	"Network.driver":   "Network driver, bridge by default.",
+	"Network.internal": "Restrict external access to the network.",
+	"Network.labels":   "Network labels.",
+
+	"NamedVolume.name":       "Volume name, unique within the manifest.",
+	"NamedVolume.driver":     "Volume driver, local by default.",
+	"NamedVolume.driverOpts": "Driver specific options.",
+	"NamedVolume.labels":     "Volume labels.",
+	"NamedVolume.retention":  "Whether the volume is kept or deleted when the manifest goes down.",
+
+	"ExecHook.command":   "Command to execute, as a list or a shell-quoted string.",
+	"ExecHook.timeout":   "How long the command may run, e.g. 30s. Defaults to 30s.",
+	"ExecHook.onFailure": "Whether a failure aborts the start (abort, the default) or is only reported (continue).",
+
+	"PreStartHook.image":       "Image to run, the container's image by default.",
+	"PreStartHook.command":     "Command to run, the image command by default.",
+	"PreStartHook.environment": "Environment variables added to the container's.",
+	"PreStartHook.timeout":     "How long the hook may run, e.g. 5m. Defaults to 5m.",
+	"PreStartHook.onFailure":   "Whether a failure aborts the start (abort, the default) or is only reported (continue).",
+
+	"Job.retries":    "How many times a failed run is retried.",
+	"Job.deadline":   "How long all attempts together may run, e.g. 1h. Unlimited by default.",
+	"Job.autoRemove": "Remove the container once the job finished.",
+
+	"Schedule.cron":              "Standard 5-field cron expression, e.g. \"30 2 * * *\", or a macro such as @daily.",
+	"Schedule.timezone":          "IANA time zone of the expression, e.g. Europe/Kyiv. UTC by default.",
+	"Schedule.concurrencyPolicy": "What to do when a run is due while the previous one is active: allow (default), forbid or replace.",
+	"Schedule.historyLimit":      "How many finished runs are kept. Defaults to 5.",
+
+	"SecretRef.name":   "Name of the secret on the master.",
+	"SecretRef.env":    "Environment variable that receives the value.",
+	"SecretRef.target": "Absolute path of the file that receives the value.",
+	"SecretRef.mode":   "File mode of target, 0400 by default.",
+
+	"ConfigFile.name":    "Name of the config on the master. Mutually exclusive with content.",
+	"ConfigFile.content": "Inline file content. Mutually exclusive with name.",
+	"ConfigFile.target":  "Absolute path of the file in the container.",
+	"ConfigFile.mode":    "File mode of target, 0444 by default.",
+
+	"Volume.source":   "Host path or volume name.",
+	"Volume.target":   "Absolute path in the container.",
+	"Volume.readOnly": "Mount read-only.",
+
+	"Resources.memory":  "Memory limit in bytes or with a unit, e.g. 512m. At least 6MiB.",
+	"Resources.cpus":    "Number of CPUs, e.g. 1.5.",
+	"Resources.shmSize": "Size of /dev/shm in bytes or with a unit.",
+
+	"Capabilities.add":  "Capabilities to add, e.g. NET_ADMIN, or ALL.",
+	"Capabilities.drop": "Capabilities to drop, e.g. MKNOD, or ALL.",
+
+	"Ulimit.name": "Limit name, e.g. nofile.",
+	"Ulimit.soft": "Soft limit.",
+	"Ulimit.hard": "Hard limit, not lower than soft.",
+
+	"longPort.target":    "Container port or range.",
+	"longPort.published": "Host port or range. Docker picks a random port when empty.",
+	"longPort.hostIP":    "Host IP to bind to.",
+	"longPort.protocol":  "Port protocol.",
+}
+
+var (
+	restartPattern    = `^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`
+	ipcPattern        = `^(none|private|shareable|host|container:.+)$`
+	capabilityPattern = `^([A-Z_]+|ALL)$`
+	sizePattern       = `^\s*[0-9]+(\.[0-9]+)?\s*([kKmMgGtT]?[iI]?[bB]?)\s*$`
+	portPattern       = `^((\[[0-9a-fA-F:.]+\]|[0-9.]+)?:)?(([0-9]+(-[0-9]+)?)?:)?[0-9]+(-[0-9]+)?(/(tcp|udp|sctp))?$`
+	durationPattern   = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	volumePattern     = `^[^:]+:/[^:]*(:(ro|rw))?$`
+)
+
+// Schema returns the JSON Schema of manifests, generated from the Manifest
+// type.
+func Schema() ([]byte, error) {
+	g := schemaGen{defs: map[string]any{}}
+	root := g.structSchema(manifestType)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "Manifest"
+	root["$defs"] = g.defs
+
+	// include is handled by LoadManifestFiles before the manifest is decoded.
+	root["properties"].(map[string]any)["include"] = map[string]any{
+		"description": "Manifest files merged before this one, relative to this file.",
+		"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+type schemaGen struct {
+	defs map[string]any
+}
+
+func (g *schemaGen) typeSchema(t reflect.Type) map[string]any {
+	switch t {
+	case commandType:
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		}}
+	case byteSizeType:
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "integer", "minimum": 0},
+			map[string]any{"type": "string", "pattern": sizePattern},
+		}}
+	case portListType:
+		return map[string]any{"type": "array", "items": map[string]any{"oneOf": []any{
+			map[string]any{"type": "string", "pattern": portPattern},
+			map[string]any{"type": "integer", "minimum": 1, "maximum": 65535},
+			g.ref(longPortType),
+		}}}
+	case durationType:
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	case volumeType:
+		return map[string]any{"oneOf": []any{
+			map[string]any{"type": "string", "pattern": volumePattern},
+			g.ref(volumeType),
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		return g.ref(t)
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		elem := g.typeSchema(t.Elem())
+		if t.Elem().Kind() == reflect.String {
+			// YAML numbers and booleans are decoded into strings as written.
+			elem["type"] = []string{"string", "number", "boolean"}
+		}
+		return map[string]any{"type": "object", "additionalProperties": elem}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number", "minimum": 0}
+	}
+	panic("config: no schema for " + t.String())
+}
+
+func (g *schemaGen) ref(t reflect.Type) map[string]any {
+	if _, ok := g.defs[t.Name()]; !ok {
+		g.defs[t.Name()] = nil // guards against recursion
+		g.defs[t.Name()] = g.structSchema(t)
+	}
+	return map[string]any{"$ref": "#/$defs/" + t.Name()}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for _, f := range schemaFields(t) {
+		s := g.typeSchema(f.typ)
+		key := t.Name() + "." + f.name
+		if d, ok := fieldDescriptions[key]; ok {
+			s["description"] = d
+		}
+		switch key {
+		case "Container.restart":
+			s["pattern"] = restartPattern
+		case "Container.ipc":
+			s["pattern"] = ipcPattern
+		case "Schedule.concurrencyPolicy":
+			s["enum"] = []string{ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace}
+		case "Manifest.kind", "Container.type":
+			s["enum"] = []string{KindService, KindJob}
+		case "ExecHook.onFailure", "PreStartHook.onFailure":
+			s["enum"] = []string{HookAbort, HookContinue}
+		case "Container.pullPolicy":
+			s["enum"] = pullPolicies
+		case "NamedVolume.retention":
+			s["enum"] = []string{RetainKeep, RetainDelete}
+		case "longPort.protocol":
+			s["enum"] = protocols
+		case "longPort.target", "longPort.published":
+			s["type"] = []string{"string", "integer"}
+		case "Container.name":
+			s["pattern"] = containerNamePattern.String()
+		case "Container.environment":
+			s["propertyNames"] = map[string]any{"pattern": envNamePattern.String()}
+		case "Capabilities.add", "Capabilities.drop":
+			s["items"] = map[string]any{"type": "string", "pattern": capabilityPattern}
+		case "Container.options":
+			s["items"] = map[string]any{"type": "string", "pattern": optionPattern()}
+		}
+		props[f.name] = s
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+type schemaField struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+// schemaFields lists the yaml fields of t in declaration order. Fields
+// without omitempty are required.
+func schemaFields(t reflect.Type) []schemaField {
+	var res []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		res = append(res, schemaField{name: name, typ: f.Type, required: !strings.Contains(opts, "omitempty")})
+	}
+	return res
+}
+
+func optionPattern() string {
+	flags := make([]string, len(supportedOptions))
+	for i, f := range supportedOptions {
+		flags[i] = regexp.QuoteMeta(f)
+	}
+	return `^\s*(` + strings.Join(flags, "|") + `)([=\s].*)?$`
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSchemaDescriptions fails when a manifest field is added without a
+// description, or a description is left behind for a removed field.
+func TestSchemaDescriptions(t *testing.T) {
+	seen := map[string]bool{}
+	var walk func(t reflect.Type)
+	walk = func(typ reflect.Type) {
+		switch typ.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Pointer:
+			walk(typ.Elem())
+			return
+		case reflect.Struct:
+		default:
+			return
+		}
+		for _, f := range schemaFields(typ) {
+			key := typ.Name() + "." + f.name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if fieldDescriptions[key] == "" {
+				t.Errorf("missing description for %s", key)
+			}
+			walk(f.typ)
+		}
+	}
+	walk(manifestType)
+	walk(longPortType)
+
+	for key := range fieldDescriptions {
+		if !seen[key] {
+			t.Errorf("description for unknown field %s", key)
+		}
+	}
+}
+
+func TestSupportedOptions(t *testing.T) {
+	for _, flag := range supportedOptions {
+		var c Container
+		err := c.applyOption(flag, "x")
+		if err != nil && strings.Contains(err.Error(), "unknown option") {
+			t.Errorf("%s is listed as supported but rejected: %v", flag, err)
+		}
+	}
+}
+
+// TestSchemaUpToDate keeps the committed manifest.schema.json in sync with
+// the Go types. Run `make schema` to regenerate it.
+func TestSchemaUpToDate(t *testing.T) {
+	want, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../manifest.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("manifest.schema.json is out of date, run `make schema`")
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ByteSize
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "1024", want: 1024},
+		{in: "512b", want: 512},
+		{in: "1k", want: KiB},
+		{in: "1K", want: KiB},
+		{in: "1kb", want: KiB},
+		{in: "1KiB", want: KiB},
+		{in: "512m", want: 512 * MiB},
+		{in: "512MiB", want: 512 * MiB},
+		{in: "512 MB", want: 512 * MiB},
+		{in: "2g", want: 2 * GiB},
+		{in: "2GiB", want: 2 * GiB},
+		{in: "1.5g", want: GiB + 512*MiB},
+		{in: "0.5k", want: 512},
+		{in: " 64m ", want: 64 * MiB},
+		{in: "1t", want: TiB},
+		{in: "", wantErr: true},
+		{in: "m", wantErr: true},
+		{in: "-1m", wantErr: true},
+		{in: "512x", wantErr: true},
+		{in: "512mm", wantErr: true},
+		{in: "1.2.3m", wantErr: true},
+		{in: "ten", wantErr: true},
+		{in: "99999999999t", wantErr: true},
+		{in: "9223372036854775807k", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseByteSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestByteSize_UnmarshalYAML(t *testing.T) {
+	var r Resources
+	if err := yaml.Unmarshal([]byte("memory: 512m\nshmSize: 67108864\n"), &r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Memory != 512*MiB {
+		t.Errorf("expected memory 512MiB, got %s", r.Memory)
+	}
+	if r.ShmSize != 64*MiB {
+		t.Errorf("expected shmSize 64MiB, got %s", r.ShmSize)
+	}
+
+	if err := yaml.Unmarshal([]byte("memory: lots\n"), &r); err == nil {
+		t.Error("expected error for garbage size")
+	}
+}
+
+func TestByteSize_String(t *testing.T) {
+	tests := map[ByteSize]string{
+		0:             "0B",
+		100:           "100B",
+		2 * KiB:       "2KiB",
+		512 * MiB:     "512MiB",
+		3 * GiB:       "3GiB",
+		GiB + 512*MiB: "1536MiB",
+		MiB + 1:       "1048577B",
+	}
+	for in, want := range tests {
+		if got := in.String(); got != want {
+			t.Errorf("ByteSize(%d).String() = %q, want %q", int64(in), got, want)
+		}
+	}
+}
+
+func TestValidate_MemoryMinimum(t *testing.T) {
+	c := Container{Name: "app", Host: "host1", Image: "nginx", Options: []string{"--memory=512"}}
+	if err := c.Validate(); err == nil {
+		t.Error("expected error for memory below the docker minimum")
+	}
+
+	c.Options = []string{"--memory=512m"}
+	if err := c.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Volume struct {
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"readOnly,omitempty"`
+}
+
+type Resources struct {
+	Memory  ByteSize `yaml:"memory,omitempty"`
+	CPUs    float64  `yaml:"cpus,omitempty"`
+	ShmSize ByteSize `yaml:"shmSize,omitempty"`
+}
+
+type Capabilities struct {
+	Add  []string `yaml:"add,omitempty"`
+	Drop []string `yaml:"drop,omitempty"`
+}
+
+type Ulimit struct {
+	Name string `yaml:"name"`
+	Soft int64  `yaml:"soft"`
+	Hard int64  `yaml:"hard"`
+}
+
+// minMemory is the smallest memory limit the docker daemon accepts.
+const minMemory = 6 * MiB
+
+var restartPolicies = []string{"no", "always", "unless-stopped", "on-failure"}
+
+// UnmarshalYAML accepts both the long form and the docker short form
+// "source:target[:ro|rw]".
+func (v *Volume) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		vol, err := ParseVolume(node.Value)
+		if err != nil {
+			return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %v", node.Line, err)}}
+		}
+		*v = vol
+		return nil
+	}
+
+	type plain Volume
+	return node.Decode((*plain)(v))
+}
+
+func ParseVolume(s string) (Volume, error) {
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 2:
+		return Volume{Source: parts[0], Target: parts[1]}, nil
+	case 3:
+		switch parts[2] {
+		case "ro":
+			return Volume{Source: parts[0], Target: parts[1], ReadOnly: true}, nil
+		case "rw":
+			return Volume{Source: parts[0], Target: parts[1]}, nil
+		}
+		return Volume{}, fmt.Errorf("invalid volume mode %q in %q", parts[2], s)
+	}
+	return Volume{}, fmt.Errorf("invalid volume %q, expected source:target[:ro|rw]", s)
+}
+
+func (v Volume) String() string {
+	if v.ReadOnly {
+		return v.Source + ":" + v.Target + ":ro"
+	}
+	return v.Source + ":" + v.Target
+}
+
+// ParseRestart splits a restart policy such as "on-failure:3" into its mode
+// and maximum retry count.
+func ParseRestart(s string) (string, int, error) {
+	mode, count, hasCount := strings.Cut(s, ":")
+	valid := false
+	for _, p := range restartPolicies {
+		if mode == p {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", 0, fmt.Errorf("invalid restart policy %q, expected one of %s", s, strings.Join(restartPolicies, ", "))
+	}
+	if !hasCount {
+		return mode, 0, nil
+	}
+	if mode != "on-failure" {
+		return "", 0, fmt.Errorf("restart policy %q does not accept a retry count", mode)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("invalid retry count in restart policy %q", s)
+	}
+	return mode, n, nil
+}
+
+func (c *Container) validateSpec(v *validator) {
+	for i, p := range c.Ports {
+		if _, err := ParsePort(p); err != nil {
+			v.add(index("ports", i), "%v", err)
+		}
+	}
+
+	for i, vol := range c.Volumes {
+		if vol.Source == "" {
+			v.add(index("volumes", i)+".source", "field is required")
+		}
+		if !path.IsAbs(vol.Target) {
+			v.add(index("volumes", i)+".target", "must be an absolute path")
+		}
+	}
+
+	switch {
+	case c.Resources.Memory < 0:
+		v.add("resources.memory", "cannot be negative")
+	case c.Resources.Memory > 0 && c.Resources.Memory < minMemory:
+		v.add("resources.memory", "must be at least %s, got %s", ByteSize(minMemory), c.Resources.Memory)
+	}
+	if c.Resources.CPUs < 0 {
+		v.add("resources.cpus", "cannot be negative")
+	}
+	if c.Resources.ShmSize < 0 {
+		v.add("resources.shmSize", "cannot be negative")
+	}
+
+	if c.Restart != "" {
+		if _, _, err := ParseRestart(c.Restart); err != nil {
+			v.add("restart", "%v", err)
+		}
+	}
+
+	if c.PullPolicy != "" && !validPullPolicy(c.PullPolicy) {
+		v.add("pullPolicy", "invalid pull policy %q, expected one of %s", c.PullPolicy, strings.Join(pullPolicies, ", "))
+	}
+
+	if c.Network != "" && len(c.Networks) > 0 {
+		v.add("networks", "cannot be combined with network %q", c.Network)
+	}
+
+	for i, cp := range c.Capabilities.Add {
+		if !validCapability(cp) {
+			v.add(index("capabilities.add", i), "invalid capability %q", cp)
+		}
+	}
+	for i, cp := range c.Capabilities.Drop {
+		if !validCapability(cp) {
+			v.add(index("capabilities.drop", i), "invalid capability %q", cp)
+		}
+	}
+
+	for i, u := range c.Ulimits {
+		if u.Name == "" {
+			v.add(index("ulimits", i)+".name", "field is required")
+		}
+		if u.Soft > u.Hard {
+			v.add(index("ulimits", i), "soft limit %d exceeds hard limit %d", u.Soft, u.Hard)
+		}
+	}
+
+	for i, ip := range c.DNS {
+		if net.ParseIP(ip) == nil {
+			v.add(index("dns", i), "invalid dns server %q", ip)
+		}
+	}
+
+	for k := range c.Labels {
+		if k == "" {
+			v.add("labels", "keys cannot be empty")
+		}
+	}
+
+	if c.WorkDir != "" && !path.IsAbs(c.WorkDir) {
+		v.add("workdir", "must be an absolute path")
+	}
+
+	for i, h := range c.ExtraHosts {
+		name, ip, ok := strings.Cut(h, ":")
+		if !ok || name == "" || ip == "" {
+			v.add(index("extraHosts", i), "invalid extra host %q, expected name:ip", h)
+		}
+	}
+
+	for mount := range c.Tmpfs {
+		if !path.IsAbs(mount) {
+			v.add("tmpfs."+mount, "path must be absolute")
+		}
+	}
+}
+
+func validCapability(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s == "ALL" {
+		return true
+	}
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// ContainerStats is a sample of the resource usage of a container, as
+// reported by the slave. Network and block I/O are totals since the
+// container started.
+type ContainerStats struct {
+	Time time.Time
+	// CPUPercent is the CPU used since the previous sample, where 100 is one
+	// full CPU. It is zero in the first sample of a container.
+	CPUPercent  float64
+	MemoryUsage ByteSize
+	// MemoryLimit is the memory the container may use: the memory of the
+	// host for a Docker container without a limit, zero when unknown.
+	MemoryLimit ByteSize `json:",omitempty"`
+	NetworkRx   ByteSize
+	NetworkTx   ByteSize
+	BlockRead   ByteSize
+	BlockWrite  ByteSize
+}
+
+// StatsWindow is the recent resource usage of a container kept by the
+// master, oldest sample first.
+type StatsWindow struct {
+	ManifestName string
+	Host         string
+	Name         string
+	Samples      []ContainerStats
+}
@@ -11,10 +11,81 @@ const (
 	StateRemoving   ContainerState = "removing"
 	StateExited     ContainerState = "exited"
 	StateDead       ContainerState = "dead"
+
+	// StateRemoved is reported by the slave once the container of a
+	// container in state removing is gone.
+	StateRemoved ContainerState = "removed"
+
+	// StatePulling is reported by the slave while it pulls the image of a
+	// new container.
+	StatePulling ContainerState = "pulling"
+
+	// StateScheduled is the state of a scheduled container, which never runs
+	// itself: the master adds a run of it at every activation.
+	StateScheduled ContainerState = "scheduled"
+
+	// Final states of a job.
+	StateSucceeded ContainerState = "succeeded"
+	StateFailed    ContainerState = "failed"
 )
 
 type ContainerStatus struct {
 	ManifestName string
 	Config       Container
 	State        ContainerState
+
+	// Endpoints are the host ports actually bound by docker, as reported by
+	// the slave once the container runs, e.g. "0.0.0.0:32768->80/tcp".
+	Endpoints []string `json:",omitempty"`
+
+	// Secrets holds resolved secret values by name. The master fills it in
+	// only for the slave that hosts the container; it is never stored.
+	Secrets map[string]string `json:",omitempty"`
+
+	// Configs holds the content of named configs referenced by the
+	// container, resolved by the master for the hosting slave.
+	Configs map[string]string `json:",omitempty"`
+
+	// RegistryAuth holds the credentials for the registry of the image, if
+	// the master has any. Like Secrets, it is only filled in for the hosting
+	// slave.
+	RegistryAuth *RegistryAuth `json:",omitempty"`
+
+	// HookRegistryAuth holds the credentials for the registries of the
+	// images of preStart hooks, by registry. It is filled in like
+	// RegistryAuth.
+	HookRegistryAuth map[string]*RegistryAuth `json:",omitempty"`
+
+	// Pull is the outcome of the last pull of the image, and PullProgress
+	// the progress of the pull running in state pulling.
+	Pull         *PullResult   `json:",omitempty"`
+	PullProgress *PullProgress `json:",omitempty"`
+
+	// Networks are the manifest networks the container is attached to, as
+	// they are created on the host.
+	Networks []Network `json:",omitempty"`
+
+	// Volumes are the manifest volumes the container mounts, as they are
+	// created on the host, and VolumeUsage is their state reported by the
+	// slave.
+	Volumes     []NamedVolume `json:",omitempty"`
+	VolumeUsage []VolumeUsage `json:",omitempty"`
+	// ManifestDown is set on the containers of a manifest taken down. Only
+	// then are the volumes with the delete retention policy removed.
+	ManifestDown bool `json:",omitempty"`
+
+	// Stats is the recent resource usage reported by the slave, oldest
+	// first.
+	Stats []ContainerStats `json:",omitempty"`
+
+	// Hooks holds the last result of every lifecycle hook of the container.
+	Hooks []HookResult `json:",omitempty"`
+
+	// Job is the progress of a container of type job.
+	Job *JobStatus `json:",omitempty"`
+
+	// Cron tracks the activations of a scheduled container, and CronJob
+	// names the scheduled container a run was created from.
+	Cron    *CronState `json:",omitempty"`
+	CronJob string     `json:",omitempty"`
 }
@@ -0,0 +1,96 @@
+package config
+
+const LabelVolume = "mtrpz.volume"
+
+// Retention policies of named volumes.
+const (
+	RetainKeep   = "keep"
+	RetainDelete = "delete"
+)
+
+// NamedVolume is a volume declared by a manifest. The slave creates it before
+// starting the first container mounting it, i.e. listing its name as a
+// volume source. On manifest down it is kept unless Retention is "delete".
+type NamedVolume struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver,omitempty"`
+	DriverOpts map[string]string `yaml:"driverOpts,omitempty"`
+	Labels     map[string]string `yaml:"labels,omitempty"`
+	Retention  string            `yaml:"retention,omitempty"`
+}
+
+// VolumeUsage is the state of a named volume on the host, as reported by the
+// slave.
+type VolumeUsage struct {
+	Name   string
+	Exists bool
+	Size   ByteSize
+}
+
+func (n *NamedVolume) Validate() error {
+	var v validator
+	if n.Name == "" {
+		v.add("name", "field is required")
+	} else if !validContainerName(n.Name) {
+		v.add("name", "invalid volume name %q, must match [a-zA-Z0-9][a-zA-Z0-9_.-]+", n.Name)
+	}
+	switch n.Retention {
+	case "", RetainKeep, RetainDelete:
+	default:
+		v.add("retention", "invalid retention %q, expected %s or %s", n.Retention, RetainKeep, RetainDelete)
+	}
+	return v.err()
+}
+
+// VolumeName returns the docker name of the volume declared as name by
+// manifest.
+func VolumeName(manifest, name string) string {
+	return manifest + "_" + name
+}
+
+// ContainerVolumes returns the named volumes c mounts, named and labelled as
+// they are created on the host. The manifest-local name is kept in the
+// LabelVolume label.
+func (m *Manifest) ContainerVolumes(c *Container) []NamedVolume {
+	var res []NamedVolume
+	seen := map[string]bool{}
+	for _, mount := range c.Volumes {
+		for _, n := range m.Volumes {
+			if n.Name != mount.Source || seen[n.Name] {
+				continue
+			}
+			seen[n.Name] = true
+
+			labels := make(map[string]string, len(n.Labels)+2)
+			for k, v := range n.Labels {
+				labels[k] = v
+			}
+			labels[LabelManifest] = m.Name
+			labels[LabelVolume] = n.Name
+			retention := n.Retention
+			if retention == "" {
+				retention = RetainKeep
+			}
+			res = append(res, NamedVolume{
+				Name:       VolumeName(m.Name, n.Name),
+				Driver:     n.Driver,
+				DriverOpts: n.DriverOpts,
+				Labels:     labels,
+				Retention:  retention,
+			})
+		}
+	}
+	return res
+}
+
+func (m *Manifest) validateVolumes(v *validator) {
+	declared := make(map[string]bool, len(m.Volumes))
+	for i := range m.Volumes {
+		n := &m.Volumes[i]
+		v.merge(index("volumes", i), n.Validate())
+		if declared[n.Name] {
+			v.add(index("volumes", i)+".name", "duplicate volume name %q", n.Name)
+		}
+		declared[n.Name] = true
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifestValidate_Volumes(t *testing.T) {
+	m := Manifest{
+		Name: "shop",
+		Volumes: []NamedVolume{
+			{Name: "data"},
+			{Name: "data"},
+			{Name: "cache", Retention: "forever"},
+		},
+		Containers: []Container{{Name: "db", Host: "node1", Image: "postgres"}},
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	paths := map[string]bool{}
+	for _, e := range err.(ValidationError) {
+		paths[e.Path] = true
+	}
+	for _, p := range []string{"volumes[1].name", "volumes[2].retention"} {
+		if !paths[p] {
+			t.Errorf("missing error at %s, got %v", p, err)
+		}
+	}
+}
+
+func TestContainerVolumes(t *testing.T) {
+	m := Manifest{
+		Name: "shop",
+		Volumes: []NamedVolume{
+			{Name: "data", Driver: "local", DriverOpts: map[string]string{"type": "nfs"}, Retention: RetainDelete},
+			{Name: "unused"},
+		},
+	}
+	c := Container{Volumes: []Volume{
+		{Source: "data", Target: "/var/lib/postgresql/data"},
+		{Source: "/etc/ssl", Target: "/etc/ssl"},
+		{Source: "data", Target: "/backup", ReadOnly: true},
+	}}
+
+	want := []NamedVolume{{
+		Name:       "shop_data",
+		Driver:     "local",
+		DriverOpts: map[string]string{"type": "nfs"},
+		Labels:     map[string]string{LabelManifest: "shop", LabelVolume: "data"},
+		Retention:  RetainDelete,
+	}}
+	if got := m.ContainerVolumes(&c); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
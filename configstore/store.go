@@ -0,0 +1,171 @@
+package configstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("config not found")
+	ErrInvalidName = errors.New("invalid config name")
+)
+
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int       `json:"size"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type entry struct {
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]entry
+}
+
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		entries: make(map[string]entry),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Set stores content under name and reports whether an existing config's
+// content actually changed.
+func (s *Store) Set(name, content string) (bool, error) {
+	if !ValidName(name) {
+		return false, ErrInvalidName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.entries[name]
+	if existed && prev.Content == content {
+		return false, nil
+	}
+	s.entries[name] = entry{Content: content, UpdatedAt: time.Now().UTC()}
+
+	if err := s.save(); err != nil {
+		if existed {
+			s.entries[name] = prev
+		} else {
+			delete(s.entries, name)
+		}
+		return false, err
+	}
+	return existed, nil
+}
+
+func (s *Store) Get(name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.entries[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return e.Content, nil
+}
+
+func (s *Store) Has(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.entries[name]
+	return ok
+}
+
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.entries[name]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, name)
+
+	if err := s.save(); err != nil {
+		s.entries[name] = prev
+		return err
+	}
+	return nil
+}
+
+func (s *Store) List() []Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Info, 0, len(s.entries))
+	for name, e := range s.entries {
+		res = append(res, Info{Name: name, Size: len(e.Content), UpdatedAt: e.UpdatedAt})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// ValidName reports whether name can name a config. Names are used as file
+// names on the slaves, so "." and ".." are rejected.
+func ValidName(name string) bool {
+	if name == "" || len(name) > 253 || strings.Trim(name, ".") == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
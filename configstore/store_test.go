@@ -0,0 +1,96 @@
+package configstore_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmerezha/mtrpz-lab4/configstore"
+)
+
+func TestStore_SetReportsChange(t *testing.T) {
+	store, err := configstore.NewStore(filepath.Join(t.TempDir(), "configs.json"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	changed, err := store.Set("nginx.conf", "worker_processes 1;")
+	if err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+	if changed {
+		t.Error("expected new config not to be reported as changed")
+	}
+
+	changed, err = store.Set("nginx.conf", "worker_processes 1;")
+	if err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+	if changed {
+		t.Error("expected identical content not to be reported as changed")
+	}
+
+	changed, err = store.Set("nginx.conf", "worker_processes 2;")
+	if err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+	if !changed {
+		t.Error("expected new content to be reported as changed")
+	}
+}
+
+func TestStore_PersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "configs.json")
+	store, err := configstore.NewStore(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	if _, err := store.Set("app.ini", "debug=false"); err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+
+	reopened, err := configstore.NewStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	content, err := reopened.Get("app.ini")
+	if err != nil {
+		t.Fatalf("failed to get config: %v", err)
+	}
+	if content != "debug=false" {
+		t.Errorf("expected 'debug=false', got %q", content)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	store, err := configstore.NewStore(filepath.Join(t.TempDir(), "configs.json"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	if _, err := store.Set("a", "x"); err != nil {
+		t.Fatalf("failed to set config: %v", err)
+	}
+
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("failed to delete config: %v", err)
+	}
+	if _, err := store.Get("a"); !errors.Is(err, configstore.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if len(store.List()) != 0 {
+		t.Errorf("expected empty list, got %+v", store.List())
+	}
+}
+
+func TestStore_InvalidName(t *testing.T) {
+	store, err := configstore.NewStore(filepath.Join(t.TempDir(), "configs.json"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	for _, name := range []string{"", "has space", "../etc", ".", ".."} {
+		if _, err := store.Set(name, "x"); !errors.Is(err, configstore.ErrInvalidName) {
+			t.Errorf("Set(%q): expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package listener
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/runner"
+	"github.com/rmerezha/mtrpz-lab4/stream"
+)
+
+// ExecListener long-polls the master for exec requests and runs them,
+// relaying their input and output over a connection the slave opens to the
+// master.
+type ExecListener struct {
+	MasterURL string
+	Host      string
+	Runner    runner.Runner
+	Token     string
+
+	// retryInterval is the pause after a failed poll.
+	retryInterval time.Duration
+}
+
+func NewExecListener(masterURL, host string, r runner.Runner, interval time.Duration, token string) *ExecListener {
+	return &ExecListener{
+		MasterURL:     masterURL,
+		Host:          host,
+		Runner:        r,
+		Token:         token,
+		retryInterval: interval,
+	}
+}
+
+func (el *ExecListener) Listen(stopCh <-chan struct{}) {
+	ctx, cancel := stopContext(stopCh)
+	defer cancel()
+
+	for ctx.Err() == nil {
+		var req config.ExecRequest
+		ok, err := longPoll(ctx, el.MasterURL+"/api/v1/exec/poll?host="+url.QueryEscape(el.Host), el.Token, &req)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("ExecListener: failed to poll for exec requests: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(el.retryInterval):
+			}
+			continue
+		}
+		if ok {
+			go el.serve(ctx, req)
+		}
+	}
+}
+
+func (el *ExecListener) serve(ctx context.Context, req config.ExecRequest) {
+	conn, err := el.attach(ctx, req.ID)
+	if err != nil {
+		log.Printf("ExecListener: failed to attach to exec request for %s: %v", req.Container, err)
+		return
+	}
+	defer conn.Close()
+	out := stream.NewWriter(conn)
+
+	log.Printf("ExecListener: running %q in %s", req.Cmd, req.Container)
+	s, err := el.Runner.ExecSession(ctx, req.Container, req.Cmd, req.TTY)
+	if err != nil {
+		_ = out.WriteFrame(stream.Error, []byte(err.Error()))
+		return
+	}
+	defer s.Close()
+
+	// sessCtx is cancelled when the client hangs up or the slave stops.
+	sessCtx, hangUp := context.WithCancel(ctx)
+	defer hangUp()
+	stop := context.AfterFunc(sessCtx, func() {
+		conn.Close()
+		s.Close()
+	})
+	defer stop()
+
+	go func() {
+		defer hangUp()
+		for {
+			typ, p, err := stream.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			switch typ {
+			case stream.Stdin:
+				if len(p) == 0 {
+					err = s.CloseStdin()
+				} else {
+					_, err = s.Write(p)
+				}
+			case stream.Resize:
+				var width, height uint16
+				if width, height, err = stream.ParseResize(p); err == nil {
+					err = s.Resize(uint(width), uint(height))
+				}
+			}
+			if err != nil {
+				log.Printf("ExecListener: session in %s: %v", req.Container, err)
+			}
+		}
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.Read(buf)
+		if n > 0 {
+			if out.WriteFrame(stream.Output, buf[:n]) != nil {
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	code, err := s.Wait(sessCtx)
+	if err != nil {
+		_ = out.WriteFrame(stream.Error, []byte(err.Error()))
+		return
+	}
+	_ = out.WriteExit(code)
+}
+
+// attach opens the connection for the exec request to the master.
+func (el *ExecListener) attach(ctx context.Context, id string) (io.ReadWriteCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", el.MasterURL+"/api/v1/exec/attach?id="+url.QueryEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+el.Token)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", stream.Upgrade)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.Body.(io.ReadWriteCloser), nil
+}
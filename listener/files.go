@@ -0,0 +1,104 @@
+package listener
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+const (
+	defaultSecretMode = 0400
+	defaultConfigMode = 0444
+)
+
+// prepare turns the desired state received from the master into the config
+// handed to the runner: secrets become env vars or files, and configs become
+// files, bind-mounted read-only from the slave's data directory. Manifest
+// networks and volumes are replaced with the names they have on the host.
+func (pl *PollingListener) prepare(cs config.ContainerStatus) (config.Container, error) {
+	c := cs.Config
+	c.Networks = networkNames(cs)
+	c.Volumes = mountVolumes(cs)
+	if len(c.Secrets) == 0 && len(c.Configs) == 0 {
+		return c, nil
+	}
+
+	env := make(map[string]string, len(c.Environment)+len(c.Secrets))
+	for k, v := range c.Environment {
+		env[k] = v
+	}
+	volumes := append([]config.Volume(nil), c.Volumes...)
+
+	for _, ref := range c.Secrets {
+		val, ok := cs.Secrets[ref.Name]
+		if !ok {
+			return c, fmt.Errorf("secret %q was not delivered by master", ref.Name)
+		}
+		if ref.Env != "" {
+			env[ref.Env] = val
+			continue
+		}
+
+		mode := os.FileMode(ref.Mode)
+		if mode == 0 {
+			mode = defaultSecretMode
+		}
+		path := filepath.Join(pl.containerDir(c.Name), "secrets", ref.Name)
+		if err := writeFile(path, []byte(val), mode); err != nil {
+			return c, fmt.Errorf("secret %q: %w", ref.Name, err)
+		}
+		volumes = append(volumes, config.Volume{Source: path, Target: ref.Target, ReadOnly: true})
+	}
+
+	for i, f := range c.Configs {
+		content, key := f.Content, fmt.Sprintf("inline-%d", i)
+		if f.Name != "" {
+			var ok bool
+			if content, ok = cs.Configs[f.Name]; !ok {
+				return c, fmt.Errorf("config %q was not delivered by master", f.Name)
+			}
+			key = f.Name
+		}
+
+		mode := os.FileMode(f.Mode)
+		if mode == 0 {
+			mode = defaultConfigMode
+		}
+		path := filepath.Join(pl.containerDir(c.Name), "configs", key)
+		if err := writeFile(path, []byte(content), mode); err != nil {
+			return c, fmt.Errorf("config for %s: %w", f.Target, err)
+		}
+		volumes = append(volumes, config.Volume{Source: path, Target: f.Target, ReadOnly: true})
+	}
+
+	c.Environment = env
+	c.Volumes = volumes
+	return c, nil
+}
+
+func (pl *PollingListener) cleanup(name string) {
+	if err := os.RemoveAll(pl.containerDir(name)); err != nil {
+		log.Printf("PollingListener: failed to clean up files for %s: %v", name, err)
+	}
+}
+
+func (pl *PollingListener) containerDir(name string) string {
+	return filepath.Join(pl.DataDir, "containers", name)
+}
+
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
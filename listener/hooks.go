@@ -0,0 +1,184 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/runner"
+)
+
+const (
+	defaultExecHookTimeout = 30 * time.Second
+	defaultPreStartTimeout = 5 * time.Minute
+)
+
+// runPreStart runs the preStart containers of c in order and reports
+// whether the container may be started.
+func (pl *PollingListener) runPreStart(ctx context.Context, cs config.ContainerStatus, c config.Container) bool {
+	for i, h := range c.PreStart {
+		hc := preStartContainer(c, i, h)
+		res, err := pl.runPreStartContainer(ctx, cs, hc, h)
+		if !pl.reportHook(ctx, cs, index("preStart", i), res, err) && h.Aborts() {
+			return false
+		}
+	}
+	return true
+}
+
+// runPreStartContainer pulls the image of a preStart hook of its own with
+// the pull policy of the container, then runs the hook container. A failed
+// pull is the failure of the hook. The timeout of the hook only covers the
+// run.
+func (pl *PollingListener) runPreStartContainer(ctx context.Context, cs config.ContainerStatus, hc config.Container, h config.PreStartHook) (runner.Result, error) {
+	if h.Image != "" {
+		_, err := pl.Runner.PullImage(ctx, hc.Image, runner.PullOptions{
+			Auth:   cs.HookRegistryAuth[config.ImageRegistry(hc.Image)],
+			Policy: hc.EffectivePullPolicy(),
+		})
+		if err != nil {
+			return runner.Result{}, fmt.Errorf("pull %s: %w", hc.Image, err)
+		}
+	}
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = defaultPreStartTimeout
+	}
+	hctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res, err := pl.Runner.RunOnce(hctx, hc)
+	return res, timedOut(err, timeout)
+}
+
+// runPostStart runs the postStart hook and reports whether the container
+// may keep running.
+func (pl *PollingListener) runPostStart(ctx context.Context, cs config.ContainerStatus) bool {
+	h := cs.Config.PostStart
+	if h == nil {
+		return true
+	}
+	return pl.runExecHook(ctx, cs, "postStart", h) || !h.Aborts()
+}
+
+// runPreStop runs the preStop hook if the container is running. Its failure
+// is reported but never prevents the stop.
+func (pl *PollingListener) runPreStop(ctx context.Context, cs config.ContainerStatus) {
+	h := cs.Config.PreStop
+	if h == nil {
+		return
+	}
+	if state, err := pl.Runner.State(ctx, cs.Config.Name); err != nil || config.ContainerState(state) != config.StateRunning {
+		return
+	}
+	pl.runExecHook(ctx, cs, "preStop", h)
+}
+
+func (pl *PollingListener) runExecHook(ctx context.Context, cs config.ContainerStatus, hook string, h *config.ExecHook) bool {
+	args, err := h.Command.Args()
+	if err != nil {
+		return pl.reportHook(ctx, cs, hook, runner.Result{}, err)
+	}
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = defaultExecHookTimeout
+	}
+	hctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res, err := pl.Runner.Exec(hctx, cs.Config.Name, args)
+	return pl.reportHook(ctx, cs, hook, res, timedOut(err, timeout))
+}
+
+// timedOut words an expired deadline for the master.
+func timedOut(err error, timeout time.Duration) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// preStartContainer derives the one-shot container of a preStart hook from
+// the prepared container: same environment, volumes and networks, no ports.
+func preStartContainer(c config.Container, i int, h config.PreStartHook) config.Container {
+	hc := c
+	hc.Name = fmt.Sprintf("%s-prestart-%d", c.Name, i)
+	if h.Image != "" {
+		hc.Image = h.Image
+		hc.Entrypoint, hc.Cmd = "", ""
+	}
+	if h.Command != "" {
+		hc.Cmd = h.Command
+	}
+	hc.Environment = make(map[string]string, len(c.Environment)+len(h.Environment))
+	for k, v := range c.Environment {
+		hc.Environment[k] = v
+	}
+	for k, v := range h.Environment {
+		hc.Environment[k] = v
+	}
+	hc.Ports = nil
+	hc.Restart = ""
+	hc.Hostname = ""
+	hc.PreStart, hc.PostStart, hc.PreStop = nil, nil, nil
+	return hc
+}
+
+// reportHook sends the result of a hook to the master and reports whether
+// the hook succeeded.
+func (pl *PollingListener) reportHook(ctx context.Context, cs config.ContainerStatus, hook string, res runner.Result, err error) bool {
+	result := config.HookResult{
+		Hook:     hook,
+		ExitCode: res.ExitCode,
+		Output:   res.Output,
+		Time:     time.Now(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if result.Failed() {
+		log.Printf("PollingListener: %s hook of %s failed: exit code %d, error %q", hook, cs.Config.Name, result.ExitCode, result.Error)
+	}
+
+	body := struct {
+		Host          string            `json:"host"`
+		ContainerName string            `json:"name"`
+		Result        config.HookResult `json:"result"`
+	}{pl.Host, cs.Config.Name, result}
+	if err := pl.post(ctx, "/api/v1/hook", body); err != nil {
+		log.Printf("PollingListener: failed to report hook: %v", err)
+	}
+	return !result.Failed()
+}
+
+// post sends body as JSON to the master.
+func (pl *PollingListener) post(ctx context.Context, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", pl.MasterURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+pl.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func index(field string, i int) string {
+	return fmt.Sprintf("%s[%d]", field, i)
+}
@@ -1,5 +1,21 @@
 package listener
 
+import "context"
+
 type Listener interface {
 	Listen(stopCh <-chan struct{})
 }
+
+// stopContext returns a context cancelled when stopCh is closed, so that
+// in-flight operations are abandoned on shutdown.
+func stopContext(stopCh <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
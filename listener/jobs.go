@@ -0,0 +1,118 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/runner"
+)
+
+const jobRetryDelay = 10 * time.Second
+
+// runJob runs the job container until it succeeds, its retries are used up
+// or its deadline expires, and reports the progress to the master. It gives
+// up silently when the desired state of the container changes meanwhile or
+// ctx is cancelled.
+func (pl *PollingListener) runJob(ctx context.Context, cs config.ContainerStatus, c config.Container) {
+	name := c.Name
+	var spec config.Job
+	if c.Job != nil {
+		spec = *c.Job
+	}
+	status := config.JobStatus{Started: time.Now()}
+	var deadline time.Time
+	if spec.Deadline > 0 {
+		deadline = status.Started.Add(spec.Deadline)
+	}
+
+	for {
+		status.Attempts++
+		pl.Store.Set(name, config.StateRunning)
+		pl.reportJob(ctx, name, config.StateRunning, status)
+
+		res, err := pl.runAttempt(ctx, cs, c, deadline)
+		if ctx.Err() != nil {
+			return
+		}
+		status.ExitCode = res.ExitCode
+		status.Error = ""
+		if err != nil {
+			status.Error = err.Error()
+		}
+		if state, _ := pl.Store.Get(name); state != config.StateRunning {
+			return
+		}
+		if err == nil && res.ExitCode == 0 {
+			pl.finishJob(ctx, name, spec, config.StateSucceeded, status)
+			return
+		}
+
+		log.Printf("PollingListener: job %s attempt %d failed: exit code %d, error %q", name, status.Attempts, res.ExitCode, status.Error)
+		if status.Attempts > spec.Retries {
+			pl.finishJob(ctx, name, spec, config.StateFailed, status)
+			return
+		}
+		if !deadline.IsZero() && time.Now().Add(jobRetryDelay).After(deadline) {
+			status.Error = "deadline exceeded"
+			pl.finishJob(ctx, name, spec, config.StateFailed, status)
+			return
+		}
+		select {
+		case <-time.After(jobRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+		if state, _ := pl.Store.Get(name); state != config.StateRunning {
+			return
+		}
+		if err := pl.Runner.Remove(ctx, name); err != nil {
+			log.Printf("Runner.Remove error for %s: %v", name, err)
+		}
+	}
+}
+
+// runAttempt runs the job container once, killing it at deadline unless
+// deadline is zero.
+func (pl *PollingListener) runAttempt(ctx context.Context, cs config.ContainerStatus, c config.Container, deadline time.Time) (runner.Result, error) {
+	if err := pl.Runner.Run(ctx, c); err != nil {
+		return runner.Result{}, err
+	}
+	if !pl.runPostStart(ctx, cs) {
+		_ = pl.Runner.Kill(ctx, c.Name)
+		return runner.Result{}, errors.New("postStart hook failed")
+	}
+	if deadline.IsZero() {
+		return pl.Runner.Wait(ctx, c.Name)
+	}
+	timeout := time.Until(deadline)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	res, err := pl.Runner.Wait(ctx, c.Name)
+	return res, timedOut(err, timeout)
+}
+
+func (pl *PollingListener) finishJob(ctx context.Context, name string, spec config.Job, state config.ContainerState, status config.JobStatus) {
+	status.Finished = time.Now()
+	if spec.AutoRemove {
+		if err := pl.Runner.Remove(ctx, name); err != nil {
+			log.Printf("Runner.Remove error for %s: %v", name, err)
+		}
+	}
+	pl.Store.Set(name, state)
+	pl.reportJob(ctx, name, state, status)
+}
+
+func (pl *PollingListener) reportJob(ctx context.Context, name string, state config.ContainerState, status config.JobStatus) {
+	body := struct {
+		Host          string                `json:"host"`
+		ContainerName string                `json:"name"`
+		State         config.ContainerState `json:"state"`
+		Job           config.JobStatus      `json:"job"`
+	}{pl.Host, name, state, status}
+	if err := pl.post(ctx, "/api/v1/state", body); err != nil {
+		log.Printf("PollingListener: failed to report job %s: %v", name, err)
+	}
+}
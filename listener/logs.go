@@ -0,0 +1,206 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/runner"
+)
+
+// logKeepAlive is how often an idle followed stream checks that its client
+// is still there.
+const logKeepAlive = 15 * time.Second
+
+// errLogGone is returned by the master once the client of a log request is
+// gone.
+var errLogGone = errors.New("log request is gone")
+
+// LogsListener long-polls the master for log requests and streams the logs
+// of the requested containers back in chunks, so that the master never has
+// to connect to the slave.
+type LogsListener struct {
+	MasterURL string
+	Host      string
+	Runner    runner.Runner
+	Token     string
+
+	// retryInterval is the pause after a failed poll.
+	retryInterval time.Duration
+}
+
+func NewLogsListener(masterURL, host string, r runner.Runner, interval time.Duration, token string) *LogsListener {
+	return &LogsListener{
+		MasterURL:     masterURL,
+		Host:          host,
+		Runner:        r,
+		Token:         token,
+		retryInterval: interval,
+	}
+}
+
+func (ll *LogsListener) Listen(stopCh <-chan struct{}) {
+	ctx, cancel := stopContext(stopCh)
+	defer cancel()
+
+	for ctx.Err() == nil {
+		req, ok, err := ll.poll(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("LogsListener: failed to poll for log requests: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(ll.retryInterval):
+			}
+			continue
+		}
+		if ok {
+			go ll.serve(ctx, req)
+		}
+	}
+}
+
+// poll waits for the next log request.
+func (ll *LogsListener) poll(ctx context.Context) (config.LogRequest, bool, error) {
+	var req config.LogRequest
+	ok, err := longPoll(ctx, ll.MasterURL+"/api/v1/logs/poll?host="+url.QueryEscape(ll.Host), ll.Token, &req)
+	return req, ok, err
+}
+
+// longPoll waits for the next request the master has for the slave and
+// decodes it into v; the master answers 204 when none arrived in time.
+func longPoll(ctx context.Context, url, token string, v any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return false, nil
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+}
+
+func (ll *LogsListener) serve(ctx context.Context, req config.LogRequest) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	logs, err := ll.Runner.Logs(ctx, req.Container, req.Follow, req.Since, req.Tail)
+	if err != nil {
+		ll.report(ctx, req, ll.send(ctx, req.ID, nil, err, true))
+		return
+	}
+	defer logs.Close()
+
+	data := make(chan []byte)
+	done := make(chan error, 1)
+	go func() {
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := logs.Read(buf)
+			if n > 0 {
+				select {
+				case data <- buf[:n]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	// An empty chunk tells the master at once that the request is served.
+	if err := ll.send(ctx, req.ID, nil, nil, false); err != nil {
+		ll.report(ctx, req, err)
+		return
+	}
+	keepAlive := time.NewTicker(logKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case b := <-data:
+			err = ll.send(ctx, req.ID, b, nil, false)
+		case err = <-done:
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			ll.report(ctx, req, ll.send(ctx, req.ID, nil, err, true))
+			return
+		case <-keepAlive.C:
+			err = ll.send(ctx, req.ID, nil, nil, false)
+		case <-ctx.Done():
+			// Let the client know rather than leave it waiting.
+			stopCtx, stop := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			_ = ll.send(stopCtx, req.ID, nil, errors.New("slave is stopping"), true)
+			stop()
+			return
+		}
+		if err != nil {
+			ll.report(ctx, req, err)
+			return
+		}
+	}
+}
+
+// send posts a chunk of logs to the master, ending the stream with failure
+// if it is not nil.
+func (ll *LogsListener) send(ctx context.Context, id string, chunk []byte, failure error, eof bool) error {
+	q := url.Values{"id": {id}}
+	if failure != nil {
+		q.Set("error", failure.Error())
+	}
+	if eof {
+		q.Set("eof", "true")
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", ll.MasterURL+"/api/v1/logs/chunk?"+q.Encode(), bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", "Bearer "+ll.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return errLogGone
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// report logs a failure to stream, unless the client just went away or the
+// slave is stopping.
+func (ll *LogsListener) report(ctx context.Context, req config.LogRequest, err error) {
+	if err == nil || errors.Is(err, errLogGone) || ctx.Err() != nil {
+		return
+	}
+	log.Printf("LogsListener: failed to stream logs of %s: %v", req.Container, err)
+}
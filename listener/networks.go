@@ -0,0 +1,36 @@
+package listener
+
+import (
+	"context"
+	"log"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+func networkNames(cs config.ContainerStatus) []string {
+	var names []string
+	for _, n := range cs.Networks {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func (pl *PollingListener) createNetworks(ctx context.Context, cs config.ContainerStatus) error {
+	for _, n := range cs.Networks {
+		if err := pl.Runner.CreateNetwork(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeNetworks removes the networks of a removed container. Networks still
+// used by other containers of the manifest are kept by the runner, so the
+// last container to go takes them down.
+func (pl *PollingListener) removeNetworks(ctx context.Context, cs config.ContainerStatus) {
+	for _, n := range cs.Networks {
+		if err := pl.Runner.RemoveNetwork(ctx, n.Name); err != nil {
+			log.Printf("Runner.RemoveNetwork error for %s: %v", n.Name, err)
+		}
+	}
+}
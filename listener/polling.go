@@ -1,6 +1,7 @@
 package listener
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/rmerezha/mtrpz-lab4/config"
 	"github.com/rmerezha/mtrpz-lab4/runner"
@@ -20,6 +21,18 @@ type PollingListener struct {
 	pollInterval time.Duration
 
 	Token string
+
+	// DataDir is where files materialized for containers (secrets and
+	// configs) live.
+	DataDir string
+
+	// pullRetries are the containers whose image failed to pull.
+	pullRetries map[string]pullRetry
+	// digests are the image digests the containers were created from.
+	digests map[string]string
+	// volumesRemoved are the removed containers whose volumes were removed
+	// with their manifest.
+	volumesRemoved map[string]bool
 }
 
 func NewPollingListener(masterURL, host string, r runner.Runner, interval time.Duration, token string, store *ContainerStateStore) *PollingListener {
@@ -34,6 +47,8 @@ func NewPollingListener(masterURL, host string, r runner.Runner, interval time.D
 }
 
 func (pl *PollingListener) Listen(stopCh <-chan struct{}) {
+	ctx, cancel := stopContext(stopCh)
+	defer cancel()
 	ticker := time.NewTicker(pl.pollInterval)
 	defer ticker.Stop()
 
@@ -42,15 +57,15 @@ func (pl *PollingListener) Listen(stopCh <-chan struct{}) {
 		case <-stopCh:
 			return
 		case <-ticker.C:
-			pl.checkAndApply()
+			pl.checkAndApply(ctx)
 		}
 	}
 }
 
-func (pl *PollingListener) checkAndApply() {
+func (pl *PollingListener) checkAndApply(ctx context.Context) {
 	url := pl.MasterURL + "/api/v1/container?host=" + pl.Host
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Printf("PollingListener: failed to create request: %v", err)
 		return
@@ -81,48 +96,144 @@ func (pl *PollingListener) checkAndApply() {
 	defer pl.mu.Unlock()
 
 	for _, cs := range containers {
+		if ctx.Err() != nil {
+			return
+		}
 		prevState, known := pl.Store.Get(cs.Config.Name)
 		if !known || prevState != cs.State {
 			log.Printf("PollingListener: container %s state changed from %s to %s", cs.Config.Name, prevState, cs.State)
 			pl.Store.Set(cs.Config.Name, cs.State)
 
-			pl.applyState(cs)
+			pl.applyState(ctx, cs)
+		} else if pl.retryPull(cs) {
+			log.Printf("PollingListener: retrying to pull the image of %s", cs.Config.Name)
+			cs.State = config.StateNew
+			pl.applyState(ctx, cs)
+		} else if _, failed := pl.pullRetries[cs.Config.Name]; !failed && pl.stale(cs) {
+			log.Printf("PollingListener: %s was created from %s, recreating it from %s",
+				cs.Config.Name, pl.digests[cs.Config.Name], cs.Config.Image)
+			cs.State = config.StateNew
+			pl.applyState(ctx, cs)
+		} else if cs.State == config.StateRemoving || cs.State == config.StateRemoved {
+			if cs.ManifestDown && !pl.volumesRemoved[cs.Config.Name] {
+				// The container was removed on its own before its
+				// manifest was taken down.
+				pl.removeVolumes(ctx, cs)
+			}
+			if cs.State == config.StateRemoving {
+				// The last report did not reach the master.
+				pl.reportRemoved(ctx, cs.Config.Name)
+			}
 		}
 	}
 }
 
-func (pl *PollingListener) applyState(cs config.ContainerStatus) {
+func (pl *PollingListener) applyState(ctx context.Context, cs config.ContainerStatus) {
 	name := cs.Config.Name
 
 	switch cs.State {
-	case config.StateNew:
-		if err := pl.Runner.PullImage(cs.Config.Image); err != nil {
-			log.Printf("Runner.PullImage error for %s: %v", name, err)
+	case config.StateNew, config.StatePulling:
+		// A container is left pulling on the master when the slave
+		// restarted during the pull; start over. A container that already
+		// exists is being updated: recreate it.
+		if _, err := pl.Runner.State(ctx, name); err == nil {
+			pl.runPreStop(ctx, cs)
+			if err := pl.Runner.Remove(ctx, name); err != nil {
+				log.Printf("Runner.Remove error for %s: %v", name, err)
+			}
+		}
+		delete(pl.volumesRemoved, name)
+		if !pl.pull(ctx, cs) {
+			return
+		}
+		c, err := pl.prepare(cs)
+		if err != nil {
+			log.Printf("PollingListener: failed to prepare %s: %v", name, err)
+			return
+		}
+		if err := pl.createNetworks(ctx, cs); err != nil {
+			log.Printf("PollingListener: failed to create networks for %s: %v", name, err)
+			return
+		}
+		if err := pl.createVolumes(ctx, cs); err != nil {
+			log.Printf("PollingListener: failed to create volumes for %s: %v", name, err)
+			return
+		}
+		if !pl.runPreStart(ctx, cs, c) {
+			log.Printf("PollingListener: preStart hook failed, not starting %s", name)
+			return
 		}
-		if err := pl.Runner.Run(cs.Config); err != nil {
+		pl.Store.SetJob(name, c.IsJob())
+		if c.IsJob() {
+			go pl.runJob(ctx, cs, c)
+			return
+		}
+		if err := pl.Runner.Run(ctx, c); err != nil {
 			log.Printf("Runner.Run error for %s: %v", name, err)
+			return
+		}
+		if !pl.runPostStart(ctx, cs) {
+			log.Printf("PollingListener: postStart hook failed, stopping %s", name)
+			if err := pl.Runner.Stop(ctx, name); err != nil {
+				log.Printf("Runner.Stop error for %s: %v", name, err)
+			}
+			return
 		}
 		cs.State = config.StateRunning
 	case config.StatePaused:
 		// TODO
 		log.Println("not implemented yet")
 	case config.StateRestarting:
-		if err := pl.Runner.Restart(name); err != nil {
+		pl.runPreStop(ctx, cs)
+		if err := pl.Runner.Restart(ctx, name); err != nil {
 			log.Printf("Runner.Restart error for %s: %v", name, err)
 		}
 	case config.StateRemoving:
-		if err := pl.Runner.Remove(name); err != nil {
+		pl.runPreStop(ctx, cs)
+		if err := pl.Runner.Remove(ctx, name); err != nil {
 			log.Printf("Runner.Remove error for %s: %v", name, err)
 		}
+		pl.cleanup(name)
+		delete(pl.digests, name)
+		pl.removeNetworks(ctx, cs)
+		if cs.ManifestDown {
+			pl.removeVolumes(ctx, cs)
+		}
+		pl.reportRemoved(ctx, name)
+	case config.StateRemoved:
+		// Reported by the slave, nothing to apply.
 	case config.StateExited:
-		if err := pl.Runner.Stop(name); err != nil {
+		pl.runPreStop(ctx, cs)
+		if err := pl.Runner.Stop(ctx, name); err != nil {
 			log.Printf("Runner.Stop error for %s: %v", name, err)
 		}
+	case config.StateSucceeded, config.StateFailed:
+		// Reported by the job runner, nothing to apply.
+	case config.StateScheduled:
+		// The master adds a run of it when due; there is no container.
+		pl.Store.SetJob(name, true)
 	case config.StateDead:
-		if err := pl.Runner.Kill(name); err != nil {
+		if err := pl.Runner.Kill(ctx, name); err != nil {
 			log.Printf("Runner.Kill error for %s: %v", name, err)
 		}
 	default:
 		log.Printf("PollingListener: unknown state %s for container %s", cs.State, name)
 	}
 }
+
+// reportRemoved tells the master that the container is gone, if it is.
+func (pl *PollingListener) reportRemoved(ctx context.Context, name string) {
+	if _, err := pl.Runner.State(ctx, name); err == nil {
+		return
+	}
+	body := struct {
+		Host          string                `json:"host"`
+		ContainerName string                `json:"name"`
+		State         config.ContainerState `json:"state"`
+	}{pl.Host, name, config.StateRemoved}
+	if err := pl.post(ctx, "/api/v1/state", body); err != nil {
+		log.Printf("PollingListener: failed to report %s removed: %v", name, err)
+		return
+	}
+	pl.Store.Set(name, config.StateRemoved)
+}
@@ -0,0 +1,160 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/runner"
+)
+
+const (
+	// maxPullBackoff bounds the delay before a failed pull is tried again.
+	maxPullBackoff = 5 * time.Minute
+	// progressInterval is the minimum delay between two reports of the
+	// progress of a pull.
+	progressInterval = time.Second
+)
+
+type pullRetry struct {
+	attempts int
+	next     time.Time
+}
+
+// pull pulls the image of the container according to its pull policy, with
+// the registry credentials the master sent along. It reports the progress
+// while pulling, then the outcome and the digest the image resolved to to
+// the master. checkAndApply tries a failed pull again after a delay doubling
+// with every attempt, so that fixing the credentials on the master is
+// enough. pl.mu must be held.
+//
+// The containers of the host are applied one at a time, so the others wait
+// for as long as the pull runs, up to the pull timeout of the runner. That
+// timeout is kept short: a pull cut off is tried again and keeps the layers
+// it already downloaded, while a container waiting to be removed or
+// restarted cannot wait long.
+func (pl *PollingListener) pull(ctx context.Context, cs config.ContainerStatus) bool {
+	name := cs.Config.Name
+	report, stop := pl.progressReporter(ctx, name)
+	dgst, err := pl.Runner.PullImage(ctx, cs.Config.Image, runner.PullOptions{
+		Auth:     cs.RegistryAuth,
+		Policy:   cs.Config.EffectivePullPolicy(),
+		Progress: report,
+	})
+	// A progress arriving after the outcome would move the container back
+	// to pulling.
+	stop()
+	if ctx.Err() != nil {
+		return false
+	}
+
+	result := config.PullResult{Image: cs.Config.Image, Time: time.Now(), Digest: dgst}
+	if err != nil {
+		result.Error = err.Error()
+		result.AuthFailed = errors.Is(err, runner.ErrRegistryAuth)
+
+		if pl.pullRetries == nil {
+			pl.pullRetries = make(map[string]pullRetry)
+		}
+		r := pl.pullRetries[name]
+		delay := pl.pollInterval
+		for i := 0; i < r.attempts && delay < maxPullBackoff; i++ {
+			delay *= 2
+		}
+		delay = min(delay, maxPullBackoff)
+		r.attempts++
+		r.next = result.Time.Add(delay)
+		pl.pullRetries[name] = r
+		log.Printf("Runner.PullImage error for %s: %v, retrying in %s", name, err, delay)
+	} else {
+		delete(pl.pullRetries, name)
+		if pl.digests == nil {
+			pl.digests = make(map[string]string)
+		}
+		pl.digests[name] = dgst
+	}
+
+	body := struct {
+		Host          string            `json:"host"`
+		ContainerName string            `json:"name"`
+		Result        config.PullResult `json:"result"`
+	}{pl.Host, name, result}
+	if err := pl.post(ctx, "/api/v1/pull", body); err != nil {
+		log.Printf("PollingListener: failed to report pull: %v", err)
+	}
+	return err == nil
+}
+
+// progressReporter returns a PullOptions.Progress reporting the progress of
+// the pull of the container to the master from a goroutine of its own, so
+// that a slow master does not slow the pull down. Reports are at least
+// progressInterval apart and carry the latest progress, older ones being
+// dropped. stop ends the reports, abandoning the one in flight.
+func (pl *PollingListener) progressReporter(ctx context.Context, name string) (report func(config.PullProgress), stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	latest := make(chan config.PullProgress, 1)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case p := <-latest:
+				body := struct {
+					Host          string              `json:"host"`
+					ContainerName string              `json:"name"`
+					Progress      config.PullProgress `json:"progress"`
+				}{pl.Host, name, p}
+				if err := pl.post(ctx, "/api/v1/pull/progress", body); err != nil && ctx.Err() == nil {
+					log.Printf("PollingListener: failed to report pull progress: %v", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-time.After(progressInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	report = func(p config.PullProgress) {
+		// The runner is the only sender: once drained, there is room.
+		select {
+		case <-latest:
+		default:
+		}
+		latest <- p
+	}
+	stop = func() {
+		cancel()
+		<-done
+	}
+	return report, stop
+}
+
+// retryPull tells whether the failed pull of the container is due to be
+// tried again. pl.mu must be held.
+func (pl *PollingListener) retryPull(cs config.ContainerStatus) bool {
+	r, ok := pl.pullRetries[cs.Config.Name]
+	if !ok {
+		return false
+	}
+	if cs.State != config.StateNew && !pl.stale(cs) {
+		delete(pl.pullRetries, cs.Config.Name)
+		return false
+	}
+	return !time.Now().Before(r.next)
+}
+
+// stale tells whether the running container was created from another digest
+// than the one its image is pinned to, as when it was pulled before the
+// master pinned the image of its manifest. pl.mu must be held.
+func (pl *PollingListener) stale(cs config.ContainerStatus) bool {
+	want := config.ImageDigest(cs.Config.Image)
+	have := pl.digests[cs.Config.Name]
+	return cs.State == config.StateRunning && want != "" && have != "" && have != want
+}
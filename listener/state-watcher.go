@@ -2,6 +2,7 @@ package listener
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -34,6 +35,8 @@ func NewStateWatcherListener(masterURL, host string, r runner.Runner, interval t
 }
 
 func (sw *StateWatcherListener) Listen(stopCh <-chan struct{}) {
+	ctx, cancel := stopContext(stopCh)
+	defer cancel()
 	ticker := time.NewTicker(sw.pollInterval)
 	defer ticker.Stop()
 
@@ -42,12 +45,12 @@ func (sw *StateWatcherListener) Listen(stopCh <-chan struct{}) {
 		case <-stopCh:
 			return
 		case <-ticker.C:
-			sw.checkAndReport()
+			sw.checkAndReport(ctx)
 		}
 	}
 }
 
-func (sw *StateWatcherListener) checkAndReport() {
+func (sw *StateWatcherListener) checkAndReport(ctx context.Context) {
 	sw.mu.Lock()
 	containerNames := make([]string, 0, len(sw.Store.states))
 	for name := range sw.Store.states {
@@ -56,7 +59,13 @@ func (sw *StateWatcherListener) checkAndReport() {
 	sw.mu.Unlock()
 
 	for _, name := range containerNames {
-		stateStr, err := sw.Runner.State(name)
+		if sw.Store.IsJob(name) {
+			continue
+		}
+		if state, _ := sw.Store.Get(name); state == config.StateRemoved {
+			continue
+		}
+		stateStr, err := sw.Runner.State(ctx, name)
 		if err != nil {
 			log.Printf("StateWatcherListener: failed to get state for %s: %v", name, err)
 			continue
@@ -70,22 +79,36 @@ func (sw *StateWatcherListener) checkAndReport() {
 			sw.Store.Set(name, state)
 			sw.mu.Unlock()
 
-			sw.sendStateUpdate(name, state)
+			var ports []string
+			var volumes []config.VolumeUsage
+			if state == config.StateRunning {
+				if ports, err = sw.Runner.Ports(ctx, name); err != nil {
+					log.Printf("StateWatcherListener: failed to get ports for %s: %v", name, err)
+				}
+				if volumes, err = sw.Runner.Volumes(ctx, name); err != nil {
+					log.Printf("StateWatcherListener: failed to get volumes for %s: %v", name, err)
+				}
+			}
+			sw.sendStateUpdate(ctx, name, state, ports, volumes)
 		} else {
 			sw.mu.Unlock()
 		}
 	}
 }
 
-func (sw *StateWatcherListener) sendStateUpdate(containerName string, state config.ContainerState) {
+func (sw *StateWatcherListener) sendStateUpdate(ctx context.Context, containerName string, state config.ContainerState, ports []string, volumes []config.VolumeUsage) {
 	body := struct {
 		Host          string                `json:"host"`
 		ContainerName string                `json:"name"`
 		State         config.ContainerState `json:"state"`
+		Ports         []string              `json:"ports,omitempty"`
+		Volumes       []config.VolumeUsage  `json:"volumes,omitempty"`
 	}{
 		Host:          sw.Host,
 		ContainerName: containerName,
 		State:         state,
+		Ports:         ports,
+		Volumes:       volumes,
 	}
 
 	data, err := json.Marshal(body)
@@ -95,7 +118,7 @@ func (sw *StateWatcherListener) sendStateUpdate(containerName string, state conf
 	}
 
 	url := sw.MasterURL + "/api/v1/state"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
 	if err != nil {
 		log.Printf("StateWatcherListener: failed to create request: %v", err)
 		return
@@ -0,0 +1,104 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+	"github.com/rmerezha/mtrpz-lab4/runner"
+)
+
+// StatsListener samples the resource usage of the running containers and
+// reports it to the master in one request per interval.
+type StatsListener struct {
+	MasterURL string
+	Host      string
+	Runner    runner.Runner
+	Store     *ContainerStateStore
+	Token     string
+
+	interval time.Duration
+}
+
+func NewStatsListener(masterURL, host string, r runner.Runner, interval time.Duration, token string, store *ContainerStateStore) *StatsListener {
+	return &StatsListener{
+		MasterURL: masterURL,
+		Host:      host,
+		Runner:    r,
+		Store:     store,
+		Token:     token,
+		interval:  interval,
+	}
+}
+
+func (sl *StatsListener) Listen(stopCh <-chan struct{}) {
+	ctx, cancel := stopContext(stopCh)
+	defer cancel()
+	ticker := time.NewTicker(sl.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := sl.sample(ctx)
+			if len(stats) == 0 {
+				continue
+			}
+			if err := sl.send(ctx, stats); err != nil && ctx.Err() == nil {
+				log.Printf("StatsListener: failed to send stats: %v", err)
+			}
+		}
+	}
+}
+
+func (sl *StatsListener) sample(ctx context.Context) map[string]config.ContainerStats {
+	stats := make(map[string]config.ContainerStats)
+	for _, name := range sl.Store.Running() {
+		s, err := sl.Runner.Stats(ctx, name)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("StatsListener: failed to get stats for %s: %v", name, err)
+			}
+			continue
+		}
+		stats[name] = s
+	}
+	return stats
+}
+
+func (sl *StatsListener) send(ctx context.Context, stats map[string]config.ContainerStats) error {
+	body := struct {
+		Host  string                           `json:"host"`
+		Stats map[string]config.ContainerStats `json:"stats"`
+	}{
+		Host:  sl.Host,
+		Stats: stats,
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sl.MasterURL+"/api/v1/stats/report", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+sl.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -9,11 +9,16 @@ import (
 type ContainerStateStore struct {
 	mu     sync.RWMutex
 	states map[string]config.ContainerState
+
+	// jobs are the containers the StateWatcherListener leaves alone: jobs,
+	// whose state is reported by the job runner, and scheduled containers.
+	jobs map[string]bool
 }
 
 func NewContainerStateStore() *ContainerStateStore {
 	return &ContainerStateStore{
 		states: make(map[string]config.ContainerState),
+		jobs:   make(map[string]bool),
 	}
 }
 
@@ -29,3 +34,28 @@ func (s *ContainerStateStore) Set(name string, state config.ContainerState) {
 	defer s.mu.Unlock()
 	s.states[name] = state
 }
+
+func (s *ContainerStateStore) SetJob(name string, job bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = job
+}
+
+func (s *ContainerStateStore) IsJob(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.jobs[name]
+}
+
+// Running returns the containers last seen running.
+func (s *ContainerStateStore) Running() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var names []string
+	for name, state := range s.states {
+		if state == config.StateRunning {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,54 @@
+package listener
+
+import (
+	"context"
+	"log"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// mountVolumes replaces the sources naming manifest volumes with the names
+// the volumes have on the host.
+func mountVolumes(cs config.ContainerStatus) []config.Volume {
+	if len(cs.Volumes) == 0 {
+		return cs.Config.Volumes
+	}
+	res := make([]config.Volume, len(cs.Config.Volumes))
+	for i, v := range cs.Config.Volumes {
+		for _, n := range cs.Volumes {
+			if n.Labels[config.LabelVolume] == v.Source {
+				v.Source = n.Name
+				break
+			}
+		}
+		res[i] = v
+	}
+	return res
+}
+
+func (pl *PollingListener) createVolumes(ctx context.Context, cs config.ContainerStatus) error {
+	for _, v := range cs.Volumes {
+		if err := pl.Runner.CreateVolume(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeVolumes deletes the volumes of a container removed with its manifest
+// whose retention policy says so. As with networks, the runner keeps volumes
+// still in use.
+func (pl *PollingListener) removeVolumes(ctx context.Context, cs config.ContainerStatus) {
+	if pl.volumesRemoved == nil {
+		pl.volumesRemoved = make(map[string]bool)
+	}
+	pl.volumesRemoved[cs.Config.Name] = true
+	for _, v := range cs.Volumes {
+		if v.Retention != config.RetainDelete {
+			continue
+		}
+		if err := pl.Runner.RemoveVolume(ctx, v.Name); err != nil {
+			log.Printf("Runner.RemoveVolume error for %s: %v", v.Name, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package planner
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// schedulerInterval is how often due schedules are checked; runs start at
+// most this late.
+const schedulerInterval = 5 * time.Second
+
+// RunScheduler starts the runs of scheduled containers as they are due until
+// stopCh is closed.
+func (p *Planner) RunScheduler(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case now := <-ticker.C:
+			p.Trigger(now)
+		}
+	}
+}
+
+// Trigger adds a run of every scheduled container due at now and returns the
+// names of the new runs.
+func (p *Planner) Trigger(now time.Time) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Collect first: starting a run changes the storage.
+	type dueJob struct {
+		host string
+		cs   *config.ContainerStatus
+	}
+	var due []dueJob
+	for host, containers := range p.storage {
+		for _, cs := range containers {
+			if cs.State == config.StateScheduled && cs.Cron != nil && !cs.Cron.Next.IsZero() && !now.Before(cs.Cron.Next) {
+				due = append(due, dueJob{host, cs})
+			}
+		}
+	}
+
+	var started []string
+	for _, d := range due {
+		at := d.cs.Cron.Next
+		next, err := d.cs.Config.Schedule.Next(now)
+		if err != nil {
+			log.Printf("planner: invalid schedule of %s: %v", d.cs.Config.Name, err)
+			continue
+		}
+		d.cs.Cron.Next = next
+		if run := p.startRun(d.host, d.cs, at); run != "" {
+			started = append(started, run)
+		}
+	}
+	return started
+}
+
+// startRun adds a run of the scheduled container cs applying its concurrency
+// policy, and prunes its history. It returns the name of the run, or "" if
+// the run was skipped.
+func (p *Planner) startRun(host string, cs *config.ContainerStatus, due time.Time) string {
+	sched := cs.Config.Schedule
+	runs := p.runs(host, cs)
+	p.pruneRuns(runs, sched.History())
+
+	var active []*config.ContainerStatus
+	for _, r := range runs {
+		if !finished(r.State) && r.State != config.StateRemoving && r.State != config.StateRemoved {
+			active = append(active, r)
+		}
+	}
+	if len(active) > 0 {
+		switch sched.Policy() {
+		case config.ConcurrencyForbid:
+			log.Printf("planner: skipping run of %s due at %s, %s is still active", cs.Config.Name, due, active[0].Config.Name)
+			return ""
+		case config.ConcurrencyReplace:
+			for _, r := range active {
+				r.State = config.StateRemoving
+			}
+		}
+	}
+
+	run := &config.ContainerStatus{
+		ManifestName: cs.ManifestName,
+		Config:       cs.Config,
+		State:        config.StateNew,
+		Networks:     cs.Networks,
+		Volumes:      cs.Volumes,
+		CronJob:      cs.Config.Name,
+	}
+	run.Config.Name = fmt.Sprintf("%s-%d", cs.Config.Name, due.Unix())
+	run.Config.Schedule = nil
+	cs.Cron.Last = due
+	p.storage[host] = append(p.storage[host], run)
+	return run.Config.Name
+}
+
+// pruneRuns marks the finished runs beyond limit for removal, oldest first.
+// They are dropped once their slave reports them removed, see SetRemoved.
+func (p *Planner) pruneRuns(runs []*config.ContainerStatus, limit int) {
+	kept := 0
+	for i := len(runs) - 1; i >= 0; i-- {
+		if r := runs[i]; finished(r.State) {
+			kept++
+			if kept > limit {
+				r.State = config.StateRemoving
+			}
+		}
+	}
+}
+
+// runs returns the runs of the scheduled container cs, oldest first.
+func (p *Planner) runs(host string, cs *config.ContainerStatus) []*config.ContainerStatus {
+	var res []*config.ContainerStatus
+	for _, c := range p.storage[host] {
+		if c.ManifestName == cs.ManifestName && c.CronJob == cs.Config.Name {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// ListCronJobs returns the scheduled containers of the manifest, or of every
+// manifest if name is empty, with their runs.
+func (p *Planner) ListCronJobs(name string) []config.CronJobStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var res []config.CronJobStatus
+	for host, containers := range p.storage {
+		for _, cs := range containers {
+			if cs.Config.Schedule == nil || cs.Cron == nil || (name != "" && cs.ManifestName != name) {
+				continue
+			}
+			job := config.CronJobStatus{
+				ManifestName: cs.ManifestName,
+				Name:         cs.Config.Name,
+				Host:         host,
+				Schedule:     *cs.Config.Schedule,
+				CronState:    *cs.Cron,
+			}
+			for _, r := range p.runs(host, cs) {
+				job.Runs = append(job.Runs, *r)
+			}
+			res = append(res, job)
+		}
+	}
+	return res
+}
+
+// hasSchedule reports whether m has a scheduled container named name on host.
+func hasSchedule(m *config.Manifest, host, name string) bool {
+	for _, c := range m.Containers {
+		if c.Name == name && c.Host == host && c.Schedule != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func finished(state config.ContainerState) bool {
+	return state == config.StateSucceeded || state == config.StateFailed
+}
@@ -0,0 +1,142 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+func setupCron(t *testing.T, policy string, history int) (*Planner, *config.ContainerStatus) {
+	t.Helper()
+	p := NewPlanner()
+	m := &config.Manifest{
+		Name: "nightly",
+		Containers: []config.Container{{
+			Name: "report", Host: "node1", Image: "busybox", Type: config.KindJob,
+			Schedule: &config.Schedule{Cron: "*/10 * * * *", ConcurrencyPolicy: policy, HistoryLimit: history},
+		}},
+	}
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The live status, whose cron state the tests follow.
+	cs := p.storage["node1"][0]
+	if cs.State != config.StateScheduled || cs.Cron == nil || cs.Cron.Next.IsZero() {
+		t.Fatalf("expected a scheduled container, got %s %+v", cs.State, cs.Cron)
+	}
+	return p, cs
+}
+
+func TestTrigger(t *testing.T) {
+	p, cs := setupCron(t, "", 0)
+
+	due := cs.Cron.Next
+	if started := p.Trigger(due.Add(-time.Second)); len(started) != 0 {
+		t.Fatalf("expected no run before the schedule, got %v", started)
+	}
+	started := p.Trigger(due)
+	if len(started) != 1 {
+		t.Fatalf("expected one run, got %v", started)
+	}
+	if !cs.Cron.Last.Equal(due) || !cs.Cron.Next.Equal(due.Add(10*time.Minute)) {
+		t.Errorf("unexpected cron state: %+v", cs.Cron)
+	}
+
+	run := p.ListContainersByHost("node1")[1]
+	if run.Config.Name != started[0] || run.State != config.StateNew || run.CronJob != "report" || run.Config.Schedule != nil {
+		t.Errorf("unexpected run: %+v", run)
+	}
+
+	// Allow: a second run starts while the first is active.
+	if started := p.Trigger(cs.Cron.Next); len(started) != 1 {
+		t.Errorf("expected a concurrent run, got %v", started)
+	}
+}
+
+func TestTrigger_ConcurrencyPolicy(t *testing.T) {
+	p, cs := setupCron(t, config.ConcurrencyForbid, 0)
+	first := p.Trigger(cs.Cron.Next)
+	p.UpdateState("node1", first[0], config.StateRunning)
+	if started := p.Trigger(cs.Cron.Next); len(started) != 0 {
+		t.Errorf("forbid: expected the run to be skipped, got %v", started)
+	}
+	p.UpdateState("node1", first[0], config.StateSucceeded)
+	if started := p.Trigger(cs.Cron.Next); len(started) != 1 {
+		t.Errorf("forbid: expected a run once the previous finished, got %v", started)
+	}
+
+	p, cs = setupCron(t, config.ConcurrencyReplace, 0)
+	first = p.Trigger(cs.Cron.Next)
+	p.UpdateState("node1", first[0], config.StateRunning)
+	if started := p.Trigger(cs.Cron.Next); len(started) != 1 {
+		t.Fatalf("replace: expected a run, got %v", started)
+	}
+	if state := p.ListContainersByHost("node1")[1].State; state != config.StateRemoving {
+		t.Errorf("replace: expected the active run to be removed, got %s", state)
+	}
+}
+
+func TestTrigger_History(t *testing.T) {
+	p, cs := setupCron(t, "", 2)
+
+	var runs []string
+	for i := 0; i < 4; i++ {
+		started := p.Trigger(cs.Cron.Next)
+		p.UpdateState("node1", started[0], config.StateSucceeded)
+		runs = append(runs, started[0])
+	}
+
+	// Pruning happens before each run: the fourth trigger marked the oldest
+	// run and the fifth the next one. Both are kept until their slave
+	// reports them removed.
+	p.Trigger(cs.Cron.Next)
+	if !p.SetRemoved("node1", runs[0]) {
+		t.Fatal("expected SetRemoved to find the run")
+	}
+
+	jobs := p.ListCronJobs("nightly")
+	if len(jobs) != 1 {
+		t.Fatalf("expected one cron job, got %d", len(jobs))
+	}
+	states := map[string]config.ContainerState{}
+	for _, r := range jobs[0].Runs {
+		states[r.Config.Name] = r.State
+	}
+	want := map[string]config.ContainerState{
+		runs[1]: config.StateRemoving,
+		runs[2]: config.StateSucceeded,
+		runs[3]: config.StateSucceeded,
+	}
+	if len(states) != len(want)+1 {
+		t.Fatalf("expected runs %v and a new one, got %v", want, states)
+	}
+	for name, state := range want {
+		if states[name] != state {
+			t.Errorf("run %s: expected %s, got %s", name, state, states[name])
+		}
+	}
+}
+
+func TestAddManifest_PrunesRunsOfRemovedSchedule(t *testing.T) {
+	p, cs := setupCron(t, "", 0)
+	run := p.Trigger(cs.Cron.Next)[0]
+	p.UpdateState("node1", run, config.StateSucceeded)
+
+	m := &config.Manifest{
+		Name:       "nightly",
+		Containers: []config.Container{{Name: "web", Host: "node1", Image: "nginx"}},
+	}
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	containers := p.ListContainersByHost("node1")
+	if len(containers) != 2 || containers[0].Config.Name != run || containers[0].State != config.StateRemoving {
+		t.Fatalf("expected the run to be removed along with its schedule, got %+v", containers)
+	}
+	p.SetRemoved("node1", run)
+	if containers := p.ListContainersByHost("node1"); len(containers) != 1 || containers[0].Config.Name != "web" {
+		t.Errorf("expected the run to be dropped once removed, got %+v", containers)
+	}
+}
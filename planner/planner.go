@@ -1,28 +1,32 @@
 package planner
 
 import (
+	"fmt"
+
 	"github.com/rmerezha/mtrpz-lab4/config"
+	"slices"
 	"sync"
+	"time"
 )
 
 type Planner struct {
 	mu      sync.RWMutex
 	storage map[string][]*config.ContainerStatus
+	// pins are the digests the images of manifests with PinDigests are
+	// pinned to, by manifest and image.
+	pins map[string]map[string]string
 }
 
 func NewPlanner(manifests ...*config.Manifest) *Planner {
 	p := &Planner{
 		storage: make(map[string][]*config.ContainerStatus),
+		pins:    make(map[string]map[string]string),
 	}
 
 	for _, m := range manifests {
+		p.resetPins(m)
 		for _, c := range m.Containers {
-			cs := &config.ContainerStatus{
-				ManifestName: m.Name,
-				Config:       c,
-				State:        config.StateCreated,
-			}
-			p.storage[c.Host] = append(p.storage[c.Host], cs)
+			p.storage[c.Host] = append(p.storage[c.Host], newContainerStatus(m, c, config.StateCreated))
 		}
 	}
 	return p
@@ -46,35 +50,256 @@ func (p *Planner) UpdateState(host, containerName string, newState config.Contai
 	return false
 }
 
-func (p *Planner) ListContainersByHost(host string) []*config.ContainerStatus {
+// SetRemoved records that the slave removed the container, which must be in
+// state removing: a report arriving after the manifest was uploaded again
+// must not affect the new container. Runs of scheduled containers are
+// dropped, their history being kept by the master only while they exist.
+func (p *Planner) SetRemoved(host, containerName string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, cs := range p.storage[host] {
+		if cs.Config.Name != containerName {
+			continue
+		}
+		if cs.State != config.StateRemoving {
+			return true
+		}
+		if cs.CronJob != "" {
+			p.storage[host] = slices.Delete(p.storage[host], i, i+1)
+			return true
+		}
+		cs.State = config.StateRemoved
+		return true
+	}
+	return false
+}
+
+func (p *Planner) SetEndpoints(host, containerName string, endpoints []string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cs := range p.storage[host] {
+		if cs.Config.Name == containerName {
+			cs.Endpoints = endpoints
+			return true
+		}
+	}
+	return false
+}
+
+// SetVolumeUsage records the volumes the slave found mounted in the
+// container. Named volumes of the container missing from usage are recorded
+// as not existing.
+func (p *Planner) SetVolumeUsage(host, containerName string, usage []config.VolumeUsage) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cs := range p.storage[host] {
+		if cs.Config.Name != containerName {
+			continue
+		}
+		res := make([]config.VolumeUsage, 0, len(cs.Volumes))
+		for _, v := range cs.Volumes {
+			u := config.VolumeUsage{Name: v.Name}
+			for _, reported := range usage {
+				if reported.Name == v.Name {
+					u = reported
+					break
+				}
+			}
+			res = append(res, u)
+		}
+		cs.VolumeUsage = res
+		return true
+	}
+	return false
+}
+
+func (p *Planner) SetJobStatus(host, containerName string, status config.JobStatus) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cs := range p.storage[host] {
+		if cs.Config.Name == containerName {
+			cs.Job = &status
+			return true
+		}
+	}
+	return false
+}
+
+// RecordHook stores the result of a lifecycle hook, replacing the previous
+// result of the same hook.
+func (p *Planner) RecordHook(host, containerName string, result config.HookResult) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cs := range p.storage[host] {
+		if cs.Config.Name != containerName {
+			continue
+		}
+		for i, h := range cs.Hooks {
+			if h.Hook == result.Hook {
+				cs.Hooks[i] = result
+				return true
+			}
+		}
+		cs.Hooks = append(cs.Hooks, result)
+		return true
+	}
+	return false
+}
+
+// SetPullProgress moves a new container to StatePulling with the progress
+// of the pull of its image. RecordPull moves it back once the pull is over.
+func (p *Planner) SetPullProgress(host, containerName string, progress config.PullProgress) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cs := range p.storage[host] {
+		if cs.Config.Name != containerName {
+			continue
+		}
+		if cs.State == config.StateNew || cs.State == config.StatePulling {
+			cs.State = config.StatePulling
+			cs.PullProgress = &progress
+		}
+		return true
+	}
+	return false
+}
+
+// RecordPull stores the outcome of the last pull of the image of a
+// container. The first digest reported for an image of a manifest with
+// PinDigests pins the image.
+func (p *Planner) RecordPull(host, containerName string, result config.PullResult) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cs := range p.storage[host] {
+		if cs.Config.Name != containerName {
+			continue
+		}
+		cs.Pull = &result
+		cs.PullProgress = nil
+		if cs.State == config.StatePulling {
+			cs.State = config.StateNew
+		}
+		pins, pinned := p.pins[cs.ManifestName]
+		if pinned && result.Error == "" && result.Digest != "" && pins[cs.Config.Image] == "" {
+			pins[cs.Config.Image] = result.Digest
+		}
+		return true
+	}
+	return false
+}
+
+// PinnedImage returns the reference to the digest the image of the manifest
+// is pinned to, or image if it is not pinned.
+func (p *Planner) PinnedImage(manifest, image string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	dgst := p.pins[manifest][image]
+	if dgst == "" {
+		return image
+	}
+	pinned, err := config.PinImage(image, dgst)
+	if err != nil {
+		return image
+	}
+	return pinned
+}
+
+// resetPins forgets the pins of the manifest, so that its images are
+// resolved again. p.mu must be held.
+func (p *Planner) resetPins(m *config.Manifest) {
+	if m.PinDigests {
+		p.pins[m.Name] = make(map[string]string)
+	} else {
+		delete(p.pins, m.Name)
+	}
+}
+
+// ListContainersByHost returns copies of the containers of host, which stay
+// consistent while the slaves report on them.
+func (p *Planner) ListContainersByHost(host string) []config.ContainerStatus {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	return append([]*config.ContainerStatus(nil), p.storage[host]...)
+	result := make([]config.ContainerStatus, 0, len(p.storage[host]))
+	for _, cs := range p.storage[host] {
+		result = append(result, snapshot(cs))
+	}
+	return result
 }
 
-func (p *Planner) AddManifest(m *config.Manifest) {
+// AddManifest replaces the containers of the manifest named m.Name with those
+// of m. Nothing is changed if m clashes with containers of other manifests or
+// fails one of checks, which run under the lock so that nothing DeleteUnused
+// removes can be referenced by m.
+func (p *Planner) AddManifest(m *config.Manifest, checks ...func(*config.Manifest) error) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	for _, check := range checks {
+		if err := check(m); err != nil {
+			return err
+		}
+	}
+
+	var existing []*config.ContainerStatus
+	for _, containers := range p.storage {
+		existing = append(existing, containers...)
+	}
+	if errs := config.CheckConflicts(m, existing); len(errs) > 0 {
+		return errs
+	}
+
 	for host, containers := range p.storage {
 		filtered := containers[:0]
 		for _, cs := range containers {
 			if cs.ManifestName != m.Name {
 				filtered = append(filtered, cs)
+				continue
+			}
+			// Runs of scheduled containers are kept as history, until the
+			// slave removes them if their schedule is gone.
+			if cs.CronJob != "" {
+				if !hasSchedule(m, host, cs.CronJob) {
+					cs.State = config.StateRemoving
+				}
+				filtered = append(filtered, cs)
 			}
 		}
 		p.storage[host] = filtered
 	}
 
+	p.resetPins(m)
 	for _, c := range m.Containers {
-		cs := &config.ContainerStatus{
-			ManifestName: m.Name,
-			Config:       c,
-			State:        config.StateNew,
-		}
-		p.storage[c.Host] = append(p.storage[c.Host], cs)
+		p.storage[c.Host] = append(p.storage[c.Host], newContainerStatus(m, c, config.StateNew))
 	}
+	return nil
+}
+
+// newContainerStatus returns the status of a container added in state, or
+// of a scheduled container waiting for its first activation.
+func newContainerStatus(m *config.Manifest, c config.Container, state config.ContainerState) *config.ContainerStatus {
+	cs := &config.ContainerStatus{
+		ManifestName: m.Name,
+		Config:       c,
+		State:        state,
+		Networks:     m.ContainerNetworks(&c),
+		Volumes:      m.ContainerVolumes(&c),
+	}
+	if c.Schedule != nil {
+		cs.State = config.StateScheduled
+		// The schedule was validated with the manifest.
+		next, _ := c.Schedule.Next(time.Now())
+		cs.Cron = &config.CronState{Next: next}
+	}
+	return cs
 }
 
 func (p *Planner) MarkManifestRemoving(name string) bool {
@@ -86,7 +311,11 @@ func (p *Planner) MarkManifestRemoving(name string) bool {
 	for _, containers := range p.storage {
 		for _, cs := range containers {
 			if cs.ManifestName == name {
-				cs.State = config.StateRemoving
+				// Containers already removed only need their volumes removed.
+				if cs.State != config.StateRemoved {
+					cs.State = config.StateRemoving
+				}
+				cs.ManifestDown = true
 				found = true
 			}
 		}
@@ -95,17 +324,80 @@ func (p *Planner) MarkManifestRemoving(name string) bool {
 	return found
 }
 
-func (p *Planner) ListContainersByManifest(name string) []*config.ContainerStatus {
+// ListContainersByManifest returns copies of the containers of the manifest,
+// or of every manifest if name is empty.
+func (p *Planner) ListContainersByManifest(name string) []config.ContainerStatus {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	var result []*config.ContainerStatus
+	var result []config.ContainerStatus
 	for _, containers := range p.storage {
 		for _, cs := range containers {
 			if name == "" || cs.ManifestName == name {
-				result = append(result, cs)
+				result = append(result, snapshot(cs))
 			}
 		}
 	}
 	return result
 }
+
+// InUseError is returned by DeleteUnused when a container still uses what
+// was to be deleted.
+type InUseError struct {
+	Container string
+	Manifest  string
+}
+
+func (e *InUseError) Error() string {
+	return fmt.Sprintf("used by container %s in manifest %s", e.Container, e.Manifest)
+}
+
+// DeleteUnused calls del unless a container matching used is planned. The
+// lock is held throughout, so no manifest can start using what del removes.
+func (p *Planner) DeleteUnused(used func(c config.Container) bool, del func() error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, containers := range p.storage {
+		for _, cs := range containers {
+			if used(cs.Config) {
+				return &InUseError{Container: cs.Config.Name, Manifest: cs.ManifestName}
+			}
+		}
+	}
+	return del()
+}
+
+// RecreateContainers moves every container matching fn back to StateNew so
+// that its slave recreates it, and returns how many containers were affected.
+func (p *Planner) RecreateContainers(fn func(c config.Container) bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, containers := range p.storage {
+		for _, cs := range containers {
+			if cs.State != config.StateRemoving && cs.State != config.StateRemoved && cs.State != config.StateScheduled && fn(cs.Config) {
+				cs.State = config.StateNew
+				cs.PullProgress = nil
+				cs.Endpoints = nil
+				cs.VolumeUsage = nil
+				cs.Job = nil
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// snapshot copies cs for use outside the lock. The fields updated in place
+// are copied too; the others are replaced on update.
+func snapshot(cs *config.ContainerStatus) config.ContainerStatus {
+	res := *cs
+	res.Hooks = slices.Clone(cs.Hooks)
+	if cs.Cron != nil {
+		cron := *cs.Cron
+		res.Cron = &cron
+	}
+	return res
+}
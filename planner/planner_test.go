@@ -1,6 +1,10 @@
 package planner
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/rmerezha/mtrpz-lab4/config"
@@ -97,10 +101,376 @@ func TestAddManifest(t *testing.T) {
 		},
 	}
 
-	p.AddManifest(m)
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	cs := p.ListContainersByHost("node1")
 	if len(cs) != 2 {
 		t.Fatalf("expected 2 containers, got %d", len(cs))
 	}
 }
+
+func TestAddManifest_Networks(t *testing.T) {
+	p := NewPlanner()
+
+	m := &config.Manifest{
+		Name:     "shop",
+		Networks: []config.Network{{Name: "front"}},
+		Containers: []config.Container{
+			{Name: "web", Host: "node1", Image: "nginx", Networks: []string{"front"}},
+			{Name: "cron", Host: "node1", Image: "alpine"},
+		},
+	}
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, cs := range p.ListContainersByHost("node1") {
+		switch cs.Config.Name {
+		case "web":
+			if len(cs.Networks) != 1 || cs.Networks[0].Name != "shop_front" {
+				t.Errorf("unexpected networks for web: %+v", cs.Networks)
+			}
+		case "cron":
+			if len(cs.Networks) != 0 {
+				t.Errorf("expected no networks for cron, got %+v", cs.Networks)
+			}
+		}
+	}
+}
+
+func TestSetVolumeUsage(t *testing.T) {
+	p := NewPlanner()
+
+	m := &config.Manifest{
+		Name:    "shop",
+		Volumes: []config.NamedVolume{{Name: "data"}, {Name: "logs"}},
+		Containers: []config.Container{{
+			Name: "db", Host: "node1", Image: "postgres",
+			Volumes: []config.Volume{{Source: "data", Target: "/data"}, {Source: "logs", Target: "/logs"}},
+		}},
+	}
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	usage := []config.VolumeUsage{{Name: "shop_data", Exists: true, Size: 2048}, {Name: "unmanaged", Exists: true}}
+	if !p.SetVolumeUsage("node1", "db", usage) {
+		t.Fatal("expected SetVolumeUsage to find the container")
+	}
+
+	want := []config.VolumeUsage{{Name: "shop_data", Exists: true, Size: 2048}, {Name: "shop_logs"}}
+	got := p.ListContainersByHost("node1")[0].VolumeUsage
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestMarkManifestRemoving(t *testing.T) {
+	p := setupPlanner()
+
+	p.UpdateState("node1", "web", config.StateRemoving)
+	p.SetRemoved("node1", "web")
+	if cs := p.ListContainersByHost("node1")[0]; cs.ManifestDown {
+		t.Error("expected a container removed on its own not to be marked as down with its manifest")
+	}
+
+	if !p.MarkManifestRemoving("example") {
+		t.Fatal("expected MarkManifestRemoving to find the manifest")
+	}
+	for _, cs := range p.ListContainersByManifest("example") {
+		want := config.StateRemoving
+		if cs.Config.Name == "web" {
+			want = config.StateRemoved
+		}
+		if cs.State != want || !cs.ManifestDown {
+			t.Errorf("expected %s to be %s with its manifest, got %s (down %v)", cs.Config.Name, want, cs.State, cs.ManifestDown)
+		}
+	}
+}
+
+func TestSetRemoved(t *testing.T) {
+	p := setupPlanner()
+
+	// A late report must not affect a container of a new upload.
+	p.SetRemoved("node1", "web")
+	if state := p.ListContainersByHost("node1")[0].State; state != config.StateCreated {
+		t.Errorf("expected the new container to stay created, got %s", state)
+	}
+
+	p.UpdateState("node1", "web", config.StateRemoving)
+	if !p.SetRemoved("node1", "web") {
+		t.Fatal("expected SetRemoved to find the container")
+	}
+	if state := p.ListContainersByHost("node1")[0].State; state != config.StateRemoved {
+		t.Errorf("expected the container to be removed, got %s", state)
+	}
+}
+
+func TestSetJobStatus(t *testing.T) {
+	p := setupPlanner()
+
+	status := config.JobStatus{Attempts: 2, ExitCode: 1, Error: "deadline exceeded"}
+	if !p.SetJobStatus("node1", "app", status) {
+		t.Fatal("expected SetJobStatus to find the container")
+	}
+	p.UpdateState("node1", "app", config.StateFailed)
+
+	cs := p.ListContainersByHost("node1")[1]
+	if cs.State != config.StateFailed || cs.Job == nil || *cs.Job != status {
+		t.Errorf("unexpected status: %s %+v", cs.State, cs.Job)
+	}
+
+	p.RecreateContainers(func(c config.Container) bool { return c.Name == "app" })
+	if cs := p.ListContainersByHost("node1")[1]; cs.Job != nil {
+		t.Errorf("expected the job status to be cleared on recreate, got %+v", cs.Job)
+	}
+}
+
+func TestRecordHook(t *testing.T) {
+	p := setupPlanner()
+
+	failed := config.HookResult{Hook: "preStart[0]", ExitCode: 1, Output: "no such table"}
+	if !p.RecordHook("node1", "web", failed) {
+		t.Fatal("expected RecordHook to find the container")
+	}
+	p.RecordHook("node1", "web", config.HookResult{Hook: "postStart"})
+	p.RecordHook("node1", "web", config.HookResult{Hook: "preStart[0]"})
+
+	hooks := p.ListContainersByHost("node1")[0].Hooks
+	if len(hooks) != 2 || hooks[0].Hook != "preStart[0]" || hooks[0].Failed() || hooks[1].Hook != "postStart" {
+		t.Errorf("unexpected hooks: %+v", hooks)
+	}
+
+	if p.RecordHook("node1", "missing", failed) {
+		t.Error("expected RecordHook to fail for an unknown container")
+	}
+}
+
+func TestAddManifest_Conflict(t *testing.T) {
+	p := setupPlanner()
+
+	m := &config.Manifest{
+		Name: "other",
+		Containers: []config.Container{
+			{Name: "cache", Host: "node2", Image: "redis"},
+			{Name: "web", Host: "node1", Image: "nginx"},
+		},
+	}
+
+	if err := p.AddManifest(m); err == nil {
+		t.Fatal("expected conflict error")
+	}
+	if cs := p.ListContainersByHost("node2"); len(cs) != 1 {
+		t.Errorf("expected node2 to be left untouched, got %d containers", len(cs))
+	}
+
+	m.Containers[1].Host = "node3"
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAddManifest_Check(t *testing.T) {
+	p := setupPlanner()
+
+	m := &config.Manifest{
+		Name:       "other",
+		Containers: []config.Container{{Name: "cache", Host: "node3", Image: "redis"}},
+	}
+
+	failed := errors.New("failed")
+	if err := p.AddManifest(m, func(*config.Manifest) error { return failed }); err != failed {
+		t.Fatalf("expected the check error, got %v", err)
+	}
+	if cs := p.ListContainersByHost("node3"); len(cs) != 0 {
+		t.Errorf("expected no containers on node3, got %d", len(cs))
+	}
+}
+
+func TestDeleteUnused(t *testing.T) {
+	p := setupPlanner()
+
+	deleted := false
+	del := func() error {
+		deleted = true
+		return nil
+	}
+
+	err := p.DeleteUnused(func(c config.Container) bool { return c.Image == "postgres" }, del)
+	var inUse *InUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("expected InUseError, got %v", err)
+	}
+	if inUse.Container != "db" || inUse.Manifest != "example" {
+		t.Errorf("unexpected user %s of manifest %s", inUse.Container, inUse.Manifest)
+	}
+	if deleted {
+		t.Error("expected nothing to be deleted while in use")
+	}
+
+	if err := p.DeleteUnused(func(c config.Container) bool { return c.Image == "mysql" }, del); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !deleted {
+		t.Error("expected unused item to be deleted")
+	}
+}
+
+func TestRecreateContainers(t *testing.T) {
+	p := setupPlanner()
+	p.UpdateState("node1", "web", config.StateRunning)
+	p.UpdateState("node1", "app", config.StateRunning)
+
+	n := p.RecreateContainers(func(c config.Container) bool { return c.Name == "web" })
+	if n != 1 {
+		t.Fatalf("expected 1 container to be recreated, got %d", n)
+	}
+
+	for _, c := range p.ListContainersByHost("node1") {
+		switch c.Config.Name {
+		case "web":
+			if c.State != config.StateNew {
+				t.Errorf("expected web to be %q, got %q", config.StateNew, c.State)
+			}
+		case "app":
+			if c.State != config.StateRunning {
+				t.Errorf("expected app to stay %q, got %q", config.StateRunning, c.State)
+			}
+		}
+	}
+}
+
+func TestSetEndpoints(t *testing.T) {
+	p := setupPlanner()
+
+	if !p.SetEndpoints("node1", "web", []string{"0.0.0.0:32768->80/tcp"}) {
+		t.Fatal("expected SetEndpoints to return true")
+	}
+	if p.SetEndpoints("node2", "web", nil) {
+		t.Error("expected SetEndpoints to return false for container on another host")
+	}
+
+	for _, c := range p.ListContainersByHost("node1") {
+		if c.Config.Name == "web" && (len(c.Endpoints) != 1 || c.Endpoints[0] != "0.0.0.0:32768->80/tcp") {
+			t.Errorf("unexpected endpoints %v", c.Endpoints)
+		}
+	}
+
+	p.RecreateContainers(func(c config.Container) bool { return c.Name == "web" })
+	for _, c := range p.ListContainersByHost("node1") {
+		if c.Config.Name == "web" && len(c.Endpoints) != 0 {
+			t.Errorf("expected endpoints to be cleared on recreate, got %v", c.Endpoints)
+		}
+	}
+}
+
+func TestRecordPull_PinDigests(t *testing.T) {
+	first := "sha256:" + strings.Repeat("a", 64)
+	second := "sha256:" + strings.Repeat("b", 64)
+	m := &config.Manifest{
+		Name:       "shop",
+		PinDigests: true,
+		Containers: []config.Container{
+			{Name: "api-1", Host: "node1", Image: "shop/api:latest"},
+			{Name: "api-2", Host: "node2", Image: "shop/api:latest"},
+		},
+	}
+	p := NewPlanner()
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := p.PinnedImage("shop", "shop/api:latest"); got != "shop/api:latest" {
+		t.Errorf("expected no pin before the first pull, got %q", got)
+	}
+	p.RecordPull("node1", "api-1", config.PullResult{Image: "shop/api:latest", Error: "timeout"})
+	p.RecordPull("node2", "api-2", config.PullResult{Image: "shop/api:latest", Digest: first})
+	p.RecordPull("node1", "api-1", config.PullResult{Image: "shop/api:latest", Digest: second})
+
+	if got, want := p.PinnedImage("shop", "shop/api:latest"), "shop/api@"+first; got != want {
+		t.Errorf("expected the first digest to pin the image as %q, got %q", want, got)
+	}
+
+	// Uploading the manifest again resolves the tags again.
+	if err := p.AddManifest(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := p.PinnedImage("shop", "shop/api:latest"); got != "shop/api:latest" {
+		t.Errorf("expected the pins to be reset, got %q", got)
+	}
+
+	m.PinDigests = false
+	p.AddManifest(m)
+	p.RecordPull("node2", "api-2", config.PullResult{Image: "shop/api:latest", Digest: first})
+	if got := p.PinnedImage("shop", "shop/api:latest"); got != "shop/api:latest" {
+		t.Errorf("expected no pin without pinDigests, got %q", got)
+	}
+}
+
+func TestSetPullProgress(t *testing.T) {
+	p := setupPlanner()
+	p.UpdateState("node1", "web", config.StateNew)
+	p.UpdateState("node1", "app", config.StateRunning)
+
+	progress := config.PullProgress{Image: "nginx", Current: 10 * config.MiB, Total: 40 * config.MiB, Layers: 3}
+	if !p.SetPullProgress("node1", "web", progress) || !p.SetPullProgress("node1", "app", progress) {
+		t.Fatal("expected SetPullProgress to find the containers")
+	}
+	for _, c := range p.ListContainersByHost("node1") {
+		switch c.Config.Name {
+		case "web":
+			if c.State != config.StatePulling || c.PullProgress == nil || c.PullProgress.Current != 10*config.MiB {
+				t.Errorf("expected web to be pulling, got %q %+v", c.State, c.PullProgress)
+			}
+		case "app":
+			if c.State != config.StateRunning || c.PullProgress != nil {
+				t.Errorf("expected a running container to be left alone, got %q %+v", c.State, c.PullProgress)
+			}
+		}
+	}
+
+	p.RecordPull("node1", "web", config.PullResult{Image: "nginx", Error: "connection reset"})
+	web := p.ListContainersByHost("node1")[0]
+	if web.State != config.StateNew || web.PullProgress != nil {
+		t.Errorf("expected web to be new again once the pull is over, got %q %+v", web.State, web.PullProgress)
+	}
+
+	if p.SetPullProgress("node1", "missing", progress) {
+		t.Error("expected SetPullProgress to fail for an unknown container")
+	}
+}
+
+// Run with -race: the API encodes the listed containers while the slaves
+// report on them.
+func TestListContainers_ConcurrentProgress(t *testing.T) {
+	p := setupPlanner()
+	p.UpdateState("node1", "web", config.StateNew)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		<-start
+		for i := 0; i < 1000; i++ {
+			p.SetPullProgress("node1", "web", config.PullProgress{Image: "nginx", Current: config.ByteSize(i), Total: 1000})
+			p.RecordHook("node1", "web", config.HookResult{Hook: "postStart", ExitCode: i})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		<-start
+		for i := 0; i < 1000; i++ {
+			for _, list := range [][]config.ContainerStatus{p.ListContainersByHost("node1"), p.ListContainersByManifest("example")} {
+				if _, err := json.Marshal(list); err != nil {
+					t.Errorf("failed to encode containers: %v", err)
+					return
+				}
+			}
+		}
+	}()
+	close(start)
+	wg.Wait()
+}
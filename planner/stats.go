@@ -0,0 +1,57 @@
+package planner
+
+import (
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// RecordStats adds a sample of the resource usage of a container and drops
+// the samples older than window before it.
+func (p *Planner) RecordStats(host, containerName string, stats config.ContainerStats, window time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, cs := range p.storage[host] {
+		if cs.Config.Name != containerName {
+			continue
+		}
+		cutoff := stats.Time.Add(-window)
+		// A new slice, as the old one may still be read by the API.
+		samples := make([]config.ContainerStats, 0, len(cs.Stats)+1)
+		for _, s := range cs.Stats {
+			if s.Time.After(cutoff) && s.Time.Before(stats.Time) {
+				samples = append(samples, s)
+			}
+		}
+		cs.Stats = append(samples, stats)
+		return true
+	}
+	return false
+}
+
+// ListStats returns the stats of the containers of the manifest and host,
+// or of every manifest or host if empty, leaving out containers without any.
+func (p *Planner) ListStats(manifest, host string) []config.StatsWindow {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var res []config.StatsWindow
+	for h, containers := range p.storage {
+		if host != "" && h != host {
+			continue
+		}
+		for _, cs := range containers {
+			if len(cs.Stats) == 0 || (manifest != "" && cs.ManifestName != manifest) {
+				continue
+			}
+			res = append(res, config.StatsWindow{
+				ManifestName: cs.ManifestName,
+				Host:         h,
+				Name:         cs.Config.Name,
+				Samples:      append([]config.ContainerStats(nil), cs.Stats...),
+			})
+		}
+	}
+	return res
+}
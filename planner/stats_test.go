@@ -0,0 +1,43 @@
+package planner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+func TestRecordStats(t *testing.T) {
+	p := setupPlanner()
+	start := time.Unix(1760000000, 0)
+
+	for i := range 5 {
+		s := config.ContainerStats{Time: start.Add(time.Duration(i) * time.Minute), CPUPercent: float64(i)}
+		if !p.RecordStats("node1", "web", s, 2*time.Minute+time.Second) {
+			t.Fatal("expected RecordStats to return true")
+		}
+	}
+	if p.RecordStats("node2", "web", config.ContainerStats{Time: start}, time.Minute) {
+		t.Error("expected RecordStats to fail for a container of another host")
+	}
+
+	windows := p.ListStats("", "")
+	if len(windows) != 1 {
+		t.Fatalf("expected the stats of one container, got %d", len(windows))
+	}
+	w := windows[0]
+	if w.ManifestName != "example" || w.Host != "node1" || w.Name != "web" {
+		t.Errorf("unexpected container: %+v", w)
+	}
+	var cpu []float64
+	for _, s := range w.Samples {
+		cpu = append(cpu, s.CPUPercent)
+	}
+	if len(cpu) != 3 || cpu[0] != 2 || cpu[2] != 4 {
+		t.Errorf("expected the samples of the last 2 minutes, got %v", cpu)
+	}
+
+	if len(p.ListStats("other", "")) != 0 || len(p.ListStats("", "node2")) != 0 {
+		t.Error("expected no stats for another manifest or host")
+	}
+}
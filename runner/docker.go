@@ -3,16 +3,24 @@ package runner
 import (
 	"context"
 	"fmt"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"github.com/docker/go-units"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/rmerezha/mtrpz-lab4/config"
 	"io"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const SIGKILL = "SIGKILL"
@@ -25,6 +33,22 @@ type DockerClient interface {
 	ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error
 	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
 	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+	ContainerStatsOneShot(ctx context.Context, containerID string) (container.StatsResponseReader, error)
+	ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (container.ExecCreateResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, config container.ExecAttachOptions) (types.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error)
+	ContainerExecResize(ctx context.Context, execID string, options container.ResizeOptions) error
+
+	NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error)
+	NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error)
+	NetworkRemove(ctx context.Context, networkID string) error
+	NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error
+
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+	DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
 
 	ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error)
 	ImagePull(ctx context.Context, refStr string, options image.PullOptions) (io.ReadCloser, error)
@@ -32,6 +56,10 @@ type DockerClient interface {
 
 type DockerRunner struct {
 	cli DockerClient
+
+	mu sync.Mutex
+	// cpu holds the last CPU sample of every container Stats was called on.
+	cpu map[string]container.CPUStats
 }
 
 func NewDockerRunner() (*DockerRunner, error) {
@@ -42,25 +70,34 @@ func NewDockerRunner() (*DockerRunner, error) {
 	return &DockerRunner{cli: cli}, nil
 }
 
-func (d *DockerRunner) Run(c config.Container) error {
-	ctx := context.Background()
+func (d *DockerRunner) Run(ctx context.Context, c config.Container) error {
+	id, err := d.create(ctx, c)
+	if err != nil {
+		return err
+	}
+	return d.cli.ContainerStart(ctx, id, container.StartOptions{})
+}
+
+func (d *DockerRunner) create(ctx context.Context, c config.Container) (string, error) {
+	if err := c.Normalize(); err != nil {
+		return "", err
+	}
 
 	portBindings := nat.PortMap{}
 	exposedPorts := nat.PortSet{}
-	for _, port := range c.Ports {
-		parts := strings.Split(port, ":")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid port format: %s", port)
-		}
-		hostPort := parts[0]
-		containerPort := parts[1]
-
-		p, err := nat.NewPort("tcp", containerPort)
+	for _, spec := range c.Ports {
+		mappings, err := config.ParsePort(spec)
 		if err != nil {
-			return err
+			return "", err
+		}
+		for _, m := range mappings {
+			p, err := nat.NewPort(m.Protocol, strconv.Itoa(m.ContainerPort))
+			if err != nil {
+				return "", err
+			}
+			portBindings[p] = append(portBindings[p], nat.PortBinding{HostIP: m.HostIP, HostPort: m.HostPort})
+			exposedPorts[p] = struct{}{}
 		}
-		portBindings[p] = []nat.PortBinding{{HostPort: hostPort}}
-		exposedPorts[p] = struct{}{}
 	}
 
 	cfg := &container.Config{
@@ -69,80 +106,186 @@ func (d *DockerRunner) Run(c config.Container) error {
 		ExposedPorts: exposedPorts,
 	}
 
-	if c.Entrypoint != "" {
-		cfg.Entrypoint = strings.Fields(c.Entrypoint)
+	entrypoint, err := c.Entrypoint.Args()
+	if err != nil {
+		return "", fmt.Errorf("invalid entrypoint: %w", err)
 	}
-	if c.Cmd != "" {
-		cfg.Cmd = strings.Fields(c.Cmd)
+	cmd, err := c.Cmd.Args()
+	if err != nil {
+		return "", fmt.Errorf("invalid cmd: %w", err)
 	}
+	cfg.Entrypoint = entrypoint
+	cfg.Cmd = cmd
 
 	hostCfg := &container.HostConfig{
 		PortBindings: portBindings,
 	}
 
-	if err := applyOptions(c.Options, hostCfg, cfg); err != nil {
-		return err
+	if err := applySpec(c, hostCfg, cfg); err != nil {
+		return "", err
 	}
 
-	resp, err := d.cli.ContainerCreate(ctx, cfg, hostCfg, nil, nil, c.Name)
+	// Older daemons accept a single network on create; the others are
+	// connected before the container starts.
+	netCfg := &network.NetworkingConfig{}
+	if len(c.Networks) > 0 {
+		hostCfg.NetworkMode = container.NetworkMode(c.Networks[0])
+		netCfg.EndpointsConfig = map[string]*network.EndpointSettings{
+			c.Networks[0]: {Aliases: []string{c.Name}},
+		}
+	}
+
+	resp, err := d.cli.ContainerCreate(ctx, cfg, hostCfg, netCfg, nil, c.Name)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	for i, n := range c.Networks {
+		if i == 0 {
+			continue
+		}
+		if err := d.cli.NetworkConnect(ctx, n, resp.ID, &network.EndpointSettings{Aliases: []string{c.Name}}); err != nil {
+			// Otherwise the half-connected container blocks the next create.
+			cleanup, cancel := cleanupContext(ctx)
+			defer cancel()
+			_ = d.cli.ContainerRemove(cleanup, resp.ID, container.RemoveOptions{Force: true})
+			return "", fmt.Errorf("connect to network %s: %w", n, err)
+		}
 	}
+	return resp.ID, nil
+}
 
-	return d.cli.ContainerStart(ctx, resp.ID, container.StartOptions{})
+func (d *DockerRunner) Stop(ctx context.Context, name string) error {
+	return d.cli.ContainerStop(ctx, name, container.StopOptions{})
 }
 
-func (d *DockerRunner) Stop(name string) error {
-	return d.cli.ContainerStop(context.Background(), name, container.StopOptions{})
+func (d *DockerRunner) Kill(ctx context.Context, name string) error {
+	return d.cli.ContainerKill(ctx, name, SIGKILL)
 }
 
-func (d *DockerRunner) Kill(name string) error {
-	return d.cli.ContainerKill(context.Background(), name, SIGKILL)
+func (d *DockerRunner) Restart(ctx context.Context, name string) error {
+	return d.cli.ContainerRestart(ctx, name, container.StopOptions{})
 }
 
-func (d *DockerRunner) Restart(name string) error {
-	return d.cli.ContainerRestart(context.Background(), name, container.StopOptions{})
+func (d *DockerRunner) Remove(ctx context.Context, name string) error {
+	d.forget(name)
+	return d.cli.ContainerRemove(ctx, name, container.RemoveOptions{Force: true})
 }
 
-func (d *DockerRunner) Remove(name string) error {
-	return d.cli.ContainerRemove(context.Background(), name, container.RemoveOptions{Force: true})
+func (d *DockerRunner) State(ctx context.Context, name string) (string, error) {
+	info, err := d.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return info.State.Status, nil
 }
 
-func (d *DockerRunner) PullImage(name string) error {
-	ctx := context.Background()
-	images, err := d.cli.ImageList(ctx, image.ListOptions{})
+// Ports returns the host endpoints docker actually bound for the container,
+// formatted as "hostIP:hostPort->containerPort/protocol".
+func (d *DockerRunner) Ports(ctx context.Context, name string) ([]string, error) {
+	info, err := d.cli.ContainerInspect(ctx, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, img := range images {
-		for _, tag := range img.RepoTags {
-			if tag == name {
-				return nil
+	if info.NetworkSettings == nil {
+		return nil, nil
+	}
+
+	var res []string
+	for port, bindings := range info.NetworkSettings.Ports {
+		for _, b := range bindings {
+			if b.HostPort == "" {
+				continue
 			}
+			res = append(res, net.JoinHostPort(b.HostIP, b.HostPort)+"->"+string(port))
 		}
 	}
+	sort.Strings(res)
+	return res, nil
+}
 
-	out, err := d.cli.ImagePull(ctx, name, image.PullOptions{})
-	if err != nil {
+func (d *DockerRunner) CreateNetwork(ctx context.Context, n config.Network) error {
+	_, err := d.cli.NetworkInspect(ctx, n.Name, network.InspectOptions{})
+	if err == nil {
+		return nil
+	}
+	if !client.IsErrNotFound(err) {
 		return err
 	}
-	defer out.Close()
 
-	_, err = io.Copy(io.Discard, out)
+	_, err = d.cli.NetworkCreate(ctx, n.Name, network.CreateOptions{
+		Driver:   n.Driver,
+		Internal: n.Internal,
+		Labels:   n.Labels,
+	})
+	return err
+}
+
+func (d *DockerRunner) RemoveNetwork(ctx context.Context, name string) error {
+	info, err := d.cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	if client.IsErrNotFound(err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+	if len(info.Containers) > 0 {
+		return nil
+	}
+	return d.cli.NetworkRemove(ctx, name)
+}
 
-	return nil
+func (d *DockerRunner) CreateVolume(ctx context.Context, v config.NamedVolume) error {
+	// Creating an existing volume is a no-op for docker.
+	_, err := d.cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name:       v.Name,
+		Driver:     v.Driver,
+		DriverOpts: v.DriverOpts,
+		Labels:     v.Labels,
+	})
+	return err
+}
+
+func (d *DockerRunner) RemoveVolume(ctx context.Context, name string) error {
+	err := d.cli.VolumeRemove(ctx, name, false)
+	if client.IsErrNotFound(err) || cerrdefs.IsConflict(err) {
+		// Already gone, or still in use by another container.
+		return nil
+	}
+	return err
 }
 
-func (d *DockerRunner) State(name string) (string, error) {
-	ctx := context.Background()
+// Volumes reports the named volumes mounted in the container along with the
+// disk space they use.
+func (d *DockerRunner) Volumes(ctx context.Context, name string) ([]config.VolumeUsage, error) {
 	info, err := d.cli.ContainerInspect(ctx, name)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return info.State.Status, nil
+	var res []config.VolumeUsage
+	for _, m := range info.Mounts {
+		if m.Type == mount.TypeVolume {
+			res = append(res, config.VolumeUsage{Name: m.Name, Exists: true})
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	du, err := d.cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(du.Volumes))
+	for _, v := range du.Volumes {
+		if v.UsageData != nil && v.UsageData.Size > 0 {
+			sizes[v.Name] = v.UsageData.Size
+		}
+	}
+	for i := range res {
+		res[i].Size = config.ByteSize(sizes[res[i].Name])
+	}
+	return res, nil
 }
 
 func toEnvList(env map[string]string) []string {
@@ -153,128 +296,64 @@ func toEnvList(env map[string]string) []string {
 	return res
 }
 
-func applyOptions(opts []string, hostCfg *container.HostConfig, config *container.Config) error {
-	for _, opt := range opts {
-		switch {
-		case strings.HasPrefix(opt, "--net="), strings.HasPrefix(opt, "--network="):
-			val := strings.TrimPrefix(strings.TrimPrefix(opt, "--net="), "--network=")
-			hostCfg.NetworkMode = container.NetworkMode(val)
-
-		case strings.HasPrefix(opt, "--restart="):
-			hostCfg.RestartPolicy = container.RestartPolicy{Name: container.RestartPolicyMode(strings.TrimPrefix(opt, "--restart="))}
-
-		case strings.HasPrefix(opt, "-v "), strings.HasPrefix(opt, "--volume="):
-			val := strings.TrimPrefix(strings.TrimPrefix(opt, "--volume="), "-v ")
-			hostCfg.Binds = append(hostCfg.Binds, val)
-
-		case opt == "--privileged":
-			hostCfg.Privileged = true
-
-		case strings.HasPrefix(opt, "--memory="):
-			val := strings.TrimPrefix(opt, "--memory=")
-			var mem int64
-			_, err := fmt.Sscanf(val, "%d", &mem)
-			if err != nil {
-				return fmt.Errorf("invalid memory value: %s", val)
-			}
-			hostCfg.Memory = mem
-
-		case strings.HasPrefix(opt, "--cpus="):
-			val := strings.TrimPrefix(opt, "--cpus=")
-			var cpus float64
-			_, err := fmt.Sscanf(val, "%f", &cpus)
-			if err != nil {
-				return fmt.Errorf("invalid cpus value: %s", val)
-			}
-			hostCfg.NanoCPUs = int64(cpus * 1e9)
-
-		case strings.HasPrefix(opt, "--add-host="):
-			val := strings.TrimPrefix(opt, "--add-host=")
-			hostCfg.ExtraHosts = append(hostCfg.ExtraHosts, val)
-
-		case strings.HasPrefix(opt, "--device="):
-			val := strings.TrimPrefix(opt, "--device=")
-			hostCfg.Devices = append(hostCfg.Devices, container.DeviceMapping{PathOnHost: val, PathInContainer: val})
-
-		case strings.HasPrefix(opt, "--tmpfs="):
-			val := strings.TrimPrefix(opt, "--tmpfs=")
-			if hostCfg.Tmpfs == nil {
-				hostCfg.Tmpfs = make(map[string]string)
-			}
-			parts := strings.SplitN(val, ":", 2)
-			if len(parts) == 2 {
-				hostCfg.Tmpfs[parts[0]] = parts[1]
-			} else {
-				hostCfg.Tmpfs[val] = ""
-			}
-
-		case strings.HasPrefix(opt, "--hostname="):
-			config.Hostname = strings.TrimPrefix(opt, "--hostname=")
-
-		case strings.HasPrefix(opt, "--cap-add="):
-			hostCfg.CapAdd = append(hostCfg.CapAdd, strings.TrimPrefix(opt, "--cap-add="))
-
-		case strings.HasPrefix(opt, "--cap-drop="):
-			hostCfg.CapDrop = append(hostCfg.CapDrop, strings.TrimPrefix(opt, "--cap-drop="))
-
-		case strings.HasPrefix(opt, "--security-opt="):
-			hostCfg.SecurityOpt = append(hostCfg.SecurityOpt, strings.TrimPrefix(opt, "--security-opt="))
+func applySpec(c config.Container, hostCfg *container.HostConfig, cfg *container.Config) error {
+	for _, v := range c.Volumes {
+		hostCfg.Binds = append(hostCfg.Binds, v.String())
+	}
 
-		case strings.HasPrefix(opt, "--ipc="):
-			hostCfg.IpcMode = container.IpcMode(strings.TrimPrefix(opt, "--ipc="))
+	hostCfg.Memory = int64(c.Resources.Memory)
+	hostCfg.NanoCPUs = int64(c.Resources.CPUs * 1e9)
+	hostCfg.ShmSize = int64(c.Resources.ShmSize)
 
-		case strings.HasPrefix(opt, "--shm-size="):
-			val := strings.TrimPrefix(opt, "--shm-size=")
-			var size int64
-			_, err := fmt.Sscanf(val, "%d", &size)
-			if err != nil {
-				return fmt.Errorf("invalid shm-size: %s", val)
-			}
-			hostCfg.ShmSize = size
+	if c.Restart != "" {
+		mode, retries, err := config.ParseRestart(c.Restart)
+		if err != nil {
+			return err
+		}
+		hostCfg.RestartPolicy = container.RestartPolicy{
+			Name:              container.RestartPolicyMode(mode),
+			MaximumRetryCount: retries,
+		}
+	}
 
-		case strings.HasPrefix(opt, "--ulimit="):
-			val := strings.TrimPrefix(opt, "--ulimit=")
-			parts := strings.Split(val, "=")
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid ulimit format: %s", val)
-			}
-			name := parts[0]
-			limits := strings.Split(parts[1], ":")
-			if len(limits) != 2 {
-				return fmt.Errorf("invalid ulimit range: %s", parts[1])
-			}
-			var soft, hard int64
-			_, err := fmt.Sscanf(limits[0], "%d", &soft)
-			if err != nil {
-				return fmt.Errorf("invalid soft limit: %s", limits[0])
-			}
-			_, err = fmt.Sscanf(limits[1], "%d", &hard)
-			if err != nil {
-				return fmt.Errorf("invalid hard limit: %s", limits[1])
-			}
-			hostCfg.Ulimits = append(hostCfg.Ulimits, &units.Ulimit{Name: name, Soft: soft, Hard: hard})
+	if c.Network != "" {
+		hostCfg.NetworkMode = container.NetworkMode(c.Network)
+	}
 
-		case strings.HasPrefix(opt, "--dns="):
-			hostCfg.DNS = append(hostCfg.DNS, strings.TrimPrefix(opt, "--dns="))
+	hostCfg.CapAdd = c.Capabilities.Add
+	hostCfg.CapDrop = c.Capabilities.Drop
 
-		case strings.HasPrefix(opt, "--dns-search="):
-			hostCfg.DNSSearch = append(hostCfg.DNSSearch, strings.TrimPrefix(opt, "--dns-search="))
+	for _, u := range c.Ulimits {
+		hostCfg.Ulimits = append(hostCfg.Ulimits, &units.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
 
-		case strings.HasPrefix(opt, "--label="):
-			val := strings.TrimPrefix(opt, "--label=")
-			if config.Labels == nil {
-				config.Labels = make(map[string]string)
-			}
-			kv := strings.SplitN(val, "=", 2)
-			if len(kv) == 2 {
-				config.Labels[kv[0]] = kv[1]
-			} else {
-				config.Labels[kv[0]] = ""
-			}
+	hostCfg.DNS = c.DNS
+	hostCfg.DNSSearch = c.DNSSearch
+	hostCfg.ReadonlyRootfs = c.ReadOnly
+	hostCfg.Privileged = c.Privileged
+	hostCfg.ExtraHosts = c.ExtraHosts
+	hostCfg.SecurityOpt = c.SecurityOpt
+	hostCfg.Tmpfs = c.Tmpfs
+	if c.IPC != "" {
+		hostCfg.IpcMode = container.IpcMode(c.IPC)
+	}
 
-		default:
-			return fmt.Errorf("unsupported or unknown option: %s", opt)
+	for _, d := range c.Devices {
+		parts := strings.SplitN(d, ":", 3)
+		dev := container.DeviceMapping{PathOnHost: parts[0], PathInContainer: parts[0], CgroupPermissions: "rwm"}
+		if len(parts) > 1 {
+			dev.PathInContainer = parts[1]
 		}
+		if len(parts) > 2 {
+			dev.CgroupPermissions = parts[2]
+		}
+		hostCfg.Devices = append(hostCfg.Devices, dev)
 	}
+
+	cfg.Labels = c.Labels
+	cfg.User = c.User
+	cfg.WorkingDir = c.WorkDir
+	cfg.Hostname = c.Hostname
+
 	return nil
 }
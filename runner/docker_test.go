@@ -3,15 +3,24 @@ package runner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/docker/docker/api/types/network"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 	"github.com/rmerezha/mtrpz-lab4/config"
 )
 
@@ -20,13 +29,52 @@ type mockDockerClient struct {
 	startCalled      bool
 	pulledImages     []string
 	existingImages   []string
+	// repoDigests are the repository digests of existingImages.
+	repoDigests    map[string]string
+	lastConfig     *container.Config
+	lastHostConfig *container.HostConfig
+	lastNetConfig  *network.NetworkingConfig
+
+	networks  map[string]network.Inspect
+	connected []string
+	removed   []string
+
+	volumes      map[string]volume.CreateOptions
+	volumesInUse map[string]bool
+
+	exitCode int
+	output   string
+	lastExec []string
+	lastLogs container.LogsOptions
+
+	lastResize container.ResizeOptions
+
+	lastPull image.PullOptions
+	// pullStream is the JSON stream of ImagePull.
+	pullStream string
+
+	// stats are returned by ContainerStatsOneShot one by one.
+	stats []container.StatsResponse
 }
 
+type conflictError struct{}
+
+func (conflictError) Error() string { return "volume is in use" }
+func (conflictError) Conflict()     {}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string { return "not found" }
+func (notFoundError) NotFound()     {}
+
 func (m *mockDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
 	if containerName == "invalid" {
 		return container.CreateResponse{}, errors.New("create failed")
 	}
 	m.containerCreated = true
+	m.lastConfig = config
+	m.lastHostConfig = hostConfig
+	m.lastNetConfig = networkingConfig
 
 	if containerName == "start-err" {
 		return container.CreateResponse{}, nil
@@ -64,23 +112,141 @@ func (m *mockDockerClient) ContainerRestart(ctx context.Context, id string, opts
 }
 
 func (m *mockDockerClient) ContainerRemove(ctx context.Context, id string, opts container.RemoveOptions) error {
+	m.removed = append(m.removed, id)
 	if id == "test" && opts.Force {
 		return nil
 	}
 	return errors.New("remove failed")
 }
 
+func (m *mockDockerClient) ContainerWait(ctx context.Context, id string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error) {
+	waitCh := make(chan container.WaitResponse, 1)
+	waitCh <- container.WaitResponse{StatusCode: int64(m.exitCode)}
+	return waitCh, make(chan error)
+}
+
+func (m *mockDockerClient) ContainerLogs(ctx context.Context, id string, opts container.LogsOptions) (io.ReadCloser, error) {
+	m.lastLogs = opts
+	return io.NopCloser(m.stream()), nil
+}
+
+func (m *mockDockerClient) ContainerStatsOneShot(ctx context.Context, id string) (container.StatsResponseReader, error) {
+	if len(m.stats) == 0 {
+		return container.StatsResponseReader{}, notFoundError{}
+	}
+	data, _ := json.Marshal(m.stats[0])
+	m.stats = m.stats[1:]
+	return container.StatsResponseReader{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (m *mockDockerClient) ContainerExecCreate(ctx context.Context, id string, opts container.ExecOptions) (container.ExecCreateResponse, error) {
+	if id != "test" {
+		return container.ExecCreateResponse{}, errors.New("container not running")
+	}
+	m.lastExec = opts.Cmd
+	return container.ExecCreateResponse{ID: "exec-id"}, nil
+}
+
+func (m *mockDockerClient) ContainerExecAttach(ctx context.Context, id string, opts container.ExecAttachOptions) (types.HijackedResponse, error) {
+	conn, peer := net.Pipe()
+	go func() {
+		_, _ = peer.Write(m.stream().Bytes())
+		peer.Close()
+	}()
+	return types.NewHijackedResponse(conn, ""), nil
+}
+
+func (m *mockDockerClient) ContainerExecResize(ctx context.Context, id string, opts container.ResizeOptions) error {
+	m.lastResize = opts
+	return nil
+}
+
+func (m *mockDockerClient) ContainerExecInspect(ctx context.Context, id string) (container.ExecInspect, error) {
+	return container.ExecInspect{ExecID: id, ExitCode: m.exitCode}, nil
+}
+
+// stream frames output the way docker multiplexes stdout and stderr.
+func (m *mockDockerClient) stream() *bytes.Buffer {
+	var buf bytes.Buffer
+	_, _ = stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(m.output))
+	return &buf
+}
+
+func (m *mockDockerClient) NetworkCreate(ctx context.Context, name string, opts network.CreateOptions) (network.CreateResponse, error) {
+	if m.networks == nil {
+		m.networks = map[string]network.Inspect{}
+	}
+	m.networks[name] = network.Inspect{Name: name, Driver: opts.Driver, Labels: opts.Labels}
+	return network.CreateResponse{ID: name}, nil
+}
+
+func (m *mockDockerClient) NetworkInspect(ctx context.Context, id string, opts network.InspectOptions) (network.Inspect, error) {
+	n, ok := m.networks[id]
+	if !ok {
+		return network.Inspect{}, notFoundError{}
+	}
+	return n, nil
+}
+
+func (m *mockDockerClient) NetworkRemove(ctx context.Context, id string) error {
+	delete(m.networks, id)
+	return nil
+}
+
+func (m *mockDockerClient) NetworkConnect(ctx context.Context, id, containerID string, settings *network.EndpointSettings) error {
+	if id == "missing" {
+		return notFoundError{}
+	}
+	m.connected = append(m.connected, id)
+	return nil
+}
+
+func (m *mockDockerClient) VolumeCreate(ctx context.Context, opts volume.CreateOptions) (volume.Volume, error) {
+	if m.volumes == nil {
+		m.volumes = map[string]volume.CreateOptions{}
+	}
+	m.volumes[opts.Name] = opts
+	return volume.Volume{Name: opts.Name}, nil
+}
+
+func (m *mockDockerClient) VolumeRemove(ctx context.Context, id string, force bool) error {
+	if _, ok := m.volumes[id]; !ok {
+		return notFoundError{}
+	}
+	if m.volumesInUse[id] && !force {
+		return conflictError{}
+	}
+	delete(m.volumes, id)
+	return nil
+}
+
+func (m *mockDockerClient) DiskUsage(ctx context.Context, opts types.DiskUsageOptions) (types.DiskUsage, error) {
+	return types.DiskUsage{Volumes: []*volume.Volume{
+		{Name: "shop_data", UsageData: &volume.UsageData{Size: 4096, RefCount: 1}},
+		{Name: "other", UsageData: &volume.UsageData{Size: -1, RefCount: 0}},
+	}}, nil
+}
+
 func (m *mockDockerClient) ImageList(ctx context.Context, opts image.ListOptions) ([]image.Summary, error) {
 	var summaries []image.Summary
 	for _, tag := range m.existingImages {
-		summaries = append(summaries, image.Summary{RepoTags: []string{tag}})
+		img := image.Summary{RepoTags: []string{tag}}
+		if rd, ok := m.repoDigests[tag]; ok {
+			img.RepoDigests = []string{rd}
+		}
+		summaries = append(summaries, img)
 	}
 	return summaries, nil
 }
 
 func (m *mockDockerClient) ImagePull(ctx context.Context, ref string, opts image.PullOptions) (io.ReadCloser, error) {
 	m.pulledImages = append(m.pulledImages, ref)
-	return io.NopCloser(bytes.NewBufferString("pulled")), nil
+	m.lastPull = opts
+	stream := m.pullStream
+	if stream == "" {
+		stream = `{"status":"Pulling from library/busybox"}` + "\n" + `{"status":"Status: Downloaded newer image"}` + "\n"
+	}
+	return io.NopCloser(bytes.NewBufferString(stream)), nil
 }
 
 func (m *mockDockerClient) ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error) {
@@ -93,10 +259,23 @@ func (m *mockDockerClient) ContainerInspect(ctx context.Context, containerID str
 				Status: container.StateRunning,
 			},
 		},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeVolume, Name: "shop_data", Destination: "/data"},
+			{Type: mount.TypeBind, Source: "/etc/ssl", Destination: "/etc/ssl"},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			NetworkSettingsBase: container.NetworkSettingsBase{
+				Ports: nat.PortMap{
+					"80/tcp": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "32768"}},
+					"53/udp": []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: "5353"}},
+				},
+			},
+		},
 	}, nil
 }
 
 func TestDockerRunner_Run(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
@@ -106,7 +285,7 @@ func TestDockerRunner_Run(t *testing.T) {
 		Ports: []string{"8080:80"},
 	}
 
-	err := runner.Run(containerConfig)
+	err := runner.Run(ctx, containerConfig)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -116,7 +295,254 @@ func TestDockerRunner_Run(t *testing.T) {
 	}
 }
 
+func TestDockerRunner_Run_Networks(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	c := config.Container{Name: "api", Image: "alpine", Networks: []string{"shop_front", "shop_back"}}
+	if err := runner.Run(ctx, c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if mock.lastHostConfig.NetworkMode != "shop_front" {
+		t.Errorf("unexpected network mode %q", mock.lastHostConfig.NetworkMode)
+	}
+	ep, ok := mock.lastNetConfig.EndpointsConfig["shop_front"]
+	if !ok || len(ep.Aliases) != 1 || ep.Aliases[0] != "api" {
+		t.Errorf("unexpected endpoints config: %+v", mock.lastNetConfig.EndpointsConfig)
+	}
+	if len(mock.connected) != 1 || mock.connected[0] != "shop_back" {
+		t.Errorf("expected shop_back to be connected, got %v", mock.connected)
+	}
+}
+
+func TestDockerRunner_Run_NetworkConnectError(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	c := config.Container{Name: "api", Image: "alpine", Networks: []string{"shop_front", "missing"}}
+	if err := runner.Run(ctx, c); err == nil {
+		t.Fatal("expected error for missing network")
+	}
+	if mock.startCalled {
+		t.Error("expected container not to be started")
+	}
+	if len(mock.removed) != 1 || mock.removed[0] != "mocked-container-id" {
+		t.Errorf("expected created container to be removed, got %v", mock.removed)
+	}
+}
+
+func TestDockerRunner_Networks(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	n := config.Network{Name: "shop_front", Driver: "bridge", Labels: map[string]string{config.LabelManifest: "shop"}}
+	if err := runner.CreateNetwork(ctx, n); err != nil {
+		t.Fatalf("CreateNetwork failed: %v", err)
+	}
+	if err := runner.CreateNetwork(ctx, n); err != nil {
+		t.Fatalf("CreateNetwork of an existing network failed: %v", err)
+	}
+	if got := mock.networks["shop_front"]; got.Labels[config.LabelManifest] != "shop" {
+		t.Errorf("unexpected network: %+v", got)
+	}
+
+	inUse := mock.networks["shop_front"]
+	inUse.Containers = map[string]network.EndpointResource{"id": {Name: "api"}}
+	mock.networks["shop_front"] = inUse
+	if err := runner.RemoveNetwork(ctx, "shop_front"); err != nil {
+		t.Fatalf("RemoveNetwork failed: %v", err)
+	}
+	if _, ok := mock.networks["shop_front"]; !ok {
+		t.Error("network in use must not be removed")
+	}
+
+	inUse.Containers = nil
+	mock.networks["shop_front"] = inUse
+	if err := runner.RemoveNetwork(ctx, "shop_front"); err != nil {
+		t.Fatalf("RemoveNetwork failed: %v", err)
+	}
+	if _, ok := mock.networks["shop_front"]; ok {
+		t.Error("expected network to be removed")
+	}
+	if err := runner.RemoveNetwork(ctx, "shop_front"); err != nil {
+		t.Errorf("removing a missing network should succeed, got %v", err)
+	}
+}
+
+func TestDockerRunner_Volumes(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{volumesInUse: map[string]bool{"shop_data": true}}
+	runner := &DockerRunner{cli: mock}
+
+	v := config.NamedVolume{Name: "shop_data", Driver: "local", DriverOpts: map[string]string{"type": "tmpfs"}}
+	if err := runner.CreateVolume(ctx, v); err != nil {
+		t.Fatalf("CreateVolume failed: %v", err)
+	}
+	if got := mock.volumes["shop_data"]; got.Driver != "local" || got.DriverOpts["type"] != "tmpfs" {
+		t.Errorf("unexpected volume: %+v", got)
+	}
+
+	usage, err := runner.Volumes(ctx, "test")
+	if err != nil {
+		t.Fatalf("Volumes failed: %v", err)
+	}
+	want := []config.VolumeUsage{{Name: "shop_data", Exists: true, Size: 4096}}
+	if len(usage) != 1 || usage[0] != want[0] {
+		t.Errorf("expected %+v, got %+v", want, usage)
+	}
+
+	if err := runner.RemoveVolume(ctx, "shop_data"); err != nil {
+		t.Fatalf("RemoveVolume of a volume in use failed: %v", err)
+	}
+	if _, ok := mock.volumes["shop_data"]; !ok {
+		t.Error("volume in use must not be removed")
+	}
+	mock.volumesInUse = nil
+	if err := runner.RemoveVolume(ctx, "shop_data"); err != nil {
+		t.Fatalf("RemoveVolume failed: %v", err)
+	}
+	if _, ok := mock.volumes["shop_data"]; ok {
+		t.Error("expected volume to be removed")
+	}
+	if err := runner.RemoveVolume(ctx, "shop_data"); err != nil {
+		t.Errorf("removing a missing volume should succeed, got %v", err)
+	}
+}
+
+func TestDockerRunner_Run_TypedFields(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	c := config.Container{
+		Name:      "test-container",
+		Image:     "alpine",
+		Volumes:   []config.Volume{{Source: "/data", Target: "/data", ReadOnly: true}},
+		Resources: config.Resources{Memory: 1024, CPUs: 0.5},
+		Restart:   "on-failure:5",
+		User:      "nobody",
+		ReadOnly:  true,
+		Options:   []string{"--dns=1.1.1.1"},
+	}
+
+	if err := runner.Run(ctx, c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	hc := mock.lastHostConfig
+	if len(hc.Binds) != 1 || hc.Binds[0] != "/data:/data:ro" {
+		t.Errorf("unexpected binds %v", hc.Binds)
+	}
+	if hc.Memory != 1024 || hc.NanoCPUs != 5e8 {
+		t.Errorf("unexpected resources memory=%d nanocpus=%d", hc.Memory, hc.NanoCPUs)
+	}
+	if hc.RestartPolicy.Name != "on-failure" || hc.RestartPolicy.MaximumRetryCount != 5 {
+		t.Errorf("unexpected restart policy %+v", hc.RestartPolicy)
+	}
+	if !hc.ReadonlyRootfs {
+		t.Error("expected read-only rootfs")
+	}
+	if len(hc.DNS) != 1 || hc.DNS[0] != "1.1.1.1" {
+		t.Errorf("expected legacy --dns option to be applied, got %v", hc.DNS)
+	}
+	if mock.lastConfig.User != "nobody" {
+		t.Errorf("expected user 'nobody', got %q", mock.lastConfig.User)
+	}
+}
+
+func TestDockerRunner_Run_UnknownOption(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	c := config.Container{
+		Name:    "test-container",
+		Image:   "alpine",
+		Options: []string{"--bogus"},
+	}
+
+	if err := runner.Run(ctx, c); err == nil {
+		t.Error("expected error for unknown option")
+	}
+	if mock.containerCreated {
+		t.Error("expected container not to be created")
+	}
+}
+
+func TestDockerRunner_Run_PortBindings(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	c := config.Container{
+		Name:  "test-container",
+		Image: "alpine",
+		Ports: []string{"127.0.0.1:8080:80", "53/udp", "9000-9001:9000-9001"},
+	}
+
+	if err := runner.Run(ctx, c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	bindings := mock.lastHostConfig.PortBindings
+	if b := bindings["80/tcp"]; len(b) != 1 || b[0].HostIP != "127.0.0.1" || b[0].HostPort != "8080" {
+		t.Errorf("unexpected binding for 80/tcp: %+v", b)
+	}
+	if b := bindings["53/udp"]; len(b) != 1 || b[0].HostPort != "" {
+		t.Errorf("expected random host port for 53/udp, got %+v", b)
+	}
+	if b := bindings["9001/tcp"]; len(b) != 1 || b[0].HostPort != "9001" {
+		t.Errorf("unexpected binding for 9001/tcp: %+v", b)
+	}
+	if len(mock.lastConfig.ExposedPorts) != 4 {
+		t.Errorf("expected 4 exposed ports, got %d", len(mock.lastConfig.ExposedPorts))
+	}
+}
+
+func TestDockerRunner_Ports(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	ports, err := runner.Ports(ctx, "test")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []string{"0.0.0.0:32768->80/tcp", "127.0.0.1:5353->53/udp"}
+	if strings.Join(ports, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, ports)
+	}
+}
+
+func TestDockerRunner_Run_ShellQuotedCmd(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	c := config.Container{
+		Name:       "test-container",
+		Image:      "alpine",
+		Entrypoint: config.NewCommand("/bin/sh", "-c"),
+		Cmd:        `"echo hello world"`,
+	}
+
+	if err := runner.Run(ctx, c); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := mock.lastConfig.Entrypoint; len(got) != 2 || got[0] != "/bin/sh" || got[1] != "-c" {
+		t.Errorf("unexpected entrypoint %q", got)
+	}
+	if got := mock.lastConfig.Cmd; len(got) != 1 || got[0] != "echo hello world" {
+		t.Errorf("unexpected cmd %q", got)
+	}
+}
+
 func TestDockerRunner_Run_InvalidPortFormat(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
@@ -126,13 +552,14 @@ func TestDockerRunner_Run_InvalidPortFormat(t *testing.T) {
 		Ports: []string{"badformat"},
 	}
 
-	err := runner.Run(c)
+	err := runner.Run(ctx, c)
 	if err == nil || !strings.Contains(err.Error(), "invalid port format") {
 		t.Errorf("expected invalid port format error, got %v", err)
 	}
 }
 
 func TestDockerRunner_Run_CreateError(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
@@ -142,13 +569,14 @@ func TestDockerRunner_Run_CreateError(t *testing.T) {
 		Ports: []string{"8080:80"},
 	}
 
-	err := runner.Run(c)
+	err := runner.Run(ctx, c)
 	if err == nil || !strings.Contains(err.Error(), "create failed") {
 		t.Errorf("expected create error, got %v", err)
 	}
 }
 
 func TestDockerRunner_Run_StartError(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
@@ -158,17 +586,18 @@ func TestDockerRunner_Run_StartError(t *testing.T) {
 		Ports: []string{"8080:80"},
 	}
 
-	err := runner.Run(c)
+	err := runner.Run(ctx, c)
 	if err == nil || !strings.Contains(err.Error(), "start failed") {
 		t.Errorf("expected start error, got %v", err)
 	}
 }
 
 func TestDockerRunner_PullImage_AlreadyExists(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{existingImages: []string{"alpine"}}
 	runner := &DockerRunner{cli: mock}
 
-	err := runner.PullImage("alpine")
+	_, err := runner.PullImage(ctx, "alpine", PullOptions{})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -178,10 +607,11 @@ func TestDockerRunner_PullImage_AlreadyExists(t *testing.T) {
 }
 
 func TestDockerRunner_PullImage_NewImage(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
-	err := runner.PullImage("busybox")
+	_, err := runner.PullImage(ctx, "busybox", PullOptions{})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -190,51 +620,161 @@ func TestDockerRunner_PullImage_NewImage(t *testing.T) {
 	}
 }
 
+func TestDockerRunner_PullImage_Auth(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{}
+	runner := &DockerRunner{cli: mock}
+
+	auth := &config.RegistryAuth{Registry: "registry.example.com", Username: "ci", Password: "s3cret"}
+	if _, err := runner.PullImage(ctx, "registry.example.com/app:1", PullOptions{Auth: auth}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	decoded, err := registry.DecodeAuthConfig(mock.lastPull.RegistryAuth)
+	if err != nil {
+		t.Fatalf("failed to decode RegistryAuth: %v", err)
+	}
+	if decoded.Username != "ci" || decoded.Password != "s3cret" || decoded.ServerAddress != "registry.example.com" {
+		t.Errorf("unexpected credentials: %+v", decoded)
+	}
+
+	mock.pullStream = `{"status":"Pulling from app"}` + "\n" +
+		`{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}` + "\n"
+	if _, err := runner.PullImage(ctx, "registry.example.com/app:2", PullOptions{}); !errors.Is(err, ErrRegistryAuth) {
+		t.Errorf("expected ErrRegistryAuth, got %v", err)
+	}
+
+	mock.pullStream = `{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}` + "\n"
+	_, err = runner.PullImage(ctx, "registry.example.com/app:3", PullOptions{Auth: auth})
+	if err == nil || errors.Is(err, ErrRegistryAuth) {
+		t.Errorf("expected a plain pull error, got %v", err)
+	}
+}
+
+func TestDockerRunner_PullImage_Policy(t *testing.T) {
+	ctx := context.Background()
+	local := "sha256:" + strings.Repeat("a", 64)
+	remote := "sha256:" + strings.Repeat("b", 64)
+	mock := &mockDockerClient{
+		existingImages: []string{"nginx:latest"},
+		repoDigests:    map[string]string{"nginx:latest": "nginx@" + local},
+		pullStream:     `{"status":"Pulling from library/nginx"}` + "\n" + `{"status":"Digest: ` + remote + `"}` + "\n",
+	}
+	runner := &DockerRunner{cli: mock}
+
+	dgst, err := runner.PullImage(ctx, "nginx", PullOptions{Policy: config.PullIfNotPresent})
+	if err != nil || dgst != local {
+		t.Errorf("expected the local digest, got %q, %v", dgst, err)
+	}
+	if len(mock.pulledImages) != 0 {
+		t.Error("expected a present image not to be pulled")
+	}
+
+	dgst, err = runner.PullImage(ctx, "nginx", PullOptions{Policy: config.PullAlways})
+	if err != nil || dgst != remote {
+		t.Errorf("expected the digest of the pull, got %q, %v", dgst, err)
+	}
+	if len(mock.pulledImages) != 1 {
+		t.Error("expected the image to be pulled")
+	}
+
+	if dgst, err := runner.PullImage(ctx, "nginx@"+local, PullOptions{}); err != nil || dgst != local {
+		t.Errorf("expected a digest reference to be found locally, got %q, %v", dgst, err)
+	}
+
+	if _, err := runner.PullImage(ctx, "redis", PullOptions{Policy: config.PullNever}); err == nil {
+		t.Error("expected the pull policy never to fail for a missing image")
+	}
+	if len(mock.pulledImages) != 1 {
+		t.Error("expected the pull policy never not to pull")
+	}
+}
+
+func TestDockerRunner_PullImage_Progress(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockDockerClient{pullStream: strings.Join([]string{
+		`{"status":"Pulling from library/nginx","id":"latest"}`,
+		`{"status":"Already exists","progressDetail":{},"id":"aaa"}`,
+		`{"status":"Pulling fs layer","progressDetail":{},"id":"bbb"}`,
+		`{"status":"Pulling fs layer","progressDetail":{},"id":"ccc"}`,
+		`{"status":"Downloading","progressDetail":{"current":100,"total":400},"id":"bbb"}`,
+		`{"status":"Downloading","progressDetail":{"current":50,"total":100},"id":"ccc"}`,
+		`{"status":"Downloading","progressDetail":{"current":300,"total":400},"id":"bbb"}`,
+		`{"status":"Download complete","progressDetail":{},"id":"ccc"}`,
+		`{"status":"Extracting","progressDetail":{"current":10,"total":100},"id":"ccc"}`,
+		`{"status":"Extracting","progressDetail":{"current":90,"total":100},"id":"ccc"}`,
+		`{"status":"Digest: sha256:` + strings.Repeat("c", 64) + `"}`,
+	}, "\n")}
+	runner := &DockerRunner{cli: mock}
+
+	var reports []config.PullProgress
+	_, err := runner.PullImage(ctx, "nginx", PullOptions{Progress: func(p config.PullProgress) {
+		reports = append(reports, p)
+	}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(reports) != 7 {
+		t.Fatalf("expected a report for every change of a layer, got %d: %+v", len(reports), reports)
+	}
+	last := reports[len(reports)-1]
+	if last.Image != "nginx" || last.Layers != 3 || last.Done != 2 || last.Current != 400 || last.Total != 500 {
+		t.Errorf("unexpected progress %+v", last)
+	}
+	if p := last.Percent(); p != 80 {
+		t.Errorf("expected 80%%, got %v", p)
+	}
+}
+
 func TestDockerRunner_Stop(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
-	err := runner.Stop("test")
+	err := runner.Stop(ctx, "test")
 	if err != nil {
 		t.Errorf("expected stop to succeed, got error: %v", err)
 	}
 }
 
 func TestDockerRunner_Kill(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
-	err := runner.Kill("test")
+	err := runner.Kill(ctx, "test")
 	if err != nil {
 		t.Errorf("expected kill to succeed, got error: %v", err)
 	}
 }
 
 func TestDockerRunner_Restart(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
-	err := runner.Restart("test")
+	err := runner.Restart(ctx, "test")
 	if err != nil {
 		t.Errorf("expected restart to succeed, got error: %v", err)
 	}
 }
 
 func TestDockerRunner_Remove(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
-	err := runner.Remove("test")
+	err := runner.Remove(ctx, "test")
 	if err != nil {
 		t.Errorf("expected remove to succeed, got error: %v", err)
 	}
 }
 
 func TestDockerRunner_State(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
-	state, err := runner.State("test")
+	state, err := runner.State(ctx, "test")
 	if err != nil {
 		t.Errorf("expected state to succeed, got error: %v", err)
 	}
@@ -244,11 +784,186 @@ func TestDockerRunner_State(t *testing.T) {
 }
 
 func TestDockerRunner_State_Fail(t *testing.T) {
+	ctx := context.Background()
 	mock := &mockDockerClient{}
 	runner := &DockerRunner{cli: mock}
 
-	_, err := runner.State("invalid")
+	_, err := runner.State(ctx, "invalid")
 	if err == nil {
 		t.Errorf("expected state to fail")
 	}
 }
+
+func TestDockerRunner_Exec(t *testing.T) {
+	mock := &mockDockerClient{exitCode: 2, output: "migration failed\n"}
+	runner := &DockerRunner{cli: mock}
+	ctx := context.Background()
+
+	res, err := runner.Exec(ctx, "test", []string{"sh", "-c", "exit 2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ExitCode != 2 || res.Output != "migration failed\n" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if strings.Join(mock.lastExec, " ") != "sh -c exit 2" {
+		t.Errorf("unexpected command: %v", mock.lastExec)
+	}
+
+	if _, err := runner.Exec(ctx, "missing", []string{"true"}); err == nil {
+		t.Error("expected error for a container that is not running")
+	}
+}
+
+func TestDockerRunner_RunOnce(t *testing.T) {
+	mock := &mockDockerClient{exitCode: 3, output: strings.Repeat("x", maxOutput+10)}
+	runner := &DockerRunner{cli: mock}
+
+	res, err := runner.RunOnce(context.Background(), config.Container{Name: "web-prestart-0", Image: "busybox", Cmd: "false"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", res.ExitCode)
+	}
+	if len(res.Output) != maxOutput {
+		t.Errorf("expected output truncated to %d bytes, got %d", maxOutput, len(res.Output))
+	}
+	if !mock.startCalled {
+		t.Error("expected the container to be started")
+	}
+}
+
+func TestDockerRunner_Wait(t *testing.T) {
+	mock := &mockDockerClient{exitCode: 1, output: "report failed\n"}
+	runner := &DockerRunner{cli: mock}
+
+	res, err := runner.Wait(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ExitCode != 1 || res.Output != "report failed\n" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestDockerRunner_Logs(t *testing.T) {
+	mock := &mockDockerClient{output: "line 1\nline 2\n"}
+	runner := &DockerRunner{cli: mock}
+
+	since := time.Unix(1760000000, 0)
+	logs, err := runner.Logs(context.Background(), "test", true, since, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer logs.Close()
+	out, err := io.ReadAll(logs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != "line 1\nline 2\n" {
+		t.Errorf("expected the demultiplexed output, got %q", out)
+	}
+	if !mock.lastLogs.Follow || mock.lastLogs.Since != "1760000000" || mock.lastLogs.Tail != "10" {
+		t.Errorf("unexpected options: %+v", mock.lastLogs)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\nb\n", 5, "a\nb\n"},
+		{"", 1, ""},
+	} {
+		if got := string(tailLines([]byte(tt.in), tt.n)); got != tt.want {
+			t.Errorf("tailLines(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDockerRunner_ExecSession(t *testing.T) {
+	mock := &mockDockerClient{exitCode: 130, output: "$ "}
+	runner := &DockerRunner{cli: mock}
+	ctx := context.Background()
+
+	s, err := runner.ExecSession(ctx, "test", []string{"sh"}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer s.Close()
+	out, err := io.ReadAll(s)
+	if err != nil || string(out) != "$ " {
+		t.Errorf("expected the demultiplexed output, got %q, %v", out, err)
+	}
+	if err := s.Resize(120, 40); err != nil || mock.lastResize.Width != 120 || mock.lastResize.Height != 40 {
+		t.Errorf("unexpected resize: %+v, %v", mock.lastResize, err)
+	}
+	if code, err := s.Wait(ctx); err != nil || code != 130 {
+		t.Errorf("expected exit code 130, got %d, %v", code, err)
+	}
+
+	if _, err := runner.ExecSession(ctx, "missing", []string{"sh"}, true); err == nil {
+		t.Error("expected error for a container that is not running")
+	}
+}
+
+func TestDockerRunner_Stats(t *testing.T) {
+	sample := func(cpu, system uint64) container.StatsResponse {
+		return container.StatsResponse{
+			CPUStats: container.CPUStats{
+				CPUUsage:    container.CPUUsage{TotalUsage: cpu},
+				SystemUsage: system,
+				OnlineCPUs:  4,
+			},
+			MemoryStats: container.MemoryStats{
+				Usage: 300 << 20,
+				Limit: 1 << 30,
+				Stats: map[string]uint64{"inactive_file": 100 << 20},
+			},
+			Networks: map[string]container.NetworkStats{
+				"eth0": {RxBytes: 1000, TxBytes: 200},
+				"eth1": {RxBytes: 10, TxBytes: 2},
+			},
+			BlkioStats: container.BlkioStats{IoServiceBytesRecursive: []container.BlkioStatEntry{
+				{Op: "read", Value: 4096},
+				{Op: "write", Value: 8192},
+				{Op: "Write", Value: 8192},
+			}},
+		}
+	}
+	mock := &mockDockerClient{stats: []container.StatsResponse{sample(1e9, 100e9), sample(3e9, 110e9)}}
+	runner := &DockerRunner{cli: mock}
+
+	first, err := runner.Stats(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.CPUPercent != 0 {
+		t.Errorf("expected no CPU usage without a previous sample, got %v", first.CPUPercent)
+	}
+	want := config.ContainerStats{
+		MemoryUsage: 200 * config.MiB,
+		MemoryLimit: config.GiB,
+		NetworkRx:   1010,
+		NetworkTx:   202,
+		BlockRead:   4096,
+		BlockWrite:  16384,
+	}
+	if first != want {
+		t.Errorf("expected %+v, got %+v", want, first)
+	}
+
+	second, err := runner.Stats(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 2s of CPU in 10s of the 4 CPUs of the host.
+	if second.CPUPercent != 80 {
+		t.Errorf("expected 80%% CPU, got %v", second.CPUPercent)
+	}
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// maxOutput bounds the output kept from a hook, which is reported to the
+// master.
+const maxOutput = 4096
+
+// cleanupTimeout bounds killing, reading the logs of and removing a container
+// after its operation is done.
+const cleanupTimeout = 10 * time.Second
+
+// Result is the outcome of a command run to completion.
+type Result struct {
+	ExitCode int
+	Output   string
+}
+
+// Exec runs cmd inside the running container and waits for it until ctx is
+// done.
+func (d *DockerRunner) Exec(ctx context.Context, name string, cmd []string) (Result, error) {
+	exec, err := d.cli.ContainerExecCreate(ctx, name, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	resp, err := d.cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Close()
+
+	// The hijacked connection ignores ctx, so close it when ctx is done.
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+
+	var out bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &out, resp.Reader); err != nil && ctx.Err() == nil {
+		return Result{}, err
+	}
+	if ctx.Err() != nil {
+		return Result{Output: tail(out.Bytes())}, ctx.Err()
+	}
+
+	info, err := d.cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{ExitCode: info.ExitCode, Output: tail(out.Bytes())}, nil
+}
+
+// RunOnce runs c to completion and removes it. The container is killed when
+// the deadline of ctx expires.
+func (d *DockerRunner) RunOnce(ctx context.Context, c config.Container) (Result, error) {
+	// A leftover from an interrupted run would make create fail.
+	_ = d.cli.ContainerRemove(ctx, c.Name, container.RemoveOptions{Force: true})
+
+	id, err := d.create(ctx, c)
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() {
+		cleanup, cancel := cleanupContext(ctx)
+		defer cancel()
+		_ = d.cli.ContainerRemove(cleanup, id, container.RemoveOptions{Force: true})
+	}()
+
+	if err := d.cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		return Result{}, err
+	}
+	return d.wait(ctx, id)
+}
+
+// Wait waits for the container to exit and kills it when the deadline of ctx
+// expires. A cancelled ctx leaves the container running.
+func (d *DockerRunner) Wait(ctx context.Context, name string) (Result, error) {
+	return d.wait(ctx, name)
+}
+
+func (d *DockerRunner) wait(ctx context.Context, id string) (Result, error) {
+	// not-running returns at once for a container that already exited.
+	waitCh, errCh := d.cli.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+
+	var res Result
+	var err error
+	select {
+	case w := <-waitCh:
+		res.ExitCode = int(w.StatusCode)
+		if w.Error != nil {
+			err = errors.New(w.Error.Message)
+		}
+	case err = <-errCh:
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}
+
+	cleanup, cancel := cleanupContext(ctx)
+	defer cancel()
+	if errors.Is(err, context.DeadlineExceeded) {
+		_ = d.cli.ContainerKill(cleanup, id, SIGKILL)
+	}
+	logs, logErr := d.cli.ContainerLogs(cleanup, id, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if logErr == nil {
+		var out bytes.Buffer
+		_, _ = stdcopy.StdCopy(&out, &out, logs)
+		logs.Close()
+		res.Output = tail(out.Bytes())
+	}
+	return res, err
+}
+
+// cleanupContext bounds the cleanup after an operation whose ctx may already
+// be done.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+func tail(b []byte) string {
+	if len(b) > maxOutput {
+		b = b[len(b)-maxOutput:]
+	}
+	return string(b)
+}
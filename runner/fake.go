@@ -0,0 +1,616 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// crashExitCode is the exit code of a container crashed by the FakeRunner.
+const crashExitCode = 1
+
+// FakeBehavior scripts a FakeRunner. Durations are real time.
+type FakeBehavior struct {
+	// PullLatency is how long PullImage takes, and ImageSize the size of
+	// the image it reports progress for, 100MiB by default.
+	PullLatency time.Duration   `yaml:"pullLatency,omitempty"`
+	ImageSize   config.ByteSize `yaml:"imageSize,omitempty"`
+	// FailImages are images, matched exactly, that PullImage and Run
+	// refuse.
+	FailImages []string `yaml:"failImages,omitempty"`
+	// Digests are the digests images resolve to in their registry. Other
+	// images resolve to a digest derived from their name.
+	Digests map[string]string `yaml:"digests,omitempty"`
+	// Registries are private registries by host, with the credentials they
+	// accept as user:password. Pulls of their images without those fail
+	// with ErrRegistryAuth.
+	Registries map[string]string `yaml:"registries,omitempty"`
+	// ExitCodes makes containers of these images exit with the code once
+	// they ran for RunDuration, like jobs. Other containers run until
+	// stopped or crashed.
+	ExitCodes   map[string]int `yaml:"exitCodes,omitempty"`
+	RunDuration time.Duration  `yaml:"runDuration,omitempty"`
+	// CrashRate is the mean number of crashes of a running container per
+	// hour. A crashed container exits with code 1 and is restarted
+	// according to its restart policy.
+	CrashRate float64 `yaml:"crashRate,omitempty"`
+	// Errors makes every call of a method fail with the message, keyed by
+	// method name, e.g. "Stop".
+	Errors map[string]string `yaml:"errors,omitempty"`
+	// Seed seeds crashes; zero picks a random seed.
+	Seed int64 `yaml:"seed,omitempty"`
+}
+
+// FakeCall is a call recorded by the FakeRunner. Name is the container,
+// image, network or volume the call was about.
+type FakeCall struct {
+	Method string
+	Name   string
+	Time   time.Time
+}
+
+// FakeRunner implements Runner in memory, to test manifests and the planner
+// without Docker.
+type FakeRunner struct {
+	behavior FakeBehavior
+
+	mu         sync.Mutex
+	rand       *rand.Rand
+	calls      []FakeCall
+	containers map[string]*fakeContainer
+	networks   map[string]config.Network
+	volumes    map[string]config.NamedVolume
+	// images are the pulled images with their digest.
+	images   map[string]string
+	nextPort int
+}
+
+type fakeContainer struct {
+	config    config.Container
+	state     config.ContainerState
+	exitCode  int
+	restarts  int
+	endpoints []string
+	// exitAt is when the running container exits by itself, if ever.
+	exitAt time.Time
+	// io is the network and block I/O of the current run.
+	io config.ContainerStats
+}
+
+func NewFakeRunner(b FakeBehavior) *FakeRunner {
+	seed := b.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &FakeRunner{
+		behavior:   b,
+		rand:       rand.New(rand.NewSource(seed)),
+		containers: make(map[string]*fakeContainer),
+		networks:   make(map[string]config.Network),
+		volumes:    make(map[string]config.NamedVolume),
+		images:     make(map[string]string),
+		nextPort:   32768,
+	}
+}
+
+// Calls returns the calls made so far, oldest first.
+func (f *FakeRunner) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// Container returns the config the container was run with.
+func (f *FakeRunner) Container(name string) (config.Container, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.containers[name]
+	if !ok {
+		return config.Container{}, false
+	}
+	return c.config, true
+}
+
+// call records a call and returns the scripted error of the method. f.mu
+// must be held.
+func (f *FakeRunner) call(method, name string) error {
+	f.calls = append(f.calls, FakeCall{Method: method, Name: name, Time: time.Now()})
+	if msg, ok := f.behavior.Errors[method]; ok {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+func (f *FakeRunner) Run(ctx context.Context, c config.Container) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Run", c.Name); err != nil {
+		return err
+	}
+	if err := c.Normalize(); err != nil {
+		return err
+	}
+	if _, ok := f.containers[c.Name]; ok {
+		return fmt.Errorf("conflict: container %s already exists", c.Name)
+	}
+	if slices.Contains(f.behavior.FailImages, c.Image) {
+		return fmt.Errorf("no such image: %s", c.Image)
+	}
+	endpoints, err := f.endpoints(c.Ports)
+	if err != nil {
+		return err
+	}
+
+	fc := &fakeContainer{config: c, endpoints: endpoints}
+	f.start(fc, time.Now())
+	f.containers[c.Name] = fc
+	return nil
+}
+
+// endpoints binds ports like docker does, picking host ports from 32768 on.
+func (f *FakeRunner) endpoints(ports config.PortList) ([]string, error) {
+	var res []string
+	for _, spec := range ports {
+		mappings, err := config.ParsePort(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mappings {
+			hostIP, hostPort := m.HostIP, m.HostPort
+			if hostIP == "" {
+				hostIP = "0.0.0.0"
+			}
+			if hostPort == "" {
+				hostPort = strconv.Itoa(f.nextPort)
+				f.nextPort++
+			}
+			res = append(res, fmt.Sprintf("%s->%d/%s", net.JoinHostPort(hostIP, hostPort), m.ContainerPort, m.Protocol))
+		}
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// start runs fc from now on and schedules its exit. f.mu must be held.
+func (f *FakeRunner) start(fc *fakeContainer, now time.Time) {
+	fc.state = config.StateRunning
+	fc.exitAt = time.Time{}
+	fc.io = config.ContainerStats{}
+	if _, ok := f.behavior.ExitCodes[fc.config.Image]; ok {
+		fc.exitAt = now.Add(f.behavior.RunDuration)
+	} else if f.behavior.CrashRate > 0 {
+		hours := f.rand.ExpFloat64() / f.behavior.CrashRate
+		fc.exitAt = now.Add(time.Duration(hours * float64(time.Hour)))
+	}
+}
+
+// refresh lets the container exit if its time has come, and restarts it
+// once according to its restart policy. f.mu must be held.
+func (f *FakeRunner) refresh(fc *fakeContainer) {
+	now := time.Now()
+	if fc.state != config.StateRunning || fc.exitAt.IsZero() || now.Before(fc.exitAt) {
+		return
+	}
+	fc.state = config.StateExited
+	fc.exitCode = crashExitCode
+	if code, ok := f.behavior.ExitCodes[fc.config.Image]; ok {
+		fc.exitCode = code
+	}
+	if shouldRestart(fc.config.Restart, fc.exitCode, fc.restarts) {
+		fc.restarts++
+		f.start(fc, now)
+	}
+}
+
+func (f *FakeRunner) get(name string) (*fakeContainer, error) {
+	fc, ok := f.containers[name]
+	if !ok {
+		return nil, fmt.Errorf("no such container: %s", name)
+	}
+	f.refresh(fc)
+	return fc, nil
+}
+
+func (f *FakeRunner) Stop(ctx context.Context, name string) error {
+	return f.exit("Stop", name, 0)
+}
+
+func (f *FakeRunner) Kill(ctx context.Context, name string) error {
+	return f.exit("Kill", name, 137)
+}
+
+func (f *FakeRunner) exit(method, name string, code int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(method, name); err != nil {
+		return err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	if fc.state == config.StateRunning {
+		fc.state = config.StateExited
+		fc.exitCode = code
+	}
+	return nil
+}
+
+func (f *FakeRunner) Restart(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Restart", name); err != nil {
+		return err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	f.start(fc, time.Now())
+	return nil
+}
+
+func (f *FakeRunner) Remove(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Remove", name); err != nil {
+		return err
+	}
+	if _, ok := f.containers[name]; !ok {
+		return fmt.Errorf("no such container: %s", name)
+	}
+	delete(f.containers, name)
+	return nil
+}
+
+func (f *FakeRunner) PullImage(ctx context.Context, name string, opts PullOptions) (string, error) {
+	f.mu.Lock()
+	err := f.call("PullImage", name)
+	dgst, found := f.images[name]
+	f.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	if found && opts.Policy != config.PullAlways {
+		return dgst, nil
+	}
+	if opts.Policy == config.PullNever {
+		return "", fmt.Errorf("image %s is not present and the pull policy is %s", name, config.PullNever)
+	}
+	if err := f.download(ctx, name, opts.Progress); err != nil {
+		return "", err
+	}
+	if want, ok := f.behavior.Registries[config.ImageRegistry(name)]; ok {
+		if opts.Auth == nil || opts.Auth.Username+":"+opts.Auth.Password != want {
+			return "", fmt.Errorf("%w: unauthorized: authentication required for %s", ErrRegistryAuth, name)
+		}
+	}
+	if slices.Contains(f.behavior.FailImages, name) {
+		return "", fmt.Errorf("pull access denied for %s", name)
+	}
+
+	dgst = config.ImageDigest(name)
+	if dgst == "" {
+		dgst = f.behavior.Digests[name]
+	}
+	if dgst == "" {
+		dgst = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(name)))
+	}
+	f.mu.Lock()
+	f.images[name] = dgst
+	f.mu.Unlock()
+	return dgst, nil
+}
+
+// download waits for PullLatency, reporting progress in ten steps.
+func (f *FakeRunner) download(ctx context.Context, name string, progress func(config.PullProgress)) error {
+	if progress == nil || f.behavior.PullLatency <= 0 {
+		return sleep(ctx, f.behavior.PullLatency)
+	}
+	size := f.behavior.ImageSize
+	if size <= 0 {
+		size = 100 * config.MiB
+	}
+	const steps = 10
+	p := config.PullProgress{Image: name, Started: time.Now(), Total: size, Layers: 1}
+	for i := 1; i <= steps; i++ {
+		progress(p)
+		if err := sleep(ctx, f.behavior.PullLatency/steps); err != nil {
+			return err
+		}
+		p.Current = size * config.ByteSize(i) / steps
+	}
+	p.Done = 1
+	progress(p)
+	return nil
+}
+
+func (f *FakeRunner) State(ctx context.Context, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("State", name); err != nil {
+		return "", err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return "", err
+	}
+	return string(fc.state), nil
+}
+
+func (f *FakeRunner) Ports(ctx context.Context, name string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Ports", name); err != nil {
+		return nil, err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(fc.endpoints), nil
+}
+
+func (f *FakeRunner) CreateNetwork(ctx context.Context, n config.Network) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("CreateNetwork", n.Name); err != nil {
+		return err
+	}
+	if _, ok := f.networks[n.Name]; !ok {
+		f.networks[n.Name] = n
+	}
+	return nil
+}
+
+func (f *FakeRunner) RemoveNetwork(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("RemoveNetwork", name); err != nil {
+		return err
+	}
+	for _, fc := range f.containers {
+		if slices.Contains(fc.config.Networks, name) {
+			return nil
+		}
+	}
+	delete(f.networks, name)
+	return nil
+}
+
+func (f *FakeRunner) CreateVolume(ctx context.Context, v config.NamedVolume) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("CreateVolume", v.Name); err != nil {
+		return err
+	}
+	if _, ok := f.volumes[v.Name]; !ok {
+		f.volumes[v.Name] = v
+	}
+	return nil
+}
+
+func (f *FakeRunner) RemoveVolume(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("RemoveVolume", name); err != nil {
+		return err
+	}
+	for _, fc := range f.containers {
+		for _, v := range fc.config.Volumes {
+			if v.Source == name {
+				return nil
+			}
+		}
+	}
+	delete(f.volumes, name)
+	return nil
+}
+
+func (f *FakeRunner) Volumes(ctx context.Context, name string) ([]config.VolumeUsage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Volumes", name); err != nil {
+		return nil, err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return nil, err
+	}
+	var res []config.VolumeUsage
+	for _, v := range fc.config.Volumes {
+		if _, ok := f.volumes[v.Source]; ok {
+			res = append(res, config.VolumeUsage{Name: v.Source, Exists: true})
+		}
+	}
+	return res, nil
+}
+
+// Exec succeeds in any running container.
+func (f *FakeRunner) Exec(ctx context.Context, name string, cmd []string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Exec", name); err != nil {
+		return Result{}, err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return Result{}, err
+	}
+	if fc.state != config.StateRunning {
+		return Result{}, fmt.Errorf("container %s is not running", name)
+	}
+	return Result{}, nil
+}
+
+// RunOnce takes RunDuration and exits with the code of the image, zero by
+// default.
+func (f *FakeRunner) RunOnce(ctx context.Context, c config.Container) (Result, error) {
+	f.mu.Lock()
+	err := f.call("RunOnce", c.Name)
+	f.mu.Unlock()
+	if err != nil {
+		return Result{}, err
+	}
+	if slices.Contains(f.behavior.FailImages, c.Image) {
+		return Result{}, fmt.Errorf("no such image: %s", c.Image)
+	}
+	if err := sleep(ctx, f.behavior.RunDuration); err != nil {
+		return Result{}, err
+	}
+	return Result{ExitCode: f.behavior.ExitCodes[c.Image]}, nil
+}
+
+func (f *FakeRunner) Wait(ctx context.Context, name string) (Result, error) {
+	f.mu.Lock()
+	err := f.call("Wait", name)
+	f.mu.Unlock()
+	if err != nil {
+		return Result{}, err
+	}
+
+	for {
+		f.mu.Lock()
+		fc, err := f.get(name)
+		if err != nil {
+			f.mu.Unlock()
+			return Result{}, err
+		}
+		if fc.state != config.StateRunning {
+			res := Result{ExitCode: fc.exitCode}
+			f.mu.Unlock()
+			return res, nil
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			fc.state, fc.exitCode = config.StateExited, 137
+			f.mu.Unlock()
+			return Result{ExitCode: 137}, ctx.Err()
+		}
+		f.mu.Unlock()
+		if err := sleep(ctx, 10*time.Millisecond); errors.Is(err, context.Canceled) {
+			return Result{}, err
+		}
+	}
+}
+
+// Logs returns no output: fake containers write none. A followed stream
+// stays open until ctx is done.
+func (f *FakeRunner) Logs(ctx context.Context, name string, follow bool, since time.Time, tail int) (io.ReadCloser, error) {
+	f.mu.Lock()
+	err := f.call("Logs", name)
+	if err == nil {
+		_, err = f.get(name)
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !follow {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	pr, pw := io.Pipe()
+	context.AfterFunc(ctx, func() { pw.CloseWithError(ctx.Err()) })
+	return pr, nil
+}
+
+// Stats makes up the usage of a running container: random CPU and memory
+// within its limits, and I/O growing with every call.
+func (f *FakeRunner) Stats(ctx context.Context, name string) (config.ContainerStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("Stats", name); err != nil {
+		return config.ContainerStats{}, err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return config.ContainerStats{}, err
+	}
+	if fc.state != config.StateRunning {
+		return config.ContainerStats{}, fmt.Errorf("container %s is not running", name)
+	}
+
+	cpus := fc.config.Resources.CPUs
+	if cpus == 0 {
+		cpus = 1
+	}
+	limit := fc.config.Resources.Memory
+	if limit == 0 {
+		limit = config.GiB
+	}
+	fc.io.NetworkRx += config.ByteSize(f.rand.Int63n(int64(config.MiB)))
+	fc.io.NetworkTx += config.ByteSize(f.rand.Int63n(int64(config.MiB)))
+	fc.io.BlockRead += config.ByteSize(f.rand.Int63n(int64(config.MiB)))
+	fc.io.BlockWrite += config.ByteSize(f.rand.Int63n(int64(config.MiB)))
+
+	res := fc.io
+	res.Time = time.Now()
+	res.CPUPercent = f.rand.Float64() * cpus * 100
+	res.MemoryUsage = config.ByteSize(f.rand.Int63n(int64(limit)))
+	res.MemoryLimit = limit
+	return res, nil
+}
+
+// ExecSession starts a session echoing its input, in any running
+// container.
+func (f *FakeRunner) ExecSession(ctx context.Context, name string, cmd []string, tty bool) (Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("ExecSession", name); err != nil {
+		return nil, err
+	}
+	fc, err := f.get(name)
+	if err != nil {
+		return nil, err
+	}
+	if fc.state != config.StateRunning {
+		return nil, fmt.Errorf("container %s is not running", name)
+	}
+	pr, pw := io.Pipe()
+	return &fakeSession{PipeReader: pr, PipeWriter: pw}, nil
+}
+
+type fakeSession struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (s *fakeSession) CloseStdin() error {
+	return s.PipeWriter.Close()
+}
+
+func (s *fakeSession) Resize(width, height uint) error {
+	return nil
+}
+
+// Wait returns at once: the output ends when the input is closed.
+func (s *fakeSession) Wait(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (s *fakeSession) Close() error {
+	s.PipeWriter.Close()
+	return s.PipeReader.Close()
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
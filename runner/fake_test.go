@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+func TestFakeRunner_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := NewFakeRunner(FakeBehavior{})
+
+	c := config.Container{Name: "web", Image: "nginx", Ports: config.PortList{"80", "127.0.0.1:8443:443"}}
+	if _, err := f.PullImage(ctx, c.Image, PullOptions{}); err != nil {
+		t.Fatalf("PullImage: %v", err)
+	}
+	if err := f.Run(ctx, c); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := f.Run(ctx, c); err == nil {
+		t.Error("expected a conflict running a container twice")
+	}
+	if state, _ := f.State(ctx, "web"); state != "running" {
+		t.Errorf("expected running, got %s", state)
+	}
+	ports, _ := f.Ports(ctx, "web")
+	if want := []string{"0.0.0.0:32768->80/tcp", "127.0.0.1:8443->443/tcp"}; !reflect.DeepEqual(ports, want) {
+		t.Errorf("expected ports %v, got %v", want, ports)
+	}
+
+	if err := f.Stop(ctx, "web"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if state, _ := f.State(ctx, "web"); state != "exited" {
+		t.Errorf("expected exited, got %s", state)
+	}
+	if err := f.Remove(ctx, "web"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := f.State(ctx, "web"); err == nil {
+		t.Error("expected error for a removed container")
+	}
+
+	var methods []string
+	for _, call := range f.Calls() {
+		methods = append(methods, call.Method)
+	}
+	want := []string{"PullImage", "Run", "Run", "State", "Ports", "Stop", "State", "Remove", "State"}
+	if !reflect.DeepEqual(methods, want) {
+		t.Errorf("expected calls %v, got %v", want, methods)
+	}
+}
+
+func TestFakeRunner_Behavior(t *testing.T) {
+	ctx := context.Background()
+	f := NewFakeRunner(FakeBehavior{
+		FailImages:  []string{"broken:1"},
+		ExitCodes:   map[string]int{"batch": 3},
+		RunDuration: 20 * time.Millisecond,
+		Errors:      map[string]string{"Restart": "daemon unavailable"},
+	})
+
+	if _, err := f.PullImage(ctx, "broken:1", PullOptions{}); err == nil {
+		t.Error("expected PullImage to fail for a failing image")
+	}
+	if err := f.Run(ctx, config.Container{Name: "bad", Image: "broken:1"}); err == nil {
+		t.Error("expected Run to fail for a failing image")
+	}
+
+	if err := f.Run(ctx, config.Container{Name: "job", Image: "batch"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	res, err := f.Wait(ctx, "job")
+	if err != nil || res.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %+v, %v", res, err)
+	}
+	if err := f.Restart(ctx, "job"); err == nil || err.Error() != "daemon unavailable" {
+		t.Errorf("expected the scripted error, got %v", err)
+	}
+
+	if err := f.Run(ctx, config.Container{Name: "svc", Image: "nginx"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := f.Wait(waitCtx, "svc"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a service to time out, got %v", err)
+	}
+}
+
+func TestFakeRunner_Crashes(t *testing.T) {
+	ctx := context.Background()
+	// A huge crash rate makes every container crash at once.
+	f := NewFakeRunner(FakeBehavior{CrashRate: 1e9, Seed: 1})
+
+	if err := f.Run(ctx, config.Container{Name: "once", Image: "app"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := f.Run(ctx, config.Container{Name: "always", Image: "app", Restart: "always"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	if state, _ := f.State(ctx, "once"); state != "exited" {
+		t.Errorf("expected the crashed container to exit, got %s", state)
+	}
+	if state, _ := f.State(ctx, "always"); state != "running" {
+		t.Errorf("expected the crashed container to be restarted, got %s", state)
+	}
+}
+
+func TestFakeRunner_PullPolicy(t *testing.T) {
+	ctx := context.Background()
+	repushed := "sha256:" + strings.Repeat("1", 64)
+	f := NewFakeRunner(FakeBehavior{Digests: map[string]string{"app:latest": repushed}})
+
+	if _, err := f.PullImage(ctx, "app:latest", PullOptions{Policy: config.PullNever}); err == nil {
+		t.Error("expected the pull policy never to fail for a missing image")
+	}
+	dgst, err := f.PullImage(ctx, "app:latest", PullOptions{})
+	if err != nil || dgst != repushed {
+		t.Fatalf("expected digest %s, got %q, %v", repushed, dgst, err)
+	}
+	if dgst, err := f.PullImage(ctx, "app:latest", PullOptions{Policy: config.PullNever}); err != nil || dgst != repushed {
+		t.Errorf("expected the present image, got %q, %v", dgst, err)
+	}
+
+	pinned := "app@sha256:" + strings.Repeat("2", 64)
+	if dgst, err := f.PullImage(ctx, pinned, PullOptions{Policy: config.PullAlways}); err != nil || dgst != config.ImageDigest(pinned) {
+		t.Errorf("expected the digest of the reference, got %q, %v", dgst, err)
+	}
+}
+
+func TestFakeRunner_PullProgress(t *testing.T) {
+	f := NewFakeRunner(FakeBehavior{PullLatency: 20 * time.Millisecond, ImageSize: 10 * config.MiB})
+
+	var reports []config.PullProgress
+	_, err := f.PullImage(context.Background(), "app", PullOptions{Progress: func(p config.PullProgress) {
+		reports = append(reports, p)
+	}})
+	if err != nil {
+		t.Fatalf("PullImage: %v", err)
+	}
+	if len(reports) != 11 || reports[0].Percent() != 0 {
+		t.Fatalf("expected 11 reports starting at 0%%, got %+v", reports)
+	}
+	if last := reports[len(reports)-1]; last.Current != 10*config.MiB || last.Percent() != 100 {
+		t.Errorf("expected the last report to be complete, got %+v", last)
+	}
+}
@@ -1,13 +1,57 @@
 package runner
 
-import "github.com/rmerezha/mtrpz-lab4/config"
+import (
+	"context"
+	"io"
+	"time"
 
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// Runner manages containers on the slave. Every method gives up when ctx is
+// done.
 type Runner interface {
-	Run(container config.Container) error
-	Stop(name string) error
-	Kill(name string) error
-	Restart(name string) error
-	Remove(name string) error
-	PullImage(name string) error
-	State(name string) (string, error)
+	Run(ctx context.Context, container config.Container) error
+	Stop(ctx context.Context, name string) error
+	Kill(ctx context.Context, name string) error
+	Restart(ctx context.Context, name string) error
+	Remove(ctx context.Context, name string) error
+	// PullImage makes the image available and returns the digest it
+	// resolved to, if known.
+	PullImage(ctx context.Context, name string, opts PullOptions) (string, error)
+	State(ctx context.Context, name string) (string, error)
+	Ports(ctx context.Context, name string) ([]string, error)
+
+	// CreateNetwork creates the network unless it already exists.
+	CreateNetwork(ctx context.Context, n config.Network) error
+	// RemoveNetwork removes the network once no container uses it.
+	RemoveNetwork(ctx context.Context, name string) error
+
+	// CreateVolume creates the volume unless it already exists.
+	CreateVolume(ctx context.Context, v config.NamedVolume) error
+	// RemoveVolume removes the volume once no container uses it.
+	RemoveVolume(ctx context.Context, name string) error
+	// Volumes reports the named volumes mounted in the container.
+	Volumes(ctx context.Context, name string) ([]config.VolumeUsage, error)
+
+	// Exec runs cmd inside the running container.
+	Exec(ctx context.Context, name string, cmd []string) (Result, error)
+	// RunOnce runs the container to completion and removes it; it is killed
+	// when the deadline of ctx expires.
+	RunOnce(ctx context.Context, c config.Container) (Result, error)
+	// Wait waits for the container to exit; it is killed when the deadline
+	// of ctx expires.
+	Wait(ctx context.Context, name string) (Result, error)
+
+	// Logs streams the output of the container: only its last tail lines
+	// unless tail is zero, only what was written after since unless since is
+	// zero, and what is written later until ctx is done if follow is set.
+	Logs(ctx context.Context, name string, follow bool, since time.Time, tail int) (io.ReadCloser, error)
+
+	// ExecSession starts an interactive cmd inside the running container,
+	// in a TTY if tty is set. ctx only bounds starting it.
+	ExecSession(ctx context.Context, name string, cmd []string, tty bool) (Session, error)
+
+	// Stats samples the resource usage of the running container.
+	Stats(ctx context.Context, name string) (config.ContainerStats, error)
 }
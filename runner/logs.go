@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Logs streams the stdout and stderr of the container, merged.
+func (d *DockerRunner) Logs(ctx context.Context, name string, follow bool, since time.Time, tail int) (io.ReadCloser, error) {
+	opts := container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: follow}
+	if !since.IsZero() {
+		opts.Since = strconv.FormatInt(since.Unix(), 10)
+	}
+	if tail > 0 {
+		opts.Tail = strconv.Itoa(tail)
+	}
+	logs, err := d.cli.ContainerLogs(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stream is multiplexed: strip the headers of its frames.
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, logs)
+		pw.CloseWithError(err)
+	}()
+	return &logReader{PipeReader: pr, src: logs}, nil
+}
+
+type logReader struct {
+	*io.PipeReader
+	src io.Closer
+}
+
+func (l *logReader) Close() error {
+	l.src.Close()
+	return l.PipeReader.Close()
+}
+
+// tailLines returns the last n lines of b.
+func tailLines(b []byte, n int) []byte {
+	end := len(b)
+	if end > 0 && b[end-1] == '\n' {
+		end--
+	}
+	for i := end - 1; i >= 0; i-- {
+		if b[i] == '\n' {
+			n--
+			if n == 0 {
+				return b[i+1:]
+			}
+		}
+	}
+	return b
+}
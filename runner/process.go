@@ -0,0 +1,756 @@
+//go:build unix
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+const (
+	processRunning = "running"
+	processExited  = "exited"
+)
+
+var (
+	// stopTimeout is how long Stop waits after SIGTERM before SIGKILL.
+	stopTimeout = 10 * time.Second
+	// restartDelay is the pause before a process is restarted by its
+	// restart policy.
+	restartDelay = time.Second
+	// logPollInterval is how often followed logs are checked for output.
+	logPollInterval = 250 * time.Millisecond
+)
+
+// ProcessRunner runs containers as supervised host processes, for hosts
+// without Docker. It runs the entrypoint and cmd of a container with its
+// environment, working directory and user, and applies its restart policy.
+// The image is ignored, and containers with ports, volumes, networks or
+// resources are refused.
+//
+// Every process has a directory under dir holding its state and its
+// stdout.log and stderr.log, so processes are picked up again after the
+// slave restarts.
+type ProcessRunner struct {
+	dir string
+
+	mu    sync.Mutex
+	procs map[string]*process
+}
+
+// processState is persisted to state.json in the process directory.
+type processState struct {
+	Config     config.Container
+	Pid        int
+	State      string
+	ExitCode   int
+	Restarts   int
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// Stopped is set by Stop and Kill so that the restart policy leaves the
+	// process alone.
+	Stopped bool
+}
+
+type process struct {
+	dir string
+
+	mu      sync.Mutex
+	state   processState
+	removed bool
+	// done is closed when the current run exits.
+	done chan struct{}
+	// cpu is the previous CPU sample of Stats.
+	cpu cpuSample
+}
+
+type cpuSample struct {
+	pid  int
+	time time.Time
+	used time.Duration
+}
+
+// groupUsage is the resource usage of a process group.
+type groupUsage struct {
+	cpu    time.Duration
+	memory uint64
+	read   uint64
+	write  uint64
+}
+
+// NewProcessRunner returns a runner keeping its state under dir. Processes
+// recorded as running are adopted if still alive, and restarted according
+// to their restart policy otherwise.
+func NewProcessRunner(dir string) (*ProcessRunner, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	r := &ProcessRunner{dir: dir, procs: make(map[string]*process)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		p := &process{dir: filepath.Join(dir, e.Name()), done: make(chan struct{})}
+		data, err := os.ReadFile(filepath.Join(p.dir, "state.json"))
+		if err != nil {
+			log.Printf("ProcessRunner: skipping %s: %v", p.dir, err)
+			continue
+		}
+		if err := json.Unmarshal(data, &p.state); err != nil {
+			log.Printf("ProcessRunner: skipping %s: %v", p.dir, err)
+			continue
+		}
+		r.procs[p.state.Config.Name] = p
+
+		if p.state.State != processRunning {
+			close(p.done)
+			continue
+		}
+		// The process is not our child any more: poll it.
+		pid := p.state.Pid
+		go r.supervise(p, func() int {
+			for syscall.Kill(pid, 0) == nil {
+				time.Sleep(time.Second)
+			}
+			// The exit code of a process we did not start is unknown.
+			return -1
+		})
+	}
+	return r, nil
+}
+
+func (r *ProcessRunner) Run(ctx context.Context, c config.Container) error {
+	if err := c.Normalize(); err != nil {
+		return err
+	}
+	if err := unsupported(c); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.procs[c.Name]; ok {
+		return fmt.Errorf("process %s already exists", c.Name)
+	}
+
+	p := &process{dir: filepath.Join(r.dir, c.Name)}
+	if err := os.MkdirAll(p.dir, 0700); err != nil {
+		return err
+	}
+	p.state.Config = c
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := r.start(p); err != nil {
+		os.RemoveAll(p.dir)
+		return err
+	}
+	r.procs[c.Name] = p
+	return nil
+}
+
+// unsupported returns an error naming the settings of c a process cannot
+// honour. Running without them, a process would for instance miss the
+// secrets mounted as files.
+func unsupported(c config.Container) error {
+	var fields []string
+	if len(c.Volumes) > 0 {
+		fields = append(fields, "volumes")
+	}
+	if len(c.Ports) > 0 {
+		fields = append(fields, "ports")
+	}
+	if len(c.Networks) > 0 {
+		fields = append(fields, "networks")
+	}
+	if c.Resources != (config.Resources{}) {
+		fields = append(fields, "resources")
+	}
+	if len(fields) > 0 {
+		return fmt.Errorf("process runtime does not support %s of %s", strings.Join(fields, ", "), c.Name)
+	}
+	return nil
+}
+
+// start starts the process of p. p.mu must be held.
+func (r *ProcessRunner) start(p *process) error {
+	cmd, err := command(context.Background(), p.state.Config, nil)
+	if err != nil {
+		return err
+	}
+	stdout, err := os.OpenFile(filepath.Join(p.dir, "stdout.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer stdout.Close()
+	stderr, err := os.OpenFile(filepath.Join(p.dir, "stderr.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer stderr.Close()
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// A process group of its own lets signals reach its children, and keeps
+	// it running when the slave exits.
+	cmd.SysProcAttr.Setpgid = true
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.state.Pid = cmd.Process.Pid
+	p.state.State = processRunning
+	p.state.StartedAt = time.Now()
+	p.state.Stopped = false
+	p.done = make(chan struct{})
+	if err := p.save(); err != nil {
+		log.Printf("ProcessRunner: failed to save state of %s: %v", p.state.Config.Name, err)
+	}
+
+	go r.supervise(p, func() int {
+		_ = cmd.Wait()
+		return exitCode(cmd.ProcessState)
+	})
+	return nil
+}
+
+// supervise waits for the current run of p to exit, records it and applies
+// the restart policy.
+func (r *ProcessRunner) supervise(p *process, wait func() int) {
+	code := wait()
+
+	p.mu.Lock()
+	p.state.State = processExited
+	p.state.ExitCode = code
+	p.state.FinishedAt = time.Now()
+	close(p.done)
+	restart := !p.state.Stopped && !p.removed && shouldRestart(p.state.Config.Restart, code, p.state.Restarts)
+	if !p.removed {
+		if err := p.save(); err != nil {
+			log.Printf("ProcessRunner: failed to save state of %s: %v", p.state.Config.Name, err)
+		}
+	}
+	p.mu.Unlock()
+
+	if !restart {
+		return
+	}
+	time.Sleep(restartDelay)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.removed || p.state.Stopped || p.state.State != processExited {
+		return
+	}
+	p.state.Restarts++
+	if err := r.start(p); err != nil {
+		log.Printf("ProcessRunner: failed to restart %s: %v", p.state.Config.Name, err)
+	}
+}
+
+func (r *ProcessRunner) Stop(ctx context.Context, name string) error {
+	return r.signal(ctx, name, syscall.SIGTERM, stopTimeout)
+}
+
+func (r *ProcessRunner) Kill(ctx context.Context, name string) error {
+	return r.signal(ctx, name, syscall.SIGKILL, 0)
+}
+
+// signal sends sig to the process group and waits for it to exit, killing
+// it after timeout unless timeout is zero, or until ctx is done.
+func (r *ProcessRunner) signal(ctx context.Context, name string, sig syscall.Signal, timeout time.Duration) error {
+	p, err := r.get(name)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.state.Stopped = true
+	if err := p.save(); err != nil {
+		log.Printf("ProcessRunner: failed to save state of %s: %v", name, err)
+	}
+	running := p.state.State == processRunning
+	pid, done := p.state.Pid, p.done
+	p.mu.Unlock()
+	if !running {
+		return nil
+	}
+
+	if err := syscall.Kill(-pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	if timeout > 0 {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(timeout):
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		}
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *ProcessRunner) Restart(ctx context.Context, name string) error {
+	if err := r.Stop(ctx, name); err != nil {
+		return err
+	}
+	p, err := r.get(name)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state.State == processRunning {
+		return nil
+	}
+	return r.start(p)
+}
+
+func (r *ProcessRunner) Remove(ctx context.Context, name string) error {
+	if err := r.Kill(ctx, name); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	p := r.procs[name]
+	delete(r.procs, name)
+	r.mu.Unlock()
+
+	p.mu.Lock()
+	p.removed = true
+	p.mu.Unlock()
+	return os.RemoveAll(p.dir)
+}
+
+// PullImage does nothing: processes have no image.
+func (r *ProcessRunner) PullImage(ctx context.Context, name string, opts PullOptions) (string, error) {
+	return "", nil
+}
+
+func (r *ProcessRunner) State(ctx context.Context, name string) (string, error) {
+	p, err := r.get(name)
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.State, nil
+}
+
+// Ports returns nothing: processes bind host ports directly.
+func (r *ProcessRunner) Ports(ctx context.Context, name string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *ProcessRunner) CreateNetwork(ctx context.Context, n config.Network) error {
+	return nil
+}
+
+func (r *ProcessRunner) RemoveNetwork(ctx context.Context, name string) error {
+	return nil
+}
+
+func (r *ProcessRunner) CreateVolume(ctx context.Context, v config.NamedVolume) error {
+	return nil
+}
+
+func (r *ProcessRunner) RemoveVolume(ctx context.Context, name string) error {
+	return nil
+}
+
+func (r *ProcessRunner) Volumes(ctx context.Context, name string) ([]config.VolumeUsage, error) {
+	return nil, nil
+}
+
+// Exec runs cmd on the host with the environment and working directory of
+// the running process.
+func (r *ProcessRunner) Exec(ctx context.Context, name string, cmd []string) (Result, error) {
+	c, err := r.running(name)
+	if err != nil {
+		return Result{}, err
+	}
+	return runCommand(ctx, c, cmd)
+}
+
+// ExecSession starts cmd on the host like Exec, with its stdin attached.
+// There is no TTY: the process runtime has no pseudo-terminals.
+func (r *ProcessRunner) ExecSession(ctx context.Context, name string, cmd []string, tty bool) (Session, error) {
+	if tty {
+		return nil, errors.New("the process runtime does not support a TTY")
+	}
+	c, err := r.running(name)
+	if err != nil {
+		return nil, err
+	}
+	command, err := command(context.Background(), c, cmd)
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	command.Stdout, command.Stderr = pw, pw
+	command.SysProcAttr.Setpgid = true
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	s := &processSession{cmd: command, stdin: stdin, out: pr, done: make(chan struct{})}
+	go func() {
+		_ = command.Wait()
+		s.code = exitCode(command.ProcessState)
+		pw.Close()
+		close(s.done)
+	}()
+	return s, nil
+}
+
+type processSession struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   io.Reader
+	// code is set once done is closed.
+	code int
+	done chan struct{}
+}
+
+func (s *processSession) Read(p []byte) (int, error) {
+	return s.out.Read(p)
+}
+
+func (s *processSession) Write(p []byte) (int, error) {
+	return s.stdin.Write(p)
+}
+
+func (s *processSession) CloseStdin() error {
+	return s.stdin.Close()
+}
+
+func (s *processSession) Resize(width, height uint) error {
+	return errors.New("no TTY to resize")
+}
+
+func (s *processSession) Wait(ctx context.Context) (int, error) {
+	select {
+	case <-s.done:
+		return s.code, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (s *processSession) Close() error {
+	s.stdin.Close()
+	select {
+	case <-s.done:
+	default:
+		_ = syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return nil
+}
+
+// Stats sums the usage of the processes in the process group of the
+// process. There are no network stats, and the block I/O of children that
+// exited no longer counts.
+func (r *ProcessRunner) Stats(ctx context.Context, name string) (config.ContainerStats, error) {
+	p, err := r.get(name)
+	if err != nil {
+		return config.ContainerStats{}, err
+	}
+	p.mu.Lock()
+	pid, running := p.state.Pid, p.state.State == processRunning
+	p.mu.Unlock()
+	if !running {
+		return config.ContainerStats{}, fmt.Errorf("process %s is not running", name)
+	}
+
+	u, err := processGroupUsage(pid)
+	if err != nil {
+		return config.ContainerStats{}, err
+	}
+	res := config.ContainerStats{
+		Time:        time.Now(),
+		MemoryUsage: config.ByteSize(u.memory),
+		BlockRead:   config.ByteSize(u.read),
+		BlockWrite:  config.ByteSize(u.write),
+	}
+	p.mu.Lock()
+	prev := p.cpu
+	p.cpu = cpuSample{pid: pid, time: res.Time, used: u.cpu}
+	p.mu.Unlock()
+	if prev.pid == pid && u.cpu >= prev.used && res.Time.After(prev.time) {
+		res.CPUPercent = float64(u.cpu-prev.used) / float64(res.Time.Sub(prev.time)) * 100
+	}
+	return res, nil
+}
+
+// RunOnce runs the process of c to completion, killing it when ctx is done.
+func (r *ProcessRunner) RunOnce(ctx context.Context, c config.Container) (Result, error) {
+	if err := c.Normalize(); err != nil {
+		return Result{}, err
+	}
+	if err := unsupported(c); err != nil {
+		return Result{}, err
+	}
+	return runCommand(ctx, c, nil)
+}
+
+func (r *ProcessRunner) Wait(ctx context.Context, name string) (Result, error) {
+	p, err := r.get(name)
+	if err != nil {
+		return Result{}, err
+	}
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return Result{}, err
+		}
+		if err := r.Kill(context.WithoutCancel(ctx), name); err != nil {
+			return Result{}, err
+		}
+	}
+
+	p.mu.Lock()
+	res := Result{ExitCode: p.state.ExitCode}
+	p.mu.Unlock()
+	var out bytes.Buffer
+	for _, f := range []string{"stdout.log", "stderr.log"} {
+		data, _ := os.ReadFile(filepath.Join(p.dir, f))
+		out.Write(data)
+	}
+	res.Output = tail(out.Bytes())
+	return res, err
+}
+
+// Logs returns stdout.log followed by stderr.log, then the output appended
+// to either if follow is set. since is ignored: the logs have no timestamps.
+func (r *ProcessRunner) Logs(ctx context.Context, name string, follow bool, since time.Time, tail int) (io.ReadCloser, error) {
+	p, err := r.get(name)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{filepath.Join(p.dir, "stdout.log"), filepath.Join(p.dir, "stderr.log")}
+	offsets := make([]int64, len(files))
+	var out bytes.Buffer
+	for i, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		out.Write(data)
+		offsets[i] = int64(len(data))
+	}
+	data := out.Bytes()
+	if tail > 0 {
+		data = tailLines(data, tail)
+	}
+	if !follow {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		if _, err := pw.Write(data); err != nil {
+			return
+		}
+		ticker := time.NewTicker(logPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				pw.CloseWithError(ctx.Err())
+				return
+			case <-ticker.C:
+			}
+			for i, f := range files {
+				n, err := copyFrom(pw, f, offsets[i])
+				offsets[i] += n
+				if err != nil {
+					// The process was removed, or the reader closed.
+					pw.CloseWithError(err)
+					return
+				}
+			}
+		}
+	}()
+	return pr, nil
+}
+
+// copyFrom copies the file from offset to w.
+func copyFrom(w io.Writer, name string, offset int64) (int64, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, f)
+}
+
+// running returns the config of the process if it runs.
+func (r *ProcessRunner) running(name string) (config.Container, error) {
+	p, err := r.get(name)
+	if err != nil {
+		return config.Container{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state.State != processRunning {
+		return config.Container{}, fmt.Errorf("process %s is not running", name)
+	}
+	return p.state.Config, nil
+}
+
+func (r *ProcessRunner) get(name string) (*process, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.procs[name]
+	if !ok {
+		return nil, fmt.Errorf("no such process: %s", name)
+	}
+	return p, nil
+}
+
+// save writes the state atomically. p.mu must be held.
+func (p *process) save() error {
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(p.dir, "state.json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(p.dir, "state.json"))
+}
+
+// command builds the command running c, or args with the environment and
+// working directory of c if args is not empty.
+func command(ctx context.Context, c config.Container, args []string) (*exec.Cmd, error) {
+	if len(args) == 0 {
+		entrypoint, err := c.Entrypoint.Args()
+		if err != nil {
+			return nil, err
+		}
+		cmd, err := c.Cmd.Args()
+		if err != nil {
+			return nil, err
+		}
+		args = append(entrypoint, cmd...)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: the process runtime needs an entrypoint or cmd", c.Name)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = c.WorkDir
+	cmd.Env = os.Environ()
+	for k, v := range c.Environment {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if c.User != "" {
+		cred, err := credential(c.User)
+		if err != nil {
+			return nil, err
+		}
+		cmd.SysProcAttr.Credential = cred
+	}
+	return cmd, nil
+}
+
+// runCommand runs args, or the process of c, to completion, killing it when
+// ctx is done.
+func runCommand(ctx context.Context, c config.Container, args []string) (Result, error) {
+	cmd, err := command(ctx, c, args)
+	if err != nil {
+		return Result{}, err
+	}
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+
+	err = cmd.Run()
+	res := Result{Output: tail(out.Bytes())}
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return res, err
+	}
+	res.ExitCode = exitCode(cmd.ProcessState)
+	return res, nil
+}
+
+// exitCode reports a process killed by a signal as 128+signal, like docker.
+func exitCode(ps *os.ProcessState) int {
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ps.ExitCode()
+}
+
+// credential resolves user[:group], by name or numeric id.
+func credential(spec string) (*syscall.Credential, error) {
+	name, group, _ := strings.Cut(spec, ":")
+	uid, err := strconv.ParseUint(name, 10, 32)
+	var gid uint64
+	if err != nil {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		uid, _ = strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ = strconv.ParseUint(u.Gid, 10, 32)
+	}
+	if group != "" {
+		if gid, err = strconv.ParseUint(group, 10, 32); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return nil, err
+			}
+			gid, _ = strconv.ParseUint(g.Gid, 10, 32)
+		}
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package runner
+
+import "errors"
+
+// ProcessRunner is only available on unix.
+type ProcessRunner struct {
+	Runner
+}
+
+func NewProcessRunner(dir string) (*ProcessRunner, error) {
+	return nil, errors.New("the process runtime is only supported on unix")
+}
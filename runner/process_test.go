@@ -0,0 +1,324 @@
+//go:build unix
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+func init() {
+	restartDelay = 10 * time.Millisecond
+	stopTimeout = time.Second
+}
+
+func newTestProcessRunner(t *testing.T) (*ProcessRunner, string) {
+	t.Helper()
+	dir := t.TempDir()
+	r, err := NewProcessRunner(dir)
+	if err != nil {
+		t.Fatalf("NewProcessRunner: %v", err)
+	}
+	return r, dir
+}
+
+func waitState(t *testing.T, r *ProcessRunner, name, want string) {
+	t.Helper()
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state, err := r.State(ctx, name)
+		if err == nil && state == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to be %s, got %q (%v)", name, want, state, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProcessRunner_RunStop(t *testing.T) {
+	ctx := context.Background()
+	r, dir := newTestProcessRunner(t)
+
+	c := config.Container{
+		Name:        "web",
+		Image:       "ignored",
+		Cmd:         `sh -c 'echo "hello $GREETING from $(pwd)"; echo oops >&2; exec sleep 30'`,
+		Environment: map[string]string{"GREETING": "world"},
+		WorkDir:     dir,
+	}
+	if err := r.Run(ctx, c); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := r.Run(ctx, c); err == nil {
+		t.Error("expected error running a process twice")
+	}
+	waitState(t, r, "web", processRunning)
+	// Let the shell write its output before it is stopped.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if stderr, _ := os.ReadFile(filepath.Join(dir, "web", "stderr.log")); len(stderr) > 0 {
+			break
+		}
+	}
+
+	if err := r.Stop(ctx, "web"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	waitState(t, r, "web", processExited)
+
+	stdout, _ := os.ReadFile(filepath.Join(dir, "web", "stdout.log"))
+	if want := "hello world from " + dir + "\n"; string(stdout) != want {
+		t.Errorf("expected stdout %q, got %q", want, stdout)
+	}
+	stderr, _ := os.ReadFile(filepath.Join(dir, "web", "stderr.log"))
+	if string(stderr) != "oops\n" {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+
+	if err := r.Restart(ctx, "web"); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	waitState(t, r, "web", processRunning)
+
+	if err := r.Remove(ctx, "web"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := r.State(ctx, "web"); err == nil {
+		t.Error("expected error for a removed process")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "web")); !os.IsNotExist(err) {
+		t.Errorf("expected the process directory to be removed, got %v", err)
+	}
+}
+
+func TestProcessRunner_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestProcessRunner(t)
+
+	tests := []struct {
+		field string
+		c     config.Container
+	}{
+		{"volumes", config.Container{Volumes: []config.Volume{{Source: "/run/secrets/db", Target: "/run/secrets/db", ReadOnly: true}}}},
+		{"ports", config.Container{Ports: config.PortList{"8080:80"}}},
+		{"networks", config.Container{Networks: []string{"shop_front"}}},
+		{"resources", config.Container{Resources: config.Resources{Memory: 1 << 20}}},
+	}
+	for _, tt := range tests {
+		tt.c.Name, tt.c.Cmd = "app", "true"
+		if err := r.Run(ctx, tt.c); err == nil || !strings.Contains(err.Error(), tt.field) {
+			t.Errorf("%s: expected Run to refuse, got %v", tt.field, err)
+		}
+		if _, err := r.RunOnce(ctx, tt.c); err == nil || !strings.Contains(err.Error(), tt.field) {
+			t.Errorf("%s: expected RunOnce to refuse, got %v", tt.field, err)
+		}
+	}
+	if _, err := r.State(ctx, "app"); err == nil {
+		t.Error("expected no process to be recorded")
+	}
+}
+
+func TestProcessRunner_Wait(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestProcessRunner(t)
+
+	if err := r.Run(ctx, config.Container{Name: "job", Cmd: "sh -c 'echo done; exit 3'"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	res, err := r.Wait(ctx, "job")
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if res.ExitCode != 3 || res.Output != "done\n" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	if err := r.Run(ctx, config.Container{Name: "slow", Cmd: "sleep 30"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	res, err = r.Wait(waitCtx, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) || res.ExitCode != 128+9 {
+		t.Errorf("expected a timeout and SIGKILL, got %+v, %v", res, err)
+	}
+}
+
+func TestProcessRunner_RestartPolicy(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestProcessRunner(t)
+
+	c := config.Container{Name: "flaky", Cmd: "false", Restart: "on-failure:2"}
+	if err := r.Run(ctx, c); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p, _ := r.get("flaky")
+		p.mu.Lock()
+		restarts, state := p.state.Restarts, p.state.State
+		p.mu.Unlock()
+		if restarts == 2 && state == processExited {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 restarts, got %d (%s)", restarts, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProcessRunner_Adopt(t *testing.T) {
+	ctx := context.Background()
+	r, dir := newTestProcessRunner(t)
+
+	if err := r.Run(ctx, config.Container{Name: "svc", Cmd: "sleep 30"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := r.Run(ctx, config.Container{Name: "gone", Cmd: "true"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	waitState(t, r, "gone", processExited)
+
+	// A new runner on the same directory, as after a slave restart.
+	r2, err := NewProcessRunner(dir)
+	if err != nil {
+		t.Fatalf("NewProcessRunner: %v", err)
+	}
+	waitState(t, r2, "svc", processRunning)
+	waitState(t, r2, "gone", processExited)
+
+	if err := r2.Stop(ctx, "svc"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	waitState(t, r2, "svc", processExited)
+	waitState(t, r, "svc", processExited)
+}
+
+func TestProcessRunner_Exec(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestProcessRunner(t)
+
+	c := config.Container{Name: "app", Cmd: "sleep 30", Environment: map[string]string{"MODE": "test"}}
+	if err := r.Run(ctx, c); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	defer r.Remove(ctx, "app")
+
+	res, err := r.Exec(ctx, "app", []string{"sh", "-c", "echo $MODE; exit 4"})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if res.ExitCode != 4 || strings.TrimSpace(res.Output) != "test" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	res, err = r.RunOnce(ctx, config.Container{Name: "once", Entrypoint: "sh", Cmd: "-c 'exit 0'"})
+	if err != nil || res.ExitCode != 0 {
+		t.Errorf("unexpected RunOnce result: %+v, %v", res, err)
+	}
+}
+
+func TestProcessRunner_Logs(t *testing.T) {
+	r, _ := newTestProcessRunner(t)
+	ctx := context.Background()
+
+	if err := r.Run(ctx, config.Container{Name: "app", Cmd: "sh -c 'echo one; echo two; sleep 30'"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	defer r.Remove(ctx, "app")
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		logs, err := r.Logs(ctx, "app", false, time.Time{}, 1)
+		if err != nil {
+			t.Fatalf("Logs: %v", err)
+		}
+		out, _ := io.ReadAll(logs)
+		if string(out) == "two\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the last line, got %q", out)
+		}
+	}
+
+	followCtx, cancel := context.WithCancel(ctx)
+	logs, err := r.Logs(followCtx, "app", true, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("Logs: %v", err)
+	}
+	cancel()
+	out, err := io.ReadAll(logs)
+	if !errors.Is(err, context.Canceled) || string(out) != "one\ntwo\n" {
+		t.Errorf("expected the logs until cancelled, got %q, %v", out, err)
+	}
+}
+
+func TestProcessRunner_Stats(t *testing.T) {
+	r, _ := newTestProcessRunner(t)
+	ctx := context.Background()
+
+	if err := r.Run(ctx, config.Container{Name: "app", Cmd: "sh -c 'while :; do :; done'"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	defer r.Remove(ctx, "app")
+
+	if _, err := r.Stats(ctx, "app"); err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	stats, err := r.Stats(ctx, "app")
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.CPUPercent <= 0 || stats.MemoryUsage <= 0 {
+		t.Errorf("expected the usage of a busy loop, got %+v", stats)
+	}
+
+	if err := r.Stop(ctx, "app"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := r.Stats(ctx, "app"); err == nil {
+		t.Error("expected an error for a stopped process")
+	}
+}
+
+func TestProcessRunner_ExecSession(t *testing.T) {
+	r, _ := newTestProcessRunner(t)
+	ctx := context.Background()
+
+	if err := r.Run(ctx, config.Container{Name: "app", Cmd: "sleep 30"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	defer r.Remove(ctx, "app")
+
+	if _, err := r.ExecSession(ctx, "app", []string{"sh"}, true); err == nil {
+		t.Error("expected a TTY to be refused")
+	}
+	s, err := r.ExecSession(ctx, "app", []string{"sh", "-c", "read line; echo got $line; exit 5"}, false)
+	if err != nil {
+		t.Fatalf("ExecSession: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out, _ := io.ReadAll(s)
+	if string(out) != "got hello\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if code, err := s.Wait(ctx); err != nil || code != 5 {
+		t.Errorf("expected exit code 5, got %d, %v", code, err)
+	}
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of the CPU times in /proc; it is 100 on
+// every architecture Go supports.
+const clockTicks = 100
+
+// processGroupUsage sums the usage of the processes in the process group
+// pgid, read from /proc.
+func processGroupUsage(pgid int) (groupUsage, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return groupUsage{}, err
+	}
+	var u groupUsage
+	found := false
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", e.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			// The process exited meanwhile.
+			continue
+		}
+		// The command name may hold spaces: the fields start after it.
+		i := bytes.LastIndexByte(data, ')')
+		if i < 0 || i+2 > len(data) {
+			continue
+		}
+		fields := strings.Fields(string(data[i+2:]))
+		if len(fields) < 22 {
+			continue
+		}
+		if pgrp, _ := strconv.Atoi(fields[2]); pgrp != pgid {
+			continue
+		}
+		found = true
+		// The CPU time of the process and of the children it reaped.
+		var ticks uint64
+		for _, f := range fields[11:15] {
+			n, _ := strconv.ParseUint(f, 10, 64)
+			ticks += n
+		}
+		rss, _ := strconv.ParseUint(fields[21], 10, 64)
+		u.cpu += time.Duration(ticks) * time.Second / clockTicks
+		u.memory += rss * uint64(os.Getpagesize())
+
+		// io is only readable by the owner of the process.
+		if read, write, err := processIO(filepath.Join(dir, "io")); err == nil {
+			u.read += read
+			u.write += write
+		}
+	}
+	if !found {
+		return groupUsage{}, fmt.Errorf("no process in process group %d", pgid)
+	}
+	return u, nil
+}
+
+func processIO(path string) (read, write uint64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, value, _ := strings.Cut(sc.Text(), ": ")
+		switch key {
+		case "read_bytes":
+			read, _ = strconv.ParseUint(value, 10, 64)
+		case "write_bytes":
+			write, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return read, write, sc.Err()
+}
@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package runner
+
+import "errors"
+
+// processGroupUsage needs /proc, which only Linux has.
+func processGroupUsage(pgid int) (groupUsage, error) {
+	return groupUsage{}, errors.New("stats of the process runtime are only supported on linux")
+}
@@ -0,0 +1,230 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// ErrRegistryAuth is wrapped by the errors of PullImage when the registry
+// refused the credentials, or asked for some and there were none.
+var ErrRegistryAuth = errors.New("registry authentication failed")
+
+// PullOptions are the options of PullImage.
+type PullOptions struct {
+	// Auth holds the credentials for the registry of the image, if any.
+	Auth *config.RegistryAuth
+	// Policy is one of the config pull policies. Empty means
+	// config.PullIfNotPresent.
+	Policy string
+	// Progress, if set, is called whenever the progress of a pull changes.
+	Progress func(config.PullProgress)
+}
+
+// pullMessage is a message of the JSON stream of a pull. Messages about a
+// layer carry its ID.
+type pullMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+	Error string `json:"error"`
+}
+
+// layerProgress is the download progress of a layer.
+type layerProgress struct {
+	current, total int64
+	done           bool
+}
+
+// pullTracker sums the progress of the layers of a pull.
+type pullTracker struct {
+	progress config.PullProgress
+	layers   map[string]*layerProgress
+}
+
+func newPullTracker(image string) *pullTracker {
+	return &pullTracker{
+		progress: config.PullProgress{Image: image, Started: time.Now()},
+		layers:   make(map[string]*layerProgress),
+	}
+}
+
+// update applies a message of the stream and tells whether the progress
+// changed.
+func (t *pullTracker) update(msg pullMessage) bool {
+	if msg.ID == "" {
+		return false
+	}
+	l := t.layers[msg.ID]
+	switch msg.Status {
+	case "Pulling fs layer", "Waiting":
+		if l != nil {
+			return false
+		}
+		l = &layerProgress{}
+	case "Downloading":
+		if l == nil {
+			l = &layerProgress{}
+		}
+		l.current, l.total = msg.ProgressDetail.Current, msg.ProgressDetail.Total
+	case "Verifying Checksum", "Download complete", "Extracting", "Pull complete", "Already exists":
+		if l == nil {
+			l = &layerProgress{}
+		}
+		if l.done {
+			return false
+		}
+		l.current, l.done = l.total, true
+	default:
+		return false
+	}
+	t.layers[msg.ID] = l
+
+	p := &t.progress
+	p.Current, p.Total, p.Layers, p.Done = 0, 0, len(t.layers), 0
+	for _, l := range t.layers {
+		p.Current += config.ByteSize(l.current)
+		p.Total += config.ByteSize(l.total)
+		if l.done {
+			p.Done++
+		}
+	}
+	return true
+}
+
+// PullImage makes the image available according to opts.Policy and returns
+// the digest it resolved to, or "" for images that were never pushed.
+func (d *DockerRunner) PullImage(ctx context.Context, name string, opts PullOptions) (string, error) {
+	if opts.Policy != config.PullAlways {
+		dgst, found, err := d.localImage(ctx, name)
+		if err != nil || found {
+			return dgst, err
+		}
+		if opts.Policy == config.PullNever {
+			return "", fmt.Errorf("image %s is not present and the pull policy is %s", name, config.PullNever)
+		}
+	}
+
+	var pullOpts image.PullOptions
+	var err error
+	if opts.Auth != nil {
+		pullOpts.RegistryAuth, err = registry.EncodeAuthConfig(registry.AuthConfig{
+			Username:      opts.Auth.Username,
+			Password:      opts.Auth.Password,
+			ServerAddress: opts.Auth.Registry,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	out, err := d.cli.ImagePull(ctx, name, pullOpts)
+	if err != nil {
+		return "", pullError(err)
+	}
+	defer out.Close()
+
+	// The daemon reports failures after the pull started in the stream,
+	// and the digest the tag resolved to in a status line.
+	var dgst string
+	tracker := newPullTracker(name)
+	dec := json.NewDecoder(out)
+	for {
+		var msg pullMessage
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if msg.ErrorDetail != nil && msg.ErrorDetail.Message != "" {
+			return "", pullError(errors.New(msg.ErrorDetail.Message))
+		}
+		if msg.Error != "" {
+			return "", pullError(errors.New(msg.Error))
+		}
+		if d, ok := strings.CutPrefix(msg.Status, "Digest: "); ok {
+			dgst = d
+		}
+		if tracker.update(msg) && opts.Progress != nil {
+			opts.Progress(tracker.progress)
+		}
+	}
+	if dgst != "" {
+		return dgst, nil
+	}
+	dgst, _, err = d.localImage(ctx, name)
+	return dgst, err
+}
+
+// localImage looks the image up among the local ones and returns the digest
+// of its repository.
+func (d *DockerRunner) localImage(ctx context.Context, name string) (string, bool, error) {
+	ref, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return "", false, err
+	}
+	ref = reference.TagNameOnly(ref)
+	images, err := d.cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return "", false, err
+	}
+	for _, img := range images {
+		if !slices.ContainsFunc(img.RepoTags, sameRef(ref)) && !slices.ContainsFunc(img.RepoDigests, sameRef(ref)) {
+			continue
+		}
+		if d := config.ImageDigest(name); d != "" {
+			return d, true, nil
+		}
+		for _, rd := range img.RepoDigests {
+			other, err := reference.ParseNormalizedNamed(rd)
+			if err != nil || other.Name() != ref.Name() {
+				continue
+			}
+			if digested, ok := other.(reference.Digested); ok {
+				return digested.Digest().String(), true, nil
+			}
+		}
+		return "", true, nil
+	}
+	return "", false, nil
+}
+
+// sameRef matches references to ref, such as nginx and
+// docker.io/library/nginx:latest.
+func sameRef(ref reference.Named) func(string) bool {
+	return func(s string) bool {
+		other, err := reference.ParseNormalizedNamed(s)
+		return err == nil && reference.TagNameOnly(other).String() == ref.String()
+	}
+}
+
+// pullError wraps err with ErrRegistryAuth if the registry refused access.
+// Registries tell it apart in their messages only.
+func pullError(err error) error {
+	msg := strings.ToLower(err.Error())
+	if cerrdefs.IsUnauthorized(err) || cerrdefs.IsPermissionDenied(err) ||
+		strings.Contains(msg, "unauthorized") ||
+		strings.Contains(msg, "authentication required") ||
+		strings.Contains(msg, "access denied") ||
+		strings.Contains(msg, "access to the resource is denied") ||
+		strings.Contains(msg, "docker login") {
+		return fmt.Errorf("%w: %v", ErrRegistryAuth, err)
+	}
+	return err
+}
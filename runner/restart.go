@@ -0,0 +1,19 @@
+package runner
+
+import "github.com/rmerezha/mtrpz-lab4/config"
+
+// shouldRestart applies a docker restart policy to a container that exited
+// with code after restarts restarts. Runners without docker use it.
+func shouldRestart(policy string, code, restarts int) bool {
+	mode, max, err := config.ParseRestart(policy)
+	if err != nil {
+		return false
+	}
+	switch mode {
+	case "always", "unless-stopped":
+		return true
+	case "on-failure":
+		return code != 0 && (max == 0 || restarts < max)
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Session is an interactive command running in a container.
+type Session interface {
+	// Read reads the output of the command, stdout and stderr merged. It
+	// returns io.EOF once the command exited.
+	Read(p []byte) (int, error)
+	// Write writes to the stdin of the command.
+	Write(p []byte) (int, error)
+	// CloseStdin closes the stdin of the command.
+	CloseStdin() error
+	// Resize resizes the terminal of a session with a TTY.
+	Resize(width, height uint) error
+	// Wait waits for the command to exit and returns its exit code.
+	Wait(ctx context.Context) (int, error)
+	// Close ends the session, hanging up on the command.
+	Close() error
+}
+
+// execPollInterval is how often Wait checks whether the exec exited.
+const execPollInterval = 100 * time.Millisecond
+
+// ExecSession starts cmd inside the running container with its stdin
+// attached, in a TTY if tty is set.
+func (d *DockerRunner) ExecSession(ctx context.Context, name string, cmd []string, tty bool) (Session, error) {
+	exec, err := d.cli.ContainerExecCreate(ctx, name, container.ExecOptions{
+		Cmd:          cmd,
+		Tty:          tty,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{Tty: tty})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &dockerSession{cli: d.cli, id: exec.ID, resp: resp, out: resp.Reader}
+	if !tty {
+		// Without a TTY the output is multiplexed.
+		pr, pw := io.Pipe()
+		go func() {
+			_, err := stdcopy.StdCopy(pw, pw, resp.Reader)
+			pw.CloseWithError(err)
+		}()
+		s.out = pr
+	}
+	return s, nil
+}
+
+type dockerSession struct {
+	cli  DockerClient
+	id   string
+	resp types.HijackedResponse
+	out  io.Reader
+}
+
+func (s *dockerSession) Read(p []byte) (int, error) {
+	return s.out.Read(p)
+}
+
+func (s *dockerSession) Write(p []byte) (int, error) {
+	return s.resp.Conn.Write(p)
+}
+
+func (s *dockerSession) CloseStdin() error {
+	return s.resp.CloseWrite()
+}
+
+func (s *dockerSession) Resize(width, height uint) error {
+	return s.cli.ContainerExecResize(context.Background(), s.id, container.ResizeOptions{Width: width, Height: height})
+}
+
+func (s *dockerSession) Wait(ctx context.Context) (int, error) {
+	for {
+		info, err := s.cli.ContainerExecInspect(ctx, s.id)
+		if err != nil {
+			return 0, err
+		}
+		if !info.Running {
+			return info.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(execPollInterval):
+		}
+	}
+}
+
+func (s *dockerSession) Close() error {
+	s.resp.Close()
+	return nil
+}
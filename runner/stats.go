@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// Stats samples the container in one shot, without waiting for the daemon
+// to take a second sample: the CPU usage is measured since the previous call
+// instead.
+func (d *DockerRunner) Stats(ctx context.Context, name string) (config.ContainerStats, error) {
+	resp, err := d.cli.ContainerStatsOneShot(ctx, name)
+	if err != nil {
+		return config.ContainerStats{}, err
+	}
+	defer resp.Body.Close()
+	var s container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return config.ContainerStats{}, err
+	}
+
+	res := config.ContainerStats{
+		Time:        s.Read,
+		MemoryUsage: config.ByteSize(memoryUsage(s.MemoryStats)),
+		MemoryLimit: config.ByteSize(s.MemoryStats.Limit),
+	}
+	d.mu.Lock()
+	if d.cpu == nil {
+		d.cpu = make(map[string]container.CPUStats)
+	}
+	prev, ok := d.cpu[name]
+	d.cpu[name] = s.CPUStats
+	d.mu.Unlock()
+	if ok {
+		res.CPUPercent = cpuPercent(prev, s.CPUStats)
+	}
+	for _, n := range s.Networks {
+		res.NetworkRx += config.ByteSize(n.RxBytes)
+		res.NetworkTx += config.ByteSize(n.TxBytes)
+	}
+	for _, e := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			res.BlockRead += config.ByteSize(e.Value)
+		case "write":
+			res.BlockWrite += config.ByteSize(e.Value)
+		}
+	}
+	return res, nil
+}
+
+// forget drops the CPU sample kept for the container.
+func (d *DockerRunner) forget(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.cpu, name)
+}
+
+// cpuPercent computes the CPU usage between two samples the way the docker
+// CLI does.
+func cpuPercent(prev, cur container.CPUStats) float64 {
+	if cur.CPUUsage.TotalUsage <= prev.CPUUsage.TotalUsage || cur.SystemUsage <= prev.SystemUsage {
+		return 0
+	}
+	cpus := float64(cur.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(cur.CPUUsage.PercpuUsage))
+	}
+	cpuDelta := float64(cur.CPUUsage.TotalUsage - prev.CPUUsage.TotalUsage)
+	systemDelta := float64(cur.SystemUsage - prev.SystemUsage)
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage leaves out the page cache the kernel can reclaim, as the docker
+// CLI does.
+func memoryUsage(m container.MemoryStats) uint64 {
+	// cgroup v1 reports total_inactive_file, cgroup v2 inactive_file.
+	if v, ok := m.Stats["total_inactive_file"]; ok && v < m.Usage {
+		return m.Usage - v
+	}
+	if v, ok := m.Stats["inactive_file"]; ok && v < m.Usage {
+		return m.Usage - v
+	}
+	return m.Usage
+}
@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/rmerezha/mtrpz-lab4/config"
+)
+
+// Timeouts bound the operations of a Runner; a zero duration leaves the
+// operation unbounded.
+type Timeouts struct {
+	// Pull bounds pulling an image.
+	Pull time.Duration
+	// Start bounds starting a container and creating its networks and
+	// volumes.
+	Start time.Duration
+	// Stop bounds stopping, killing, restarting and removing a container and
+	// removing networks and volumes.
+	Stop time.Duration
+	// Inspect bounds reading the state, ports, volumes and stats of a
+	// container.
+	Inspect time.Duration
+}
+
+// WithTimeouts returns a Runner that bounds every operation of r by the
+// matching timeout. Exec, RunOnce, Wait and Logs are left to the deadline of
+// the caller.
+func WithTimeouts(r Runner, t Timeouts) Runner {
+	return &timeoutRunner{r: r, t: t}
+}
+
+type timeoutRunner struct {
+	r Runner
+	t Timeouts
+}
+
+func bound(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func (tr *timeoutRunner) Run(ctx context.Context, c config.Container) error {
+	ctx, cancel := bound(ctx, tr.t.Start)
+	defer cancel()
+	return tr.r.Run(ctx, c)
+}
+
+func (tr *timeoutRunner) Stop(ctx context.Context, name string) error {
+	ctx, cancel := bound(ctx, tr.t.Stop)
+	defer cancel()
+	return tr.r.Stop(ctx, name)
+}
+
+func (tr *timeoutRunner) Kill(ctx context.Context, name string) error {
+	ctx, cancel := bound(ctx, tr.t.Stop)
+	defer cancel()
+	return tr.r.Kill(ctx, name)
+}
+
+func (tr *timeoutRunner) Restart(ctx context.Context, name string) error {
+	ctx, cancel := bound(ctx, tr.t.Stop)
+	defer cancel()
+	return tr.r.Restart(ctx, name)
+}
+
+func (tr *timeoutRunner) Remove(ctx context.Context, name string) error {
+	ctx, cancel := bound(ctx, tr.t.Stop)
+	defer cancel()
+	return tr.r.Remove(ctx, name)
+}
+
+func (tr *timeoutRunner) PullImage(ctx context.Context, name string, opts PullOptions) (string, error) {
+	ctx, cancel := bound(ctx, tr.t.Pull)
+	defer cancel()
+	return tr.r.PullImage(ctx, name, opts)
+}
+
+func (tr *timeoutRunner) State(ctx context.Context, name string) (string, error) {
+	ctx, cancel := bound(ctx, tr.t.Inspect)
+	defer cancel()
+	return tr.r.State(ctx, name)
+}
+
+func (tr *timeoutRunner) Ports(ctx context.Context, name string) ([]string, error) {
+	ctx, cancel := bound(ctx, tr.t.Inspect)
+	defer cancel()
+	return tr.r.Ports(ctx, name)
+}
+
+func (tr *timeoutRunner) CreateNetwork(ctx context.Context, n config.Network) error {
+	ctx, cancel := bound(ctx, tr.t.Start)
+	defer cancel()
+	return tr.r.CreateNetwork(ctx, n)
+}
+
+func (tr *timeoutRunner) RemoveNetwork(ctx context.Context, name string) error {
+	ctx, cancel := bound(ctx, tr.t.Stop)
+	defer cancel()
+	return tr.r.RemoveNetwork(ctx, name)
+}
+
+func (tr *timeoutRunner) CreateVolume(ctx context.Context, v config.NamedVolume) error {
+	ctx, cancel := bound(ctx, tr.t.Start)
+	defer cancel()
+	return tr.r.CreateVolume(ctx, v)
+}
+
+func (tr *timeoutRunner) RemoveVolume(ctx context.Context, name string) error {
+	ctx, cancel := bound(ctx, tr.t.Stop)
+	defer cancel()
+	return tr.r.RemoveVolume(ctx, name)
+}
+
+func (tr *timeoutRunner) Volumes(ctx context.Context, name string) ([]config.VolumeUsage, error) {
+	ctx, cancel := bound(ctx, tr.t.Inspect)
+	defer cancel()
+	return tr.r.Volumes(ctx, name)
+}
+
+func (tr *timeoutRunner) Exec(ctx context.Context, name string, cmd []string) (Result, error) {
+	return tr.r.Exec(ctx, name, cmd)
+}
+
+func (tr *timeoutRunner) RunOnce(ctx context.Context, c config.Container) (Result, error) {
+	return tr.r.RunOnce(ctx, c)
+}
+
+func (tr *timeoutRunner) Wait(ctx context.Context, name string) (Result, error) {
+	return tr.r.Wait(ctx, name)
+}
+
+func (tr *timeoutRunner) Logs(ctx context.Context, name string, follow bool, since time.Time, tail int) (io.ReadCloser, error) {
+	return tr.r.Logs(ctx, name, follow, since, tail)
+}
+
+func (tr *timeoutRunner) ExecSession(ctx context.Context, name string, cmd []string, tty bool) (Session, error) {
+	ctx, cancel := bound(ctx, tr.t.Start)
+	defer cancel()
+	return tr.r.ExecSession(ctx, name, cmd, tty)
+}
+
+func (tr *timeoutRunner) Stats(ctx context.Context, name string) (config.ContainerStats, error) {
+	ctx, cancel := bound(ctx, tr.t.Inspect)
+	defer cancel()
+	return tr.r.Stats(ctx, name)
+}
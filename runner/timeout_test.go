@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithTimeouts(t *testing.T) {
+	f := NewFakeRunner(FakeBehavior{PullLatency: time.Second})
+	r := WithTimeouts(f, Timeouts{Pull: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := r.PullImage(context.Background(), "nginx", PullOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the pull to time out, got %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("expected the pull to give up early, took %s", d)
+	}
+
+	// Without a timeout the pull is only bounded by the caller.
+	r = WithTimeouts(f, Timeouts{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.PullImage(ctx, "nginx", PullOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the pull to be cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keySize = 32
+
+var (
+	ErrNotFound    = errors.New("secret not found")
+	ErrInvalidName = errors.New("invalid secret name")
+)
+
+type Info struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type entry struct {
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Store keeps secrets encrypted with AES-256-GCM both in memory and in the
+// backing file, so plaintext only exists while a value is being resolved.
+type Store struct {
+	mu      sync.RWMutex
+	aead    cipher.AEAD
+	path    string
+	entries map[string]entry
+	// validName checks the names of new entries.
+	validName func(string) bool
+}
+
+func NewStore(path string, key []byte) (*Store, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		aead:      aead,
+		path:      path,
+		entries:   make(map[string]entry),
+		validName: ValidName,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// LoadKey reads a hex-encoded master key from path, generating and saving a
+// new random key when the file does not exist yet.
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key in %s: %w", path, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("master key in %s must be %d bytes, got %d", path, keySize, len(key))
+	}
+	return key, nil
+}
+
+// NewRegistryStore returns a store of registry credentials, named by the
+// host of their registry, e.g. registry.example.com:5000.
+func NewRegistryStore(path string, key []byte) (*Store, error) {
+	s, err := NewStore(path, key)
+	if err != nil {
+		return nil, err
+	}
+	s.validName = ValidRegistry
+	return s, nil
+}
+
+func (s *Store) Set(name string, value []byte) error {
+	if !s.validName(name) {
+		return ErrInvalidName
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.entries[name]
+	s.entries[name] = entry{
+		Nonce:      nonce,
+		Ciphertext: s.aead.Seal(nil, nonce, value, []byte(name)),
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if err := s.save(); err != nil {
+		if existed {
+			s.entries[name] = prev
+		} else {
+			delete(s.entries, name)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Get(name string) ([]byte, error) {
+	s.mu.RLock()
+	e, ok := s.entries[name]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.aead.Open(nil, e.Nonce, e.Ciphertext, []byte(name))
+}
+
+func (s *Store) Has(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.entries[name]
+	return ok
+}
+
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.entries[name]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, name)
+
+	if err := s.save(); err != nil {
+		s.entries[name] = prev
+		return err
+	}
+	return nil
+}
+
+func (s *Store) List() []Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Info, 0, len(s.entries))
+	for name, e := range s.entries {
+		res = append(res, Info{Name: name, CreatedAt: e.CreatedAt})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// ValidName reports whether name can name a secret. Names are used as file
+// names on the slaves, so "." and ".." are rejected.
+func ValidName(name string) bool {
+	if name == "" || len(name) > 253 || strings.Trim(name, ".") == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidRegistry checks a registry host: a host name with an optional port.
+func ValidRegistry(name string) bool {
+	host, port, hasPort := strings.Cut(name, ":")
+	if hasPort {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return false
+		}
+	}
+	return ValidName(host) && !strings.HasPrefix(host, "-") && !strings.HasPrefix(host, ".")
+}
@@ -0,0 +1,151 @@
+package secrets_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmerezha/mtrpz-lab4/secrets"
+)
+
+func newStore(t *testing.T, dir string) *secrets.Store {
+	t.Helper()
+	key, err := secrets.LoadKey(filepath.Join(dir, "master.key"))
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+	store, err := secrets.NewStore(filepath.Join(dir, "secrets.json"), key)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	return store
+}
+
+func TestStore_SetGet(t *testing.T) {
+	store := newStore(t, t.TempDir())
+
+	if err := store.Set("db-password", []byte("hunter2")); err != nil {
+		t.Fatalf("failed to set secret: %v", err)
+	}
+
+	val, err := store.Get("db-password")
+	if err != nil {
+		t.Fatalf("failed to get secret: %v", err)
+	}
+	if string(val) != "hunter2" {
+		t.Errorf("expected 'hunter2', got %q", val)
+	}
+}
+
+func TestStore_EncryptedAtRest(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore(t, dir)
+
+	if err := store.Set("api-key", []byte("plaintext-value")); err != nil {
+		t.Fatalf("failed to set secret: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "secrets.json"))
+	if err != nil {
+		t.Fatalf("failed to read secrets file: %v", err)
+	}
+	if bytes.Contains(data, []byte("plaintext-value")) {
+		t.Error("secrets file contains plaintext value")
+	}
+}
+
+func TestStore_PersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore(t, dir)
+
+	if err := store.Set("token", []byte("abc")); err != nil {
+		t.Fatalf("failed to set secret: %v", err)
+	}
+
+	reopened := newStore(t, dir)
+	val, err := reopened.Get("token")
+	if err != nil {
+		t.Fatalf("failed to get secret after reopen: %v", err)
+	}
+	if string(val) != "abc" {
+		t.Errorf("expected 'abc', got %q", val)
+	}
+}
+
+func TestStore_WrongKey(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore(t, dir)
+
+	if err := store.Set("token", []byte("abc")); err != nil {
+		t.Fatalf("failed to set secret: %v", err)
+	}
+
+	otherKey := bytes.Repeat([]byte{1}, 32)
+	other, err := secrets.NewStore(filepath.Join(dir, "secrets.json"), otherKey)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	if _, err := other.Get("token"); err == nil {
+		t.Error("expected decryption with wrong key to fail")
+	}
+}
+
+func TestStore_DeleteAndList(t *testing.T) {
+	store := newStore(t, t.TempDir())
+
+	for _, name := range []string{"b", "a"} {
+		if err := store.Set(name, []byte(name)); err != nil {
+			t.Fatalf("failed to set secret %s: %v", name, err)
+		}
+	}
+
+	list := store.List()
+	if len(list) != 2 || list[0].Name != "a" || list[1].Name != "b" {
+		t.Fatalf("expected sorted [a b], got %+v", list)
+	}
+
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("failed to delete secret: %v", err)
+	}
+	if _, err := store.Get("a"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := store.Delete("a"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second delete, got %v", err)
+	}
+}
+
+func TestStore_InvalidName(t *testing.T) {
+	store := newStore(t, t.TempDir())
+
+	for _, name := range []string{"", "has space", "../etc", ".", ".."} {
+		if err := store.Set(name, []byte("x")); !errors.Is(err, secrets.ErrInvalidName) {
+			t.Errorf("Set(%q): expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
+
+func TestRegistryStore_Names(t *testing.T) {
+	dir := t.TempDir()
+	key, err := secrets.LoadKey(filepath.Join(dir, "master.key"))
+	if err != nil {
+		t.Fatalf("failed to load key: %v", err)
+	}
+	store, err := secrets.NewRegistryStore(filepath.Join(dir, "registries.json"), key)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	for _, name := range []string{"docker.io", "registry.example.com:5000", "localhost"} {
+		if err := store.Set(name, []byte("x")); err != nil {
+			t.Errorf("Set(%q): unexpected error %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "example.com:", "example.com:99999", "example.com/app", "-x.io"} {
+		if err := store.Set(name, []byte("x")); !errors.Is(err, secrets.ErrInvalidName) {
+			t.Errorf("Set(%q): expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package stream frames the interactive exec sessions the master relays
+// between a client and a slave. Every frame is a type byte, a big-endian
+// uint32 length and the payload.
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Upgrade is the protocol named in the Upgrade header of the connections
+// carrying frames.
+const Upgrade = "mtrpz-exec"
+
+const (
+	// Stdin carries input to the command; an empty one closes its stdin.
+	Stdin byte = iota
+	// Output carries the stdout and stderr of the command.
+	Output
+	// Resize carries the width and height of the terminal, as two uint16.
+	Resize
+	// Exit carries the exit code of the command, as an int32. It is the last
+	// frame of a session.
+	Exit
+	// Error carries the message of a failure ending the session.
+	Error
+)
+
+// maxFrame bounds the payload of a frame.
+const maxFrame = 1 << 20
+
+// ReadFrame reads the next frame from r.
+func ReadFrame(r io.Reader) (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > maxFrame {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return hdr[0], payload, nil
+}
+
+// Writer writes frames; it is safe for concurrent use.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (fw *Writer) WriteFrame(typ byte, payload []byte) error {
+	if len(payload) > maxFrame {
+		return fmt.Errorf("frame of %d bytes is too large", len(payload))
+	}
+	buf := make([]byte, 5+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(payload)))
+	copy(buf[5:], payload)
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	_, err := fw.w.Write(buf)
+	return err
+}
+
+// WriteResize sends the size of the terminal.
+func (fw *Writer) WriteResize(width, height uint16) error {
+	var p [4]byte
+	binary.BigEndian.PutUint16(p[:], width)
+	binary.BigEndian.PutUint16(p[2:], height)
+	return fw.WriteFrame(Resize, p[:])
+}
+
+// WriteExit sends the exit code of the command.
+func (fw *Writer) WriteExit(code int) error {
+	var p [4]byte
+	binary.BigEndian.PutUint32(p[:], uint32(int32(code)))
+	return fw.WriteFrame(Exit, p[:])
+}
+
+// ParseResize decodes the payload of a Resize frame.
+func ParseResize(p []byte) (width, height uint16, err error) {
+	if len(p) != 4 {
+		return 0, 0, errors.New("malformed resize frame")
+	}
+	return binary.BigEndian.Uint16(p), binary.BigEndian.Uint16(p[2:]), nil
+}
+
+// ParseExit decodes the payload of an Exit frame.
+func ParseExit(p []byte) (int, error) {
+	if len(p) != 4 {
+		return 0, errors.New("malformed exit frame")
+	}
+	return int(int32(binary.BigEndian.Uint32(p))), nil
+}
@@ -0,0 +1,41 @@
+package stream
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteFrame(Stdin, []byte("ls\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteResize(120, 40); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteExit(-1); err != nil {
+		t.Fatal(err)
+	}
+
+	typ, p, err := ReadFrame(&buf)
+	if err != nil || typ != Stdin || string(p) != "ls\n" {
+		t.Errorf("unexpected frame %d %q, %v", typ, p, err)
+	}
+	typ, p, _ = ReadFrame(&buf)
+	if width, height, err := ParseResize(p); typ != Resize || err != nil || width != 120 || height != 40 {
+		t.Errorf("unexpected resize %d %dx%d, %v", typ, width, height, err)
+	}
+	typ, p, _ = ReadFrame(&buf)
+	if code, err := ParseExit(p); typ != Exit || err != nil || code != -1 {
+		t.Errorf("unexpected exit %d %d, %v", typ, code, err)
+	}
+	if _, _, err := ReadFrame(&buf); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	if _, _, err := ReadFrame(bytes.NewReader([]byte{Output, 0, 0, 0, 5, 'a'})); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected a truncated frame to fail, got %v", err)
+	}
+}